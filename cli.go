@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/diamondburned/chatkit/kits/secret"
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/api"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/utils/httputil"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/ipc"
+)
+
+const usage = `Usage:
+  gtkcord4 [--instance URL] [--open CHANNEL]
+  gtkcord4 [--instance URL] send CHANNEL TEXT|-
+  gtkcord4 [--instance URL] status online|idle|dnd|invisible
+
+CHANNEL is a channel ID or a link to a channel or a message. If TEXT is -, the
+message is read from standard input.
+
+send and status use the running instance if there is one and it's connected
+to the same instance. Otherwise, they use the remembered account without
+opening a window, asking for its password if it's encrypted.
+`
+
+// cliOptions are the options parsed from the command line.
+type cliOptions struct {
+	// Open is the channel or link to open.
+	Open string
+	// Instance is the URL of the instance to use instead of the default one.
+	Instance string
+	// Command is the subcommand and its arguments, if any.
+	Command []string
+	// Args are the remaining arguments, which are given to the application.
+	Args []string
+}
+
+// parseArgs parses the command line. Unknown flags are kept in Args, since
+// they belong to GApplication.
+func parseArgs(args []string) (cliOptions, error) {
+	opts := cliOptions{Args: args[:1]}
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+
+		var dst *string
+		switch {
+		case arg == "--open" || strings.HasPrefix(arg, "--open="):
+			dst = &opts.Open
+		case arg == "--instance" || strings.HasPrefix(arg, "--instance="):
+			dst = &opts.Instance
+		case arg == "send" || arg == "status":
+			opts.Command = args[i:]
+			return opts, nil
+		default:
+			opts.Args = append(opts.Args, arg)
+			continue
+		}
+
+		if name, value, ok := strings.Cut(arg, "="); ok {
+			if value == "" {
+				return opts, fmt.Errorf("%s needs a value", name)
+			}
+			*dst = value
+			continue
+		}
+
+		if i+1 >= len(args) {
+			return opts, fmt.Errorf("%s needs a value", arg)
+		}
+		i++
+		*dst = args[i]
+	}
+
+	return opts, nil
+}
+
+// runCommand runs the subcommand and returns the exit code. ctx must have the
+// application, which the secret drivers use to find the account.
+func runCommand(ctx context.Context, opts cliOptions) int {
+	args := opts.Command
+	var err error
+
+	switch args[0] {
+	case "send":
+		if len(args) != 3 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		err = cliSend(ctx, opts.Instance, args[1], args[2])
+	case "status":
+		if len(args) != 2 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		err = cliStatus(ctx, opts.Instance, args[1])
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+
+	return 0
+}
+
+func cliSend(ctx context.Context, instance, channel, text string) error {
+	cmd, err := gtkcord.ParseChannelLink(channel)
+	if err != nil {
+		return err
+	}
+
+	if text == "-" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return errors.Wrap(err, "cannot read standard input")
+		}
+		text = string(b)
+	}
+
+	text = strings.TrimRight(text, "\n")
+	if strings.TrimSpace(text) == "" {
+		return errors.New("message is empty")
+	}
+
+	send := gtkcord.SendMessageCommand{
+		ChannelID: cmd.ChannelID,
+		Content:   text,
+	}
+
+	if client := runningInstance(instance); client != nil {
+		msgID, err := client.SendMessage(send)
+		if !errors.Is(err, ipc.ErrNotLoggedIn) {
+			if err == nil {
+				fmt.Println(msgID)
+			}
+			return err
+		}
+	}
+
+	c, err := headlessClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	msg, err := c.SendMessage(send.ChannelID, send.Content)
+	if err != nil {
+		return errors.Wrap(err, "cannot send message")
+	}
+
+	fmt.Println(msg.ID)
+	return nil
+}
+
+func cliStatus(ctx context.Context, instance, status string) error {
+	cmd := gtkcord.SetStatusCommand{Status: discord.Status(status)}
+
+	switch cmd.Status {
+	case discord.OnlineStatus, discord.IdleStatus, discord.DoNotDisturbStatus, discord.InvisibleStatus:
+	default:
+		return fmt.Errorf("unknown status %q, expected online, idle, dnd or invisible", status)
+	}
+
+	if client := runningInstance(instance); client != nil {
+		err := client.SetStatus(cmd)
+		if !errors.Is(err, ipc.ErrNotLoggedIn) {
+			return err
+		}
+	}
+
+	c, err := headlessClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Without a Gateway connection, the status can only be changed through
+	// the user settings, which the server broadcasts to the other sessions.
+	err = c.FastRequest("PATCH", api.EndpointMe+"/settings", httputil.WithJSONBody(map[string]interface{}{
+		"status": cmd.Status,
+	}))
+	return errors.Wrap(err, "cannot update user settings")
+}
+
+// cliOpen opens the channel in the running instance. It returns false if
+// there is no running instance.
+func cliOpen(instance string, cmd gtkcord.OpenChannelCommand) bool {
+	conn, err := dbus.SessionBus()
+	if err != nil || !ipc.IsRunning(conn) {
+		return false
+	}
+
+	client := ipc.NewClient(conn)
+
+	// The running instance owns the application, so another window can't be
+	// opened for a different instance.
+	if instance != "" && !sameInstance(client) {
+		fmt.Fprintln(os.Stderr, "error: gtkcord4 is already running with another instance")
+		return true
+	}
+
+	if err := client.OpenChannel(cmd); err != nil {
+		// The instance is running but isn't logged in yet or doesn't know the
+		// channel, so there's nothing else to do.
+		fmt.Fprintln(os.Stderr, "error:", err)
+	}
+
+	return true
+}
+
+// runningInstance returns a client to the running instance, or nil if there
+// isn't one. If instance isn't empty, then the running instance is only used
+// if it's connected to the same instance.
+func runningInstance(instance string) *ipc.Client {
+	conn, err := dbus.SessionBus()
+	if err != nil || !ipc.IsRunning(conn) {
+		return nil
+	}
+
+	client := ipc.NewClient(conn)
+	if instance != "" && !sameInstance(client) {
+		return nil
+	}
+
+	return client
+}
+
+// sameInstance returns true if the running instance is connected to the
+// instance given with --instance.
+func sameInstance(client *ipc.Client) bool {
+	host, err := client.Instance()
+	return err == nil && strings.EqualFold(host, gtkcord.InstanceHost())
+}
+
+// headlessClient creates an API client using the remembered account. Like the
+// login page, it tries the keyring first and then the encrypted file, whose
+// password is asked for on the terminal.
+func headlessClient(ctx context.Context) (*api.Client, error) {
+	token, err := secret.KeyringDriver(ctx).Get("account")
+	if err != nil && secret.IsEncrypted(ctx) {
+		var pass string
+		pass, err = readPassword("Password of the encrypted account: ")
+		if err != nil {
+			return nil, err
+		}
+
+		token, err = secret.EncryptedFileDriver(ctx, pass).Get("account")
+		if err != nil && !errors.Is(err, secret.ErrNotFound) {
+			return nil, errors.Wrap(err, "cannot decrypt account")
+		}
+	}
+	if err != nil {
+		if errors.Is(err, secret.ErrNotFound) {
+			return nil, errors.New("not logged in, log in with Remember Account checked first")
+		}
+		return nil, errors.Wrap(err, "cannot get account from keyring")
+	}
+
+	return api.NewClient(string(token)).WithContext(ctx), nil
+}
+
+// readPassword asks for a password on the terminal without echoing it. The
+// terminal is used instead of standard input, which may hold the message.
+func readPassword(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot ask for the password without a terminal")
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+
+	if err := stty(tty, "-echo"); err != nil {
+		return "", errors.Wrap(err, "cannot disable terminal echo")
+	}
+	defer func() {
+		stty(tty, "echo")
+		fmt.Fprintln(tty)
+	}()
+
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.Wrap(err, "cannot read password")
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func stty(tty *os.File, arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = tty
+	return cmd.Run()
+}
@@ -0,0 +1,137 @@
+// Package activity publishes the user's activities, such as the song that's
+// playing or the game that's running, as their Gateway presence.
+package activity
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/godbus/dbus/v5"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+var shareMedia = prefs.NewBool(false, prefs.PropMeta{
+	Name:        "Share Media",
+	Section:     "Activity",
+	Description: "Show the song playing in media players as a Listening to activity.",
+})
+
+var shareGames = prefs.NewBool(false, prefs.PropMeta{
+	Name:        "Share Games",
+	Section:     "Activity",
+	Description: "Show a running program from the list below as a Playing activity.",
+})
+
+var gameList = prefs.NewString("", prefs.StringMeta{
+	Name:    "Games",
+	Section: "Activity",
+	Description: "Process names to show as a Playing activity, one per line. " +
+		"Add = and a name to show another name, such as \"hl2_linux = Half-Life 2\".",
+	Placeholder: "hl2_linux = Half-Life 2",
+	Multiline:   true,
+})
+
+func init() {
+	prefs.Order(shareMedia, shareGames, gameList)
+}
+
+// Provider provides an activity.
+type Provider interface {
+	// Enabled returns true if the user opted into sharing the activity.
+	Enabled() bool
+	// Activity returns the current activity, or nil if there is none.
+	Activity() *discord.Activity
+}
+
+const (
+	// pollInterval is how often the providers are asked for their activity.
+	pollInterval = 10 * time.Second
+	// minUpdateInterval is the minimum time between two presence updates,
+	// which stays well under the Gateway's rate limit. Changes made in
+	// between are sent afterwards.
+	minUpdateInterval = 15 * time.Second
+)
+
+// Start starts publishing the activities of the providers in the background.
+// It stops once ctx is done. The state is taken from ctx.
+func Start(ctx context.Context) {
+	state := gtkcord.FromContext(ctx)
+	providers := []Provider{
+		newProcessProvider("/proc"),
+	}
+
+	go func() {
+		if conn, err := dbus.SessionBus(); err == nil {
+			providers = append(providers, newMPRISProvider(conn))
+		} else {
+			log.Println("activity: cannot connect to session bus:", err)
+		}
+
+		publish(ctx, state, providers)
+	}()
+}
+
+func publish(ctx context.Context, state *gtkcord.State, providers []Provider) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var published []discord.Activity
+	var lastUpdate time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if state.Status() == discord.OfflineStatus {
+			// Not connected yet.
+			continue
+		}
+
+		var activities []discord.Activity
+		for _, provider := range providers {
+			if !provider.Enabled() {
+				continue
+			}
+			if activity := provider.Activity(); activity != nil {
+				activities = append(activities, *activity)
+			}
+		}
+
+		// Presence updates from elsewhere may have dropped the activities, in
+		// which case they're sent again.
+		unchanged := reflect.DeepEqual(activities, published) && shown(state.Activities(), published)
+		if unchanged || time.Since(lastUpdate) < minUpdateInterval {
+			continue
+		}
+
+		if err := state.SetActivities(activities); err != nil {
+			log.Println("activity: cannot update presence:", err)
+			continue
+		}
+
+		published = activities
+		lastUpdate = time.Now()
+	}
+}
+
+// shown returns true if every published activity is in the current ones.
+// Only the name and type are compared, since the Gateway may fill in the rest.
+func shown(current, published []discord.Activity) bool {
+outer:
+	for _, p := range published {
+		for _, c := range current {
+			if c.Name == p.Name && c.Type == p.Type {
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
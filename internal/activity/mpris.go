@@ -0,0 +1,89 @@
+package activity
+
+import (
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+)
+
+const (
+	mprisPrefix      = "org.mpris.MediaPlayer2."
+	mprisPath        = "/org/mpris/MediaPlayer2"
+	mprisIface       = "org.mpris.MediaPlayer2"
+	mprisPlayerIface = "org.mpris.MediaPlayer2.Player"
+)
+
+// mprisProvider provides a Listening to activity from the first MPRIS media
+// player that's playing.
+type mprisProvider struct {
+	conn *dbus.Conn
+}
+
+func newMPRISProvider(conn *dbus.Conn) *mprisProvider {
+	return &mprisProvider{conn: conn}
+}
+
+// Enabled implements Provider.
+func (p *mprisProvider) Enabled() bool { return shareMedia.Value() }
+
+// Activity implements Provider.
+func (p *mprisProvider) Activity() *discord.Activity {
+	var names []string
+	if err := p.conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		return nil
+	}
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, mprisPrefix) {
+			continue
+		}
+
+		if activity := p.playerActivity(p.conn.Object(name, mprisPath)); activity != nil {
+			return activity
+		}
+	}
+
+	return nil
+}
+
+func (p *mprisProvider) playerActivity(player dbus.BusObject) *discord.Activity {
+	status, err := player.GetProperty(mprisPlayerIface + ".PlaybackStatus")
+	if err != nil || status.Value() != "Playing" {
+		return nil
+	}
+
+	variant, err := player.GetProperty(mprisPlayerIface + ".Metadata")
+	if err != nil {
+		return nil
+	}
+
+	metadata, ok := variant.Value().(map[string]dbus.Variant)
+	if !ok {
+		return nil
+	}
+
+	title, _ := metadata["xesam:title"].Value().(string)
+	if title == "" {
+		return nil
+	}
+
+	name := "Music"
+	if identity, err := player.GetProperty(mprisIface + ".Identity"); err == nil {
+		if str, _ := identity.Value().(string); str != "" {
+			name = str
+		}
+	}
+
+	activity := &discord.Activity{
+		Name:    name,
+		Type:    discord.ListeningActivity,
+		Details: title,
+	}
+
+	if artists, _ := metadata["xesam:artist"].Value().([]string); len(artists) > 0 {
+		activity.State = strings.Join(artists, ", ")
+	}
+
+	return activity
+}
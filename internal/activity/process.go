@@ -0,0 +1,134 @@
+package activity
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+)
+
+// game is a process name from the Games list and the name to show for it.
+type game struct {
+	process string
+	name    string
+}
+
+// parseGames parses the Games list. Each line holds a process name and an
+// optional display name after an =.
+func parseGames(list string) []game {
+	var games []game
+
+	for _, line := range strings.Split(list, "\n") {
+		process, name, _ := strings.Cut(line, "=")
+		process = strings.TrimSpace(process)
+		name = strings.TrimSpace(name)
+
+		if process == "" {
+			continue
+		}
+		if name == "" {
+			name = process
+		}
+
+		games = append(games, game{process, name})
+	}
+
+	return games
+}
+
+// processProvider provides a Playing activity from the first game in the
+// Games list that's running.
+type processProvider struct {
+	procDir string
+	// started is when each running game was first seen, so that the elapsed
+	// time doesn't restart on every poll.
+	started map[string]time.Time
+}
+
+func newProcessProvider(procDir string) *processProvider {
+	return &processProvider{
+		procDir: procDir,
+		started: make(map[string]time.Time),
+	}
+}
+
+// Enabled implements Provider.
+func (p *processProvider) Enabled() bool { return shareGames.Value() }
+
+// Activity implements Provider.
+func (p *processProvider) Activity() *discord.Activity {
+	games := parseGames(gameList.Value())
+	if len(games) == 0 {
+		return nil
+	}
+
+	running := p.running()
+
+	// Forget games that were closed, so they start over when reopened.
+	for process := range p.started {
+		if !running[process] {
+			delete(p.started, process)
+		}
+	}
+
+	for _, game := range games {
+		if !running[game.process] {
+			continue
+		}
+
+		started, ok := p.started[game.process]
+		if !ok {
+			started = time.Now()
+			p.started[game.process] = started
+		}
+
+		return &discord.Activity{
+			Name: game.name,
+			Type: discord.GameActivity,
+			Timestamps: &discord.ActivityTimestamps{
+				Start: discord.UnixMsTimestamp(started.UnixNano() / int64(time.Millisecond)),
+			},
+		}
+	}
+
+	return nil
+}
+
+// running returns the names of the running processes. Each process is known
+// by the name of its executable, the name it was started with and its comm,
+// since the comm is cut off at 15 characters.
+func (p *processProvider) running() map[string]bool {
+	dirs, _ := filepath.Glob(filepath.Join(p.procDir, "[0-9]*"))
+	running := make(map[string]bool, len(dirs))
+
+	for _, dir := range dirs {
+		if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+			exe = strings.TrimSuffix(exe, " (deleted)")
+			running[filepath.Base(exe)] = true
+		}
+
+		if b, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+			argv0, _, _ := strings.Cut(string(b), "\x00")
+			if name := processName(argv0); name != "" {
+				running[name] = true
+			}
+		}
+
+		if b, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+			running[strings.TrimSpace(string(b))] = true
+		}
+	}
+
+	return running
+}
+
+// processName returns the file name of argv[0]. Windows paths are also
+// handled for games running through Wine.
+func processName(argv0 string) string {
+	if i := strings.LastIndexAny(argv0, "/\\"); i >= 0 {
+		argv0 = argv0[i+1:]
+	}
+	return argv0
+}
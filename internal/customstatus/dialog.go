@@ -0,0 +1,253 @@
+package customstatus
+
+import (
+	"context"
+	"time"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/components/onlineimage"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// ClearAfter is a choice for when a custom status is cleared.
+type ClearAfter struct {
+	Name locale.Localized
+	// Expiry returns the time that the custom status set at now is cleared.
+	// The zero time never clears it.
+	Expiry func(now time.Time) time.Time
+}
+
+func clearAfterDuration(d time.Duration) func(time.Time) time.Time {
+	return func(now time.Time) time.Time { return now.Add(d) }
+}
+
+// ClearAfters are the choices for when a custom status is cleared.
+var ClearAfters = []ClearAfter{
+	{locale.Localized("30 minutes"), clearAfterDuration(30 * time.Minute)},
+	{locale.Localized("1 hour"), clearAfterDuration(time.Hour)},
+	{locale.Localized("4 hours"), clearAfterDuration(4 * time.Hour)},
+	{locale.Localized("Today"), func(now time.Time) time.Time {
+		y, m, d := now.Date()
+		return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+	}},
+	{locale.Localized("Don't clear"), func(time.Time) time.Time { return time.Time{} }},
+}
+
+// defaultClearAfter is the index of the ClearAfter that's selected at first.
+const defaultClearAfter = 3 // Today
+
+// Dialog is a dialog for setting the user's custom status.
+type Dialog struct {
+	*gtk.Dialog
+	ctx context.Context
+
+	emojiButton *gtk.Button
+	emojiLabel  *gtk.Label
+	emojiImage  *onlineimage.Image
+	emojiIcon   *gtk.Image
+	emoji       *discord.Emoji
+
+	entry      *gtk.Entry
+	clearAfter *adw.ComboRow
+}
+
+const dialogFlags = 0 |
+	gtk.DialogDestroyWithParent |
+	gtk.DialogModal |
+	gtk.DialogUseHeaderBar
+
+// ShowDialog shows a dialog for setting the user's custom status.
+func ShowDialog(ctx context.Context) {
+	d := NewDialog(ctx)
+	d.Show()
+}
+
+// NewDialog creates a new custom status dialog that's filled in with the
+// user's current custom status.
+func NewDialog(ctx context.Context) *Dialog {
+	d := Dialog{ctx: ctx}
+
+	d.emojiLabel = gtk.NewLabel("")
+	d.emojiImage = onlineimage.NewImage(ctx, imgutil.HTTPProvider)
+	d.emojiImage.SetSizeRequest(emojiSize, emojiSize)
+	d.emojiIcon = gtk.NewImageFromIconName("face-smile-symbolic")
+
+	emojiBox := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	emojiBox.Append(d.emojiLabel)
+	emojiBox.Append(d.emojiImage)
+	emojiBox.Append(d.emojiIcon)
+
+	d.emojiButton = gtk.NewButton()
+	d.emojiButton.SetTooltipText(locale.Get("Choose Emoji"))
+	d.emojiButton.SetChild(emojiBox)
+	d.emojiButton.ConnectClicked(func() {
+		chooser := gtk.NewEmojiChooser()
+		chooser.SetParent(d.emojiButton)
+		chooser.ConnectEmojiPicked(func(text string) {
+			d.setEmoji(&discord.Emoji{Name: text})
+		})
+		gtkutil.PopupFinally(chooser)
+	})
+
+	customButton := gtk.NewButtonFromIconName("image-x-generic-symbolic")
+	customButton.SetTooltipText(locale.Get("Choose Custom Emoji"))
+	customButton.ConnectClicked(func() {
+		picker := newCustomEmojiPicker(ctx, customButton, func(emoji discord.Emoji) {
+			d.setEmoji(&emoji)
+		})
+		gtkutil.PopupFinally(picker)
+	})
+
+	d.entry = gtk.NewEntry()
+	d.entry.SetHExpand(true)
+	d.entry.SetMaxLength(128)
+	d.entry.SetActivatesDefault(true)
+	d.entry.SetPlaceholderText(locale.Get("What's happening?"))
+	d.entry.SetIconFromIconName(gtk.EntryIconSecondary, "edit-clear-symbolic")
+	d.entry.SetIconTooltipText(gtk.EntryIconSecondary, locale.Get("Clear"))
+	d.entry.ConnectIconPress(func(gtk.EntryIconPosition) {
+		d.entry.SetText("")
+		d.setEmoji(nil)
+	})
+
+	statusBox := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	statusBox.Append(d.emojiButton)
+	statusBox.Append(customButton)
+	statusBox.Append(d.entry)
+
+	clearAfters := make([]string, len(ClearAfters))
+	for i, clearAfter := range ClearAfters {
+		clearAfters[i] = clearAfter.Name.String()
+	}
+
+	d.clearAfter = adw.NewComboRow()
+	d.clearAfter.SetTitle(locale.Get("Clear After"))
+	d.clearAfter.SetModel(gtk.NewStringList(clearAfters))
+	d.clearAfter.SetSelected(defaultClearAfter)
+
+	statusGroup := adw.NewPreferencesGroup()
+	statusGroup.SetTitle(locale.Get("Custom Status"))
+	statusGroup.Add(statusBox)
+
+	clearGroup := adw.NewPreferencesGroup()
+	clearGroup.Add(d.clearAfter)
+
+	page := adw.NewPreferencesPage()
+	page.Add(statusGroup)
+	page.Add(clearGroup)
+
+	d.Dialog = gtk.NewDialogWithFlags(
+		app.FromContext(ctx).SuffixedTitle(locale.Get("Set Custom Status")),
+		app.GTKWindowFromContext(ctx),
+		dialogFlags,
+	)
+	d.Dialog.SetHideOnClose(false)
+	d.Dialog.SetDefaultSize(400, 300)
+	d.Dialog.SetChild(page)
+
+	d.Dialog.AddButton(locale.Get("Clear Status"), int(gtk.ResponseReject))
+	save := d.Dialog.AddButton(locale.Get("Save"), int(gtk.ResponseAccept))
+	gtk.BaseWidget(save).AddCSSClass("suggested-action")
+	d.Dialog.SetDefaultResponse(int(gtk.ResponseAccept))
+	d.Dialog.ConnectResponse(func(id int) {
+		switch id {
+		case int(gtk.ResponseAccept):
+			d.save()
+		case int(gtk.ResponseReject):
+			d.send(nil)
+		}
+	})
+
+	esc := gtk.NewEventControllerKey()
+	esc.SetName("dialog-escape")
+	esc.ConnectKeyPressed(func(val, _ uint, state gdk.ModifierType) bool {
+		switch val {
+		case gdk.KEY_Escape:
+			d.Dialog.Close()
+			return true
+		}
+		return false
+	})
+	d.Dialog.AddController(esc)
+
+	if app.IsDevel() {
+		d.Dialog.AddCSSClass("devel")
+	}
+
+	d.setEmoji(nil)
+
+	state := gtkcord.FromContext(ctx)
+	if custom := state.CustomStatus(); custom != nil {
+		d.entry.SetText(custom.State)
+		d.setEmoji(custom.Emoji)
+	}
+
+	return &d
+}
+
+// setEmoji shows the emoji in the emoji button. A nil emoji removes it.
+func (d *Dialog) setEmoji(emoji *discord.Emoji) {
+	d.emoji = emoji
+
+	d.emojiLabel.SetVisible(false)
+	d.emojiImage.SetVisible(false)
+	d.emojiIcon.SetVisible(false)
+
+	switch {
+	case emoji == nil:
+		d.emojiIcon.SetVisible(true)
+	case emoji.ID.IsValid():
+		d.emojiImage.SetFromURL(gtkcord.EmojiURL(emoji.ID.String(), emoji.Animated))
+		d.emojiImage.SetVisible(true)
+	default:
+		d.emojiLabel.SetText(emoji.Name)
+		d.emojiLabel.SetVisible(true)
+	}
+}
+
+func (d *Dialog) save() {
+	text := d.entry.Text()
+	if text == "" && d.emoji == nil {
+		d.send(nil)
+		return
+	}
+
+	custom := &gateway.CustomUserStatus{Text: text}
+	if d.emoji != nil {
+		custom.EmojiID = d.emoji.ID
+		custom.EmojiName = d.emoji.Name
+	}
+
+	clearAfter := ClearAfters[d.clearAfter.Selected()]
+	if expiry := clearAfter.Expiry(time.Now()); !expiry.IsZero() {
+		custom.ExpiresAt = discord.NewTimestamp(expiry)
+	}
+
+	d.send(custom)
+}
+
+func (d *Dialog) send(custom *gateway.CustomUserStatus) {
+	state := gtkcord.FromContext(d.ctx)
+	d.SetSensitive(false)
+
+	gtkutil.Async(d.ctx, func() func() {
+		err := state.SetCustomStatus(custom)
+		return func() {
+			if err != nil {
+				d.SetSensitive(true)
+				app.Error(d.ctx, errors.Wrap(err, "cannot set custom status"))
+				return
+			}
+			d.Close()
+		}
+	})
+}
@@ -0,0 +1,91 @@
+// Package customstatus provides widgets for showing and setting custom
+// statuses.
+package customstatus
+
+import (
+	"context"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/components/onlineimage"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// emojiSize is the size of custom emojis in a Label.
+const emojiSize = 16 // px
+
+// Label shows a custom status. It's hidden if there's no custom status.
+type Label struct {
+	*gtk.Box
+	emoji *gtk.Label
+	image *onlineimage.Image
+	text  *gtk.Label
+}
+
+var labelCSS = cssutil.Applier("customstatus-label", `
+	.customstatus-label {
+		font-size: 0.9em;
+	}
+	.customstatus-label-text {
+		opacity: 0.75;
+	}
+`)
+
+// NewLabel creates a new Label.
+func NewLabel(ctx context.Context) *Label {
+	l := Label{}
+
+	l.emoji = gtk.NewLabel("")
+	l.emoji.AddCSSClass("customstatus-label-emoji")
+
+	l.image = onlineimage.NewImage(ctx, imgutil.HTTPProvider)
+	l.image.AddCSSClass("customstatus-label-emoji")
+	l.image.SetSizeRequest(emojiSize, emojiSize)
+
+	l.text = gtk.NewLabel("")
+	l.text.AddCSSClass("customstatus-label-text")
+	l.text.SetXAlign(0)
+	l.text.SetHExpand(true)
+	l.text.SetEllipsize(pango.EllipsizeEnd)
+	l.text.SetSingleLineMode(true)
+
+	l.Box = gtk.NewBox(gtk.OrientationHorizontal, 4)
+	l.Box.Append(l.emoji)
+	l.Box.Append(l.image)
+	l.Box.Append(l.text)
+	labelCSS(l)
+
+	l.SetActivity(nil)
+	return &l
+}
+
+// SetActivity shows the custom status activity. A nil activity hides the
+// Label.
+func (l *Label) SetActivity(activity *discord.Activity) {
+	if activity == nil || (activity.State == "" && activity.Emoji == nil) {
+		l.SetVisible(false)
+		return
+	}
+
+	l.emoji.SetVisible(false)
+	l.image.SetVisible(false)
+
+	if emoji := activity.Emoji; emoji != nil {
+		if emoji.ID.IsValid() {
+			l.image.SetFromURL(gtkcord.EmojiURL(emoji.ID.String(), emoji.Animated))
+			l.image.SetTooltipText(":" + emoji.Name + ":")
+			l.image.SetVisible(true)
+		} else if emoji.Name != "" {
+			l.emoji.SetText(emoji.Name)
+			l.emoji.SetVisible(true)
+		}
+	}
+
+	l.text.SetText(activity.State)
+	l.text.SetTooltipText(activity.State)
+	l.text.SetVisible(activity.State != "")
+	l.SetVisible(true)
+}
@@ -0,0 +1,107 @@
+package customstatus
+
+import (
+	"context"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/components/onlineimage"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// pickerEmojiSize is the size of the emojis in the custom emoji picker.
+const pickerEmojiSize = 32 // px
+
+// pickerEmoji is an emoji in the custom emoji picker.
+type pickerEmoji struct {
+	discord.Emoji
+	guild  string
+	search string // lowercase
+}
+
+var pickerCSS = cssutil.Applier("customstatus-picker", `
+	.customstatus-picker-list flowboxchild {
+		padding: 4px;
+	}
+	.customstatus-picker-empty {
+		padding: 12px;
+		opacity: 0.75;
+	}
+`)
+
+// newCustomEmojiPicker creates a popover that lists the custom emojis from the
+// user's guilds. f is called with the emoji that's picked.
+func newCustomEmojiPicker(ctx context.Context, parent gtk.Widgetter, f func(discord.Emoji)) *gtk.Popover {
+	state := gtkcord.FromContext(ctx)
+	guilds, _ := state.EmojiState.AllEmojis()
+
+	var emojis []pickerEmoji
+	for _, guild := range guilds {
+		for _, emoji := range guild.Emojis {
+			emojis = append(emojis, pickerEmoji{
+				Emoji:  emoji,
+				guild:  guild.Name,
+				search: strings.ToLower(emoji.Name + " " + guild.Name),
+			})
+		}
+	}
+
+	popover := gtk.NewPopover()
+	popover.SetParent(parent)
+	popover.SetPosition(gtk.PosBottom)
+
+	if len(emojis) == 0 {
+		empty := gtk.NewLabel(locale.Get("None of your servers have custom emojis."))
+		empty.AddCSSClass("customstatus-picker-empty")
+		popover.SetChild(empty)
+		return popover
+	}
+
+	list := gtk.NewFlowBox()
+	list.AddCSSClass("customstatus-picker-list")
+	list.SetVAlign(gtk.AlignStart)
+	list.SetMaxChildrenPerLine(8)
+	list.SetSelectionMode(gtk.SelectionNone)
+	list.SetActivateOnSingleClick(true)
+	list.SetHomogeneous(true)
+
+	for _, emoji := range emojis {
+		image := onlineimage.NewImage(ctx, imgutil.HTTPProvider)
+		image.SetSizeRequest(pickerEmojiSize, pickerEmojiSize)
+		image.SetFromURL(gtkcord.EmojiURL(emoji.ID.String(), emoji.Animated))
+		image.SetTooltipText(":" + emoji.Name + ": (" + emoji.guild + ")")
+		list.Insert(image, -1)
+	}
+
+	list.ConnectChildActivated(func(child *gtk.FlowBoxChild) {
+		f(emojis[child.Index()].Emoji)
+		popover.Popdown()
+	})
+
+	search := gtk.NewSearchEntry()
+	search.SetObjectProperty("placeholder-text", locale.Get("Search Emojis"))
+	search.ConnectSearchChanged(func() { list.InvalidateFilter() })
+
+	list.SetFilterFunc(func(child *gtk.FlowBoxChild) bool {
+		str := strings.ToLower(search.Text())
+		return str == "" || strings.Contains(emojis[child.Index()].search, str)
+	})
+
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scroll.SetSizeRequest(-1, 250)
+	scroll.SetChild(list)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 6)
+	box.AddCSSClass("customstatus-picker")
+	box.Append(search)
+	box.Append(scroll)
+	pickerCSS(box)
+
+	popover.SetChild(box)
+	return popover
+}
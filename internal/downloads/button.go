@@ -0,0 +1,218 @@
+package downloads
+
+import (
+	"context"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/dustin/go-humanize"
+)
+
+// Button is a header button that shows the list of downloads in a popover. It
+// hides itself while there are no downloads.
+type Button struct {
+	*gtk.MenuButton
+	list  *gtk.Box
+	clear *gtk.Button
+	rows  map[*Download]*downloadRow
+
+	m *Manager
+}
+
+var buttonCSS = cssutil.Applier("downloads-button", `
+	.downloads-popover {
+		min-width: 300px;
+	}
+	.downloads-row {
+		padding: 6px;
+	}
+	.downloads-row-name {
+		font-weight: bold;
+	}
+	.downloads-row-status {
+		font-size: 0.85em;
+		color: alpha(@theme_fg_color, 0.75);
+	}
+	.downloads-row progressbar {
+		margin-top: 4px;
+	}
+`)
+
+// NewButton creates a new downloads button for the manager inside the context.
+// The button lives as long as the manager does.
+func NewButton(ctx context.Context) *Button {
+	b := Button{
+		m:    FromContext(ctx),
+		rows: make(map[*Download]*downloadRow),
+	}
+
+	b.list = gtk.NewBox(gtk.OrientationVertical, 0)
+
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scroll.SetPropagateNaturalHeight(true)
+	scroll.SetMaxContentHeight(400)
+	scroll.SetChild(b.list)
+
+	b.clear = gtk.NewButtonWithLabel(locale.Get("Clear Finished"))
+	b.clear.AddCSSClass("flat")
+	b.clear.ConnectClicked(b.m.ClearFinished)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 4)
+	box.Append(scroll)
+	box.Append(b.clear)
+
+	popover := gtk.NewPopover()
+	popover.AddCSSClass("downloads-popover")
+	popover.SetChild(box)
+
+	b.MenuButton = gtk.NewMenuButton()
+	b.MenuButton.AddCSSClass("flat")
+	b.MenuButton.SetIconName("folder-download-symbolic")
+	b.MenuButton.SetTooltipText(locale.Get("Downloads"))
+	b.MenuButton.SetVAlign(gtk.AlignCenter)
+	b.MenuButton.SetPopover(popover)
+
+	// The button hides itself, so it can't only listen while it's mapped.
+	remove := b.m.OnUpdate(b.update)
+	b.ConnectDestroy(func() { remove() })
+
+	b.update()
+	buttonCSS(b)
+	return &b
+}
+
+func (b *Button) update() {
+	downloads := b.m.Downloads()
+	b.SetVisible(len(downloads) > 0)
+
+	seen := make(map[*Download]struct{}, len(downloads))
+	var finished bool
+
+	for i := len(downloads) - 1; i >= 0; i-- {
+		d := downloads[i]
+		seen[d] = struct{}{}
+
+		row, ok := b.rows[d]
+		if !ok {
+			row = newDownloadRow(d)
+			b.rows[d] = row
+			b.list.Prepend(row)
+		}
+		row.update()
+
+		if d.Status() != Downloading {
+			finished = true
+		}
+	}
+
+	for d, row := range b.rows {
+		if _, ok := seen[d]; !ok {
+			b.list.Remove(row)
+			delete(b.rows, d)
+		}
+	}
+
+	b.clear.SetSensitive(finished)
+
+	if len(downloads) == 0 {
+		b.Popdown()
+	}
+}
+
+type downloadRow struct {
+	*gtk.Box
+	status   *gtk.Label
+	progress *gtk.ProgressBar
+	cancel   *gtk.Button
+	resume   *gtk.Button
+	open     *gtk.Button
+	folder   *gtk.Button
+
+	d *Download
+}
+
+func newDownloadRow(d *Download) *downloadRow {
+	r := downloadRow{d: d}
+
+	name := gtk.NewLabel(d.Name())
+	name.AddCSSClass("downloads-row-name")
+	name.SetXAlign(0)
+	name.SetEllipsize(pango.EllipsizeMiddle)
+	name.SetTooltipText(d.Path)
+
+	r.status = gtk.NewLabel("")
+	r.status.AddCSSClass("downloads-row-status")
+	r.status.SetXAlign(0)
+	r.status.SetEllipsize(pango.EllipsizeEnd)
+
+	labels := gtk.NewBox(gtk.OrientationVertical, 0)
+	labels.SetHExpand(true)
+	labels.Append(name)
+	labels.Append(r.status)
+
+	r.cancel = newRowButton("process-stop-symbolic", locale.Get("Cancel"), d.Cancel)
+	r.resume = newRowButton("view-refresh-symbolic", locale.Get("Resume"), d.Resume)
+	r.open = newRowButton("document-open-symbolic", locale.Get("Open"), d.Open)
+	r.folder = newRowButton("folder-open-symbolic", locale.Get("Show in Folder"), d.ShowInFolder)
+
+	top := gtk.NewBox(gtk.OrientationHorizontal, 2)
+	top.Append(labels)
+	top.Append(r.cancel)
+	top.Append(r.resume)
+	top.Append(r.open)
+	top.Append(r.folder)
+
+	r.progress = gtk.NewProgressBar()
+
+	r.Box = gtk.NewBox(gtk.OrientationVertical, 0)
+	r.Box.AddCSSClass("downloads-row")
+	r.Box.Append(top)
+	r.Box.Append(r.progress)
+
+	return &r
+}
+
+func newRowButton(icon, tooltip string, f func()) *gtk.Button {
+	button := gtk.NewButtonFromIconName(icon)
+	button.AddCSSClass("flat")
+	button.SetVAlign(gtk.AlignCenter)
+	button.SetTooltipText(tooltip)
+	button.ConnectClicked(f)
+	return button
+}
+
+func (r *downloadRow) update() {
+	status := r.d.Status()
+	received, total := r.d.Progress()
+
+	r.cancel.SetVisible(status == Downloading)
+	r.resume.SetVisible(status == Failed)
+	r.open.SetVisible(status == Done)
+	r.folder.SetVisible(status == Done)
+	r.progress.SetVisible(status == Downloading)
+
+	switch status {
+	case Downloading:
+		if total > 0 {
+			r.progress.SetFraction(float64(received) / float64(total))
+			r.status.SetText(locale.Get("%s of %s",
+				humanize.Bytes(uint64(received)), humanize.Bytes(uint64(total))))
+		} else {
+			r.progress.Pulse()
+			r.status.SetText(humanize.Bytes(uint64(received)))
+		}
+		r.status.SetTooltipText("")
+	case Failed:
+		r.status.SetText(locale.Get("Failed"))
+		r.status.SetTooltipText(r.d.Err().Error())
+	case Cancelled:
+		r.status.SetText(locale.Get("Cancelled"))
+		r.status.SetTooltipText("")
+	case Done:
+		r.status.SetText(humanize.Bytes(uint64(received)))
+		r.status.SetTooltipText("")
+	}
+}
@@ -0,0 +1,479 @@
+// Package downloads manages files downloaded from Discord, such as message
+// attachments.
+package downloads
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/pkg/errors"
+)
+
+var downloadDirectory = prefs.NewString("", prefs.StringMeta{
+	Name:        "Download Folder",
+	Section:     "Downloads",
+	Description: "The folder that files are saved to. Leave empty to use the system's Downloads folder.",
+	Placeholder: "~/Downloads",
+	Validate: func(dir string) error {
+		if dir == "" {
+			return nil
+		}
+		s, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !s.IsDir() {
+			return errors.New("not a folder")
+		}
+		return nil
+	},
+})
+
+var askLocation = prefs.NewBool(false, prefs.PropMeta{
+	Name:        "Ask Where to Save Files",
+	Section:     "Downloads",
+	Description: "Ask for a location every time a file is downloaded instead of saving it to the download folder.",
+})
+
+func init() {
+	prefs.Order(downloadDirectory, askLocation)
+}
+
+func defaultDirectory() string {
+	if dir := glib.GetUserSpecialDir(glib.UserDirectoryDownload); dir != "" {
+		return dir
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, "Downloads")
+	}
+	return "."
+}
+
+// Directory returns the folder that downloads are saved to by default.
+func Directory() string {
+	if dir := downloadDirectory.Value(); dir != "" {
+		return dir
+	}
+	return defaultDirectory()
+}
+
+type ctxKey uint8
+
+const (
+	_ ctxKey = iota
+	managerKey
+)
+
+// WithManager injects the given manager into a new context.
+func WithManager(ctx context.Context, m *Manager) context.Context {
+	return context.WithValue(ctx, managerKey, m)
+}
+
+// FromContext returns the manager inside the context, or nil if there's none.
+func FromContext(ctx context.Context) *Manager {
+	m, _ := ctx.Value(managerKey).(*Manager)
+	return m
+}
+
+// Manager keeps track of all downloads in the session. Manager must only be
+// used from the main thread.
+type Manager struct {
+	ctx       context.Context
+	downloads []*Download // newest first
+	handlers  map[int]func()
+	handlerID int
+}
+
+// NewManager creates a new download manager. All downloads are stopped once
+// the context is cancelled.
+func NewManager(ctx context.Context) *Manager {
+	return &Manager{
+		ctx:      ctx,
+		handlers: make(map[int]func()),
+	}
+}
+
+// Downloads returns all downloads from newest to oldest. The returned slice
+// must not be modified.
+func (m *Manager) Downloads() []*Download {
+	return m.downloads
+}
+
+// OnUpdate adds a callback that's called every time a download is added,
+// removed or makes progress. Call the returned function to remove the
+// callback.
+func (m *Manager) OnUpdate(f func()) (remove func()) {
+	id := m.handlerID
+	m.handlerID++
+	m.handlers[id] = f
+	return func() { delete(m.handlers, id) }
+}
+
+func (m *Manager) invalidate() {
+	for _, f := range m.handlers {
+		f()
+	}
+}
+
+// Download downloads the file at the given URL. Depending on the user's
+// preferences, the file is either saved into the download folder or the user
+// is asked for a location first.
+func (m *Manager) Download(url, name string) {
+	if !askLocation.Value() {
+		f, err := createUnique(Directory(), name)
+		if err != nil {
+			app.Error(m.ctx, errors.Wrap(err, "cannot download file"))
+			return
+		}
+		f.Close()
+
+		d := m.DownloadTo(url, f.Name())
+		d.created = true
+		return
+	}
+
+	chooser := gtk.NewFileChooserNative(
+		locale.Get("Save File"),
+		app.GTKWindowFromContext(m.ctx),
+		gtk.FileChooserActionSave,
+		locale.Get("Save"), locale.Get("Cancel"),
+	)
+	chooser.SetCurrentName(name)
+	chooser.SetCurrentFolder(gio.NewFileForPath(Directory()))
+	chooser.ConnectResponse(func(resp int) {
+		if resp == int(gtk.ResponseAccept) {
+			m.DownloadTo(url, chooser.File().Path())
+		}
+	})
+	chooser.Show()
+}
+
+// DownloadTo downloads the file at the given URL into the given path.
+func (m *Manager) DownloadTo(url, path string) *Download {
+	d := &Download{
+		URL:  url,
+		Path: path,
+		m:    m,
+	}
+
+	m.downloads = append([]*Download{d}, m.downloads...)
+	d.start()
+
+	return d
+}
+
+// ClearFinished removes all downloads that aren't running anymore from the
+// list.
+func (m *Manager) ClearFinished() {
+	downloads := m.downloads[:0]
+	for _, d := range m.downloads {
+		if d.status == Downloading {
+			downloads = append(downloads, d)
+		}
+	}
+	m.downloads = downloads
+	m.invalidate()
+}
+
+// createUnique creates an empty file for the file name inside dir, numbering
+// the name if it's taken. The file is created exclusively, so downloads of
+// files with the same name never end up with the same path.
+func createUnique(dir, name string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "cannot create folder")
+	}
+
+	name = filepath.Base(name)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	path := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		// Don't take the path of a download that was interrupted.
+		if !fileExists(path + partSuffix) {
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+			if err == nil {
+				return f, nil
+			}
+			if !os.IsExist(err) {
+				return nil, err
+			}
+		}
+
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Status is the status of a download.
+type Status uint8
+
+const (
+	// Downloading means that the file is being downloaded.
+	Downloading Status = iota
+	// Failed means that the download was interrupted. It can be resumed.
+	Failed
+	// Cancelled means that the user cancelled the download.
+	Cancelled
+	// Done means that the file was downloaded.
+	Done
+)
+
+// partSuffix is the suffix of the file that's being downloaded into. It's
+// renamed once the download is done.
+const partSuffix = ".part"
+
+// progressInterval is the minimum duration between progress updates.
+const progressInterval = 100 * time.Millisecond
+
+// Download is a single file download.
+type Download struct {
+	URL  string
+	Path string
+
+	m        *Manager
+	cancel   context.CancelFunc
+	created  bool // true if Path was created empty for the download
+	err      error
+	received int64
+	total    int64
+	status   Status
+}
+
+// Name returns the file name of the download.
+func (d *Download) Name() string {
+	return filepath.Base(d.Path)
+}
+
+// Status returns the download's status.
+func (d *Download) Status() Status {
+	return d.status
+}
+
+// Err returns the error that interrupted the download, if any.
+func (d *Download) Err() error {
+	return d.err
+}
+
+// Progress returns the number of bytes received so far and the total size of
+// the file. The total is -1 if it's unknown.
+func (d *Download) Progress() (received, total int64) {
+	return d.received, d.total
+}
+
+// Cancel stops the download and deletes the partially downloaded file.
+func (d *Download) Cancel() {
+	if d.status != Downloading {
+		return
+	}
+
+	d.status = Cancelled
+	d.cancel()
+	d.m.invalidate()
+}
+
+// Resume restarts an interrupted download. If the server supports it, then the
+// download continues from where it stopped.
+func (d *Download) Resume() {
+	if d.status != Failed {
+		return
+	}
+	d.start()
+}
+
+// Open opens the downloaded file using the default application.
+func (d *Download) Open() {
+	app.OpenURI(d.m.ctx, gio.NewFileForPath(d.Path).URI())
+}
+
+// ShowInFolder shows the downloaded file in the file manager. If the file
+// manager doesn't support selecting files, then the folder is opened instead.
+func (d *Download) ShowInFolder() {
+	uri := gio.NewFileForPath(d.Path).URI()
+	dir := gio.NewFileForPath(filepath.Dir(d.Path)).URI()
+
+	ctx := d.m.ctx
+	go func() {
+		conn, err := gio.BusGetSync(ctx, gio.BusTypeSession)
+		if err == nil {
+			_, err = conn.CallSync(
+				ctx,
+				"org.freedesktop.FileManager1",
+				"/org/freedesktop/FileManager1",
+				"org.freedesktop.FileManager1",
+				"ShowItems",
+				glib.NewVariantTuple([]*glib.Variant{
+					glib.NewVariantStrv([]string{uri}),
+					glib.NewVariantString(""),
+				}),
+				nil, gio.DBusCallFlagsNone, -1,
+			)
+		}
+		if err != nil {
+			log.Println("downloads: cannot show file in file manager:", err)
+			glib.IdleAdd(func() { app.OpenURI(ctx, dir) })
+		}
+	}()
+}
+
+func (d *Download) start() {
+	ctx, cancel := context.WithCancel(d.m.ctx)
+	d.cancel = cancel
+	d.status = Downloading
+	d.err = nil
+	d.m.invalidate()
+
+	go func() {
+		err := d.transfer(ctx, true)
+		glib.IdleAdd(func() { d.finish(err) })
+	}()
+}
+
+func (d *Download) finish(err error) {
+	d.cancel()
+
+	switch {
+	case d.status == Cancelled:
+		go func(path string, created bool) {
+			os.Remove(path + partSuffix)
+			// Only remove the empty file, in case the download finished
+			// just before it was cancelled.
+			if s, err := os.Stat(path); err == nil && created && s.Size() == 0 {
+				os.Remove(path)
+			}
+		}(d.Path, d.created)
+	case err != nil:
+		d.status = Failed
+		d.err = err
+		log.Printf("downloads: cannot download %q: %v", d.URL, err)
+	default:
+		d.status = Done
+	}
+
+	d.m.invalidate()
+}
+
+func (d *Download) setProgress(received, total int64) {
+	glib.IdleAdd(func() {
+		if d.status == Downloading {
+			d.received = received
+			d.total = total
+			d.m.invalidate()
+		}
+	})
+}
+
+// transfer downloads the file into the part file, continuing from its current
+// size if possible. It's called in a goroutine.
+func (d *Download) transfer(ctx context.Context, allowResume bool) error {
+	part := d.Path + partSuffix
+
+	var offset int64
+	if allowResume {
+		if s, err := os.Stat(part); err == nil {
+			offset = s.Size()
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", d.URL, nil)
+	if err != nil {
+		return errors.Wrap(err, "invalid URL")
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The part file doesn't match the file anymore, so start over.
+		resp.Body.Close()
+		return d.transfer(ctx, false)
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		// The server doesn't support ranges, so start over.
+		offset = 0
+		flags |= os.O_TRUNC
+	default:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.Path), 0755); err != nil {
+		return errors.Wrap(err, "cannot create folder")
+	}
+
+	f, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return errors.Wrap(err, "cannot create file")
+	}
+
+	w := progressWriter{
+		w:        f,
+		received: offset,
+		total:    total,
+		report:   d.setProgress,
+	}
+	w.report(w.received, w.total)
+
+	_, err = io.Copy(&w, resp.Body)
+	w.report(w.received, w.total)
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "cannot write file")
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot download")
+	}
+
+	return errors.Wrap(os.Rename(part, d.Path), "cannot move downloaded file")
+}
+
+type progressWriter struct {
+	w        io.Writer
+	received int64
+	total    int64
+	last     time.Time
+	report   func(received, total int64)
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.received += int64(n)
+
+	if now := time.Now(); now.Sub(w.last) >= progressInterval {
+		w.last = now
+		w.report(w.received, w.total)
+	}
+
+	return n, err
+}
@@ -1,8 +1,14 @@
 package gtkcord
 
-import "github.com/thekrafter/arikawa-spacebar/v3/discord"
+import (
+	"time"
 
-// IPC commands go here.
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+)
+
+// IPC commands go here. Commands with an action ID are also available as
+// actions on the application, while the rest are only available through the
+// D-Bus interface in package ipc.
 
 // OpenChannelCommand is the data type for a command sent over DBus to open a
 // message channel. Its action ID is app.open-channel.
@@ -10,3 +16,58 @@ type OpenChannelCommand struct {
 	ChannelID discord.ChannelID
 	MessageID discord.MessageID // optional, used to highlight message
 }
+
+// MarkReadCommand is the data type for a command to mark a channel as read up
+// to the given message. Its action ID is app.mark-read.
+type MarkReadCommand struct {
+	ChannelID discord.ChannelID
+	MessageID discord.MessageID
+}
+
+// ReplyCommand is the data type for a command to show a quick reply window
+// for a message. Its action ID is app.quick-reply.
+type ReplyCommand struct {
+	ChannelID discord.ChannelID
+	MessageID discord.MessageID
+}
+
+// SendMessageCommand is the data type for a command to send a message to a
+// channel.
+type SendMessageCommand struct {
+	ChannelID discord.ChannelID
+	Content   string
+}
+
+// SetStatusCommand is the data type for a command to set the user's status.
+type SetStatusCommand struct {
+	Status discord.Status
+}
+
+// SetCustomStatusCommand is the data type for a command to set the user's
+// custom status. An empty Text and Emoji clears the custom status.
+type SetCustomStatusCommand struct {
+	Text string
+	// Emoji is either a Unicode emoji or a custom emoji in the "name:id"
+	// format.
+	Emoji string
+	// ExpiresAt is the time the custom status is cleared. The zero value
+	// never clears it.
+	ExpiresAt time.Time
+}
+
+// GuildInfo describes a guild in the result of listing guilds.
+type GuildInfo struct {
+	ID   discord.GuildID `json:"id"`
+	Name string          `json:"name"`
+	// Unread is true if the guild has unread channels that aren't muted.
+	Unread   bool `json:"unread"`
+	Mentions int  `json:"mentions"`
+}
+
+// ChannelInfo describes a channel in the result of listing channels.
+type ChannelInfo struct {
+	ID       discord.ChannelID `json:"id"`
+	Name     string            `json:"name"`
+	Unread   bool              `json:"unread"`
+	Mentions int               `json:"mentions"`
+}
@@ -0,0 +1,79 @@
+package gtkcord
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/api"
+)
+
+var instanceHost string
+
+// InstanceHost returns the host of the instance that requests go to.
+func InstanceHost() string {
+	if instanceHost != "" {
+		return instanceHost
+	}
+
+	u, err := url.Parse(api.BaseEndpoint)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// SetInstance makes all requests to the default API host go to the instance
+// at the given URL instead. Only the scheme and host of the URL are used. It
+// must be called before any request is made.
+func SetInstance(rawURL string) error {
+	instance, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Wrap(err, "invalid instance URL")
+	}
+
+	if instance.Scheme != "http" && instance.Scheme != "https" {
+		return errors.New("instance URL must start with http:// or https://")
+	}
+
+	if instance.Host == "" {
+		return errors.New("instance URL has no host")
+	}
+
+	base, err := url.Parse(api.BaseEndpoint)
+	if err != nil {
+		return errors.Wrap(err, "invalid default endpoint")
+	}
+
+	instanceHost = strings.ToLower(instance.Hostname())
+
+	// The API and the Gateway URL lookup both use clients without their own
+	// transport, so overriding the default transport covers both.
+	http.DefaultTransport = instanceTransport{
+		from: base.Host,
+		to:   instance,
+		rt:   http.DefaultTransport,
+	}
+
+	return nil
+}
+
+type instanceTransport struct {
+	from string
+	to   *url.URL
+	rt   http.RoundTripper
+}
+
+func (t instanceTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Host != t.from {
+		return t.rt.RoundTrip(r)
+	}
+
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.to.Scheme
+	r.URL.Host = t.to.Host
+	r.Host = ""
+
+	return t.rt.RoundTrip(r)
+}
@@ -0,0 +1,127 @@
+package gtkcord
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+)
+
+// URISchemes are the URI schemes that gtkcord4 handles, for example
+// gtkcord4://channels/<guild>/<channel>/<message>.
+var URISchemes = []string{"gtkcord4", "spacebar"}
+
+// discordHosts are the hosts of the Discord web client. Links to them are
+// opened in gtkcord4 as well, since instances use the same link format.
+var discordHosts = []string{
+	"discord.com",
+	"discordapp.com",
+	"ptb.discord.com",
+	"canary.discord.com",
+}
+
+// ParseChannelLink parses either a channel ID or a link to a channel or a
+// message, such as https://discord.com/channels/<guild>/<channel>/<message>.
+// The guild is "@me" for direct messages. Links to any host are accepted.
+func ParseChannelLink(str string) (OpenChannelCommand, error) {
+	if id, err := discord.ParseSnowflake(str); err == nil {
+		return OpenChannelCommand{ChannelID: discord.ChannelID(id)}, nil
+	}
+
+	u, err := url.Parse(str)
+	if err != nil {
+		return OpenChannelCommand{}, errors.Wrap(err, "invalid link")
+	}
+
+	cmd, ok := parseLinkPath(u)
+	if !ok {
+		return cmd, errors.Errorf("%q is not a channel ID or link", str)
+	}
+
+	return cmd, nil
+}
+
+// ParseMessageLink parses a link to a channel or a message if it points to
+// the instance, to Discord or uses one of the URISchemes. Other links are
+// left to the browser.
+func ParseMessageLink(str string) (OpenChannelCommand, bool) {
+	u, err := url.Parse(str)
+	if err != nil || !isInternalLink(u) {
+		return OpenChannelCommand{}, false
+	}
+	return parseLinkPath(u)
+}
+
+func isInternalLink(u *url.URL) bool {
+	scheme := strings.ToLower(u.Scheme)
+	for _, s := range URISchemes {
+		if scheme == s {
+			return true
+		}
+	}
+
+	if scheme != "http" && scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	// Instances commonly serve their API from an api. subdomain and the web
+	// client from the domain itself.
+	instance := InstanceHost()
+	if host == instance || "api."+host == instance {
+		return true
+	}
+
+	for _, h := range discordHosts {
+		if host == h {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseLinkPath parses the channels/<guild>/<channel>[/<message>] part of the
+// link.
+func parseLinkPath(u *url.URL) (OpenChannelCommand, bool) {
+	var cmd OpenChannelCommand
+
+	path := u.Path
+	switch {
+	case u.Opaque != "":
+		// gtkcord4:channels/...
+		path = u.Opaque
+	case u.Scheme != "http" && u.Scheme != "https":
+		// gtkcord4://channels/... puts the first part into the host.
+		path = u.Host + u.Path
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] != "channels" {
+		return cmd, false
+	}
+
+	if parts[1] != "@me" {
+		if _, err := discord.ParseSnowflake(parts[1]); err != nil {
+			return cmd, false
+		}
+	}
+
+	chID, err := discord.ParseSnowflake(parts[2])
+	if err != nil || !chID.IsValid() {
+		return cmd, false
+	}
+	cmd.ChannelID = discord.ChannelID(chID)
+
+	if len(parts) == 4 {
+		msgID, err := discord.ParseSnowflake(parts[3])
+		if err != nil || !msgID.IsValid() {
+			return cmd, false
+		}
+		cmd.MessageID = discord.MessageID(msgID)
+	}
+
+	return cmd, true
+}
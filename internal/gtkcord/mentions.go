@@ -0,0 +1,46 @@
+package gtkcord
+
+import (
+	"github.com/thekrafter/arikawa-spacebar/v3/api"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/utils/httputil"
+)
+
+// RecentMentionsData is the query used to fetch the user's recent mentions.
+type RecentMentionsData struct {
+	// Before fetches mentions before this message ID.
+	Before discord.MessageID `schema:"before,omitempty"`
+	// Limit is the maximum number of messages to fetch. It is clamped to
+	// 1-100.
+	Limit uint `schema:"limit"`
+	// Roles includes messages that mention the user's roles.
+	Roles bool `schema:"roles"`
+	// Everyone includes messages that mention @everyone and @here.
+	Everyone bool `schema:"everyone"`
+	// GuildID only includes messages from this guild, if valid.
+	GuildID discord.GuildID `schema:"guild_id,omitempty"`
+}
+
+// RecentMentions fetches the messages that recently mentioned the current
+// user. The messages are ordered from newest to oldest.
+func (s *State) RecentMentions(data RecentMentionsData) ([]discord.Message, error) {
+	switch {
+	case data.Limit == 0:
+		data.Limit = 25
+	case data.Limit > 100:
+		data.Limit = 100
+	}
+
+	var msgs []discord.Message
+	return msgs, s.RequestJSON(
+		&msgs, "GET",
+		api.EndpointMe+"/mentions",
+		httputil.WithSchema(s, data),
+	)
+}
+
+// DeleteRecentMention removes the message with the given ID from the user's
+// recent mentions.
+func (s *State) DeleteRecentMention(msgID discord.MessageID) error {
+	return s.FastRequest("DELETE", api.EndpointMe+"/mentions/"+msgID.String())
+}
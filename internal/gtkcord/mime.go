@@ -0,0 +1,90 @@
+package gtkcord
+
+import (
+	"path"
+	"strings"
+)
+
+// textMIMETypes are the non-text/* MIME types that are still plain text.
+var textMIMETypes = map[string]struct{}{
+	"application/json":       {},
+	"application/xml":        {},
+	"application/javascript": {},
+	"application/x-sh":       {},
+	"application/x-yaml":     {},
+	"application/toml":       {},
+	"application/sql":        {},
+	// How is utf8_string a valid MIME type? GTK, what the fuck?
+	"utf8_string": {},
+}
+
+// textExtensions are the file extensions of plain text files. They're used
+// when the MIME type doesn't say anything, which instances do for files they
+// don't know.
+var textExtensions = map[string]struct{}{
+	".txt":   {},
+	".log":   {},
+	".md":    {},
+	".json":  {},
+	".xml":   {},
+	".yml":   {},
+	".yaml":  {},
+	".toml":  {},
+	".ini":   {},
+	".conf":  {},
+	".cfg":   {},
+	".csv":   {},
+	".diff":  {},
+	".patch": {},
+	".go":    {},
+	".c":     {},
+	".h":     {},
+	".cpp":   {},
+	".hpp":   {},
+	".cs":    {},
+	".java":  {},
+	".kt":    {},
+	".rs":    {},
+	".py":    {},
+	".rb":    {},
+	".js":    {},
+	".ts":    {},
+	".lua":   {},
+	".sh":    {},
+	".nix":   {},
+	".sql":   {},
+	".html":  {},
+	".css":   {},
+}
+
+// MIMEIsText returns true if the MIME type is plain text. Parameters such as
+// the charset are ignored.
+func MIMEIsText(mime string) bool {
+	mime, _, _ = strings.Cut(mime, ";")
+	mime = strings.TrimSpace(mime)
+
+	if strings.HasPrefix(mime, "text") {
+		return true
+	}
+
+	_, ok := textMIMETypes[mime]
+	return ok
+}
+
+// FileIsText returns true if the file with the given MIME type and name is
+// plain text. The file extension is only checked if the MIME type is empty or
+// application/octet-stream.
+func FileIsText(mime, filename string) bool {
+	if MIMEIsText(mime) {
+		return true
+	}
+
+	mime, _, _ = strings.Cut(mime, ";")
+	switch strings.TrimSpace(mime) {
+	case "", "application/octet-stream":
+		_, ok := textExtensions[strings.ToLower(path.Ext(filename))]
+		return ok
+	default:
+		return false
+	}
+}
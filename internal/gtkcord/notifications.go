@@ -0,0 +1,222 @@
+package gtkcord
+
+import (
+	"time"
+
+	"github.com/thekrafter/arikawa-spacebar/v3/api"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/thekrafter/arikawa-spacebar/v3/utils/httputil"
+)
+
+// MuteDurations are the durations that a guild or channel can be muted for. 0
+// mutes until the user unmutes it.
+var MuteDurations = []time.Duration{
+	15 * time.Minute,
+	time.Hour,
+	3 * time.Hour,
+	8 * time.Hour,
+	24 * time.Hour,
+	0,
+}
+
+// NewMuteConfig creates a mute config that mutes for the given duration. 0
+// mutes until the user unmutes it.
+func NewMuteConfig(d time.Duration) *gateway.UserMuteConfig {
+	if d == 0 {
+		return &gateway.UserMuteConfig{SelectedTimeWindow: -1}
+	}
+
+	return &gateway.UserMuteConfig{
+		SelectedTimeWindow: int(d / time.Second),
+		EndTime:            discord.NewTimestamp(time.Now().Add(d)),
+	}
+}
+
+// MuteIsActive returns true if the mute is still in effect.
+func MuteIsActive(muted bool, cfg *gateway.UserMuteConfig) bool {
+	if !muted {
+		return false
+	}
+	// No config or no time window means it's muted until unmuted.
+	if cfg == nil || cfg.SelectedTimeWindow == -1 || !cfg.EndTime.IsValid() {
+		return true
+	}
+	return cfg.EndTime.Time().After(time.Now())
+}
+
+// GuildSettingsData is the data used to modify the user's notification
+// settings of a guild. Nil fields are left unchanged.
+type GuildSettingsData struct {
+	Muted            *bool                     `json:"muted,omitempty"`
+	MuteConfig       *gateway.UserMuteConfig   `json:"mute_config,omitempty"`
+	Notifications    *gateway.UserNotification `json:"message_notifications,omitempty"`
+	SuppressEveryone *bool                     `json:"suppress_everyone,omitempty"`
+	SuppressRoles    *bool                     `json:"suppress_roles,omitempty"`
+
+	ChannelOverrides map[discord.ChannelID]ChannelOverrideData `json:"channel_overrides,omitempty"`
+}
+
+// ChannelOverrideData is the data used to modify the user's notification
+// settings of a channel or category. Nil fields are left unchanged.
+type ChannelOverrideData struct {
+	Muted         *bool                     `json:"muted,omitempty"`
+	MuteConfig    *gateway.UserMuteConfig   `json:"mute_config,omitempty"`
+	Notifications *gateway.UserNotification `json:"message_notifications,omitempty"`
+}
+
+// ModifyGuildSettings modifies the user's notification settings of the guild.
+// An invalid guild ID modifies the settings of direct messages. The new
+// settings are dispatched as a UserGuildSettingsUpdateEvent so that the rest of
+// the state picks them up right away.
+func (s *State) ModifyGuildSettings(guildID discord.GuildID, data GuildSettingsData) error {
+	id := "@me"
+	if guildID.IsValid() {
+		id = guildID.String()
+	}
+
+	var settings gateway.UserGuildSetting
+	err := s.RequestJSON(
+		&settings, "PATCH",
+		api.EndpointMe+"/guilds/"+id+"/settings",
+		httputil.WithJSONBody(data),
+	)
+	if err != nil {
+		return err
+	}
+
+	s.State.State.Call(&gateway.UserGuildSettingsUpdateEvent{
+		UserGuildSetting: settings,
+	})
+
+	return nil
+}
+
+// ChannelOverride returns the user's explicit notification settings of the
+// channel or category, if there are any.
+func (s *State) ChannelOverride(guildID discord.GuildID, chID discord.ChannelID) (gateway.UserChannelOverride, bool) {
+	settings := s.MutedState.GuildSettings(guildID)
+	for _, override := range settings.ChannelOverrides {
+		if override.ChannelID == chID {
+			return override, true
+		}
+	}
+	return gateway.UserChannelOverride{ChannelID: chID, Notifications: gateway.GuildDefaults}, false
+}
+
+// ChannelNotifications returns the notification level that applies to the
+// channel. Channel overrides take precedence over the category's, which take
+// precedence over the guild's.
+func (s *State) ChannelNotifications(ch *discord.Channel) gateway.UserNotification {
+	if o, ok := s.ChannelOverride(ch.GuildID, ch.ID); ok && o.Notifications != gateway.GuildDefaults {
+		return o.Notifications
+	}
+
+	if ch.ParentID.IsValid() {
+		if o, ok := s.ChannelOverride(ch.GuildID, ch.ParentID); ok && o.Notifications != gateway.GuildDefaults {
+			return o.Notifications
+		}
+	}
+
+	if !ch.GuildID.IsValid() {
+		return gateway.AllNotifications
+	}
+
+	notifications := s.MutedState.GuildSettings(ch.GuildID).Notifications
+	if notifications == gateway.GuildDefaults {
+		return s.GuildDefaultNotifications(ch.GuildID)
+	}
+	return notifications
+}
+
+// GuildDefaultNotifications returns the notification level that the guild
+// uses for members who haven't chosen one.
+func (s *State) GuildDefaultNotifications(guildID discord.GuildID) gateway.UserNotification {
+	guild, err := s.Cabinet.Guild(guildID)
+	if err == nil && guild.Notification == discord.OnlyMentions {
+		return gateway.OnlyMentions
+	}
+	return gateway.AllNotifications
+}
+
+// channelMuted returns true if the channel, its category or its guild is
+// muted.
+func (s *State) channelMuted(ch *discord.Channel) bool {
+	for _, id := range []discord.ChannelID{ch.ID, ch.ParentID} {
+		if !id.IsValid() {
+			continue
+		}
+		if o, ok := s.ChannelOverride(ch.GuildID, id); ok && MuteIsActive(o.Muted, o.MuteConfig) {
+			return true
+		}
+	}
+
+	if ch.GuildID.IsValid() {
+		guild := s.MutedState.GuildSettings(ch.GuildID)
+		return MuteIsActive(guild.Muted, guild.MuteConfig)
+	}
+
+	return false
+}
+
+// MessageNotifies returns true if the message should send a notification
+// according to the user's notification settings.
+func (s *State) MessageNotifies(msg *discord.Message) bool {
+	me, _ := s.Cabinet.Me()
+	if me == nil || msg.Author.ID == me.ID || s.UserIsBlocked(msg.Author.ID) {
+		return false
+	}
+
+	ch, err := s.Cabinet.Channel(msg.ChannelID)
+	if err != nil {
+		return false
+	}
+
+	notifications := s.ChannelNotifications(ch)
+	if notifications == gateway.NoNotifications {
+		return false
+	}
+
+	// Direct messages always notify unless they're muted.
+	if !ch.GuildID.IsValid() {
+		return !s.channelMuted(ch)
+	}
+
+	if s.messageMentionsMe(msg, me.ID) {
+		return true
+	}
+
+	return notifications == gateway.AllNotifications && !s.channelMuted(ch)
+}
+
+// messageMentionsMe returns true if the message mentions the user directly,
+// through @everyone or through one of their roles, honoring the guild's
+// suppression settings.
+func (s *State) messageMentionsMe(msg *discord.Message, myID discord.UserID) bool {
+	for _, user := range msg.Mentions {
+		if user.ID == myID {
+			return true
+		}
+	}
+
+	settings := s.MutedState.GuildSettings(msg.GuildID)
+
+	if msg.MentionEveryone && !settings.SuppressEveryone {
+		return true
+	}
+
+	if len(msg.MentionRoleIDs) > 0 && !settings.SuppressRoles {
+		member, _ := s.Cabinet.Member(msg.GuildID, myID)
+		if member != nil {
+			for _, roleID := range msg.MentionRoleIDs {
+				for _, myRoleID := range member.RoleIDs {
+					if roleID == myRoleID {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	return false
+}
@@ -71,6 +71,8 @@ const (
 // State extends the Discord state controller.
 type State struct {
 	*ningen.State
+	presence *presenceState
+	limits   *limitsCache
 }
 
 // FromContext gets the Discord state controller from the given context.
@@ -111,9 +113,15 @@ func Wrap(state *state.State) *State {
 
 	// dumpRawEvents(state)
 
-	return &State{
-		State: ningen.FromState(state),
+	s := &State{
+		State:    ningen.FromState(state),
+		presence: &presenceState{},
+		limits:   &limitsCache{},
 	}
+	s.bindCustomStatus()
+	s.bindGuildStickers()
+
+	return s
 }
 
 var rawEventsOnce sync.Once
@@ -158,10 +166,26 @@ func InjectState(ctx context.Context, state *State) context.Context {
 // WithContext creates a copy of State with a new context.
 func (s *State) WithContext(ctx context.Context) *State {
 	return &State{
-		State: s.State.WithContext(ctx),
+		State:    s.State.WithContext(ctx),
+		presence: s.presence,
+		limits:   s.limits,
 	}
 }
 
+// Close closes the session. The custom status is no longer cleared once it
+// expires.
+func (s *State) Close() error {
+	s.presence.close()
+	return s.State.Close()
+}
+
+// EndSession stops what the State does on its own for the session, such as
+// clearing the custom status once it expires, without closing the Gateway. It
+// is called once the user is logged out.
+func (s *State) EndSession() {
+	s.presence.close()
+}
+
 // BindHandler is similar to BindWidgetHandler, except the lifetime of the
 // handler is bound to the context.
 func (s *State) BindHandler(ctx gtkutil.Cancellable, fn func(gateway.Event), filters ...gateway.Event) {
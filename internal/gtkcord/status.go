@@ -0,0 +1,546 @@
+package gtkcord
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/api"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/thekrafter/arikawa-spacebar/v3/utils/httputil"
+)
+
+// CustomStatus returns the user's custom status, or nil if they have none.
+func (s *State) CustomStatus() *discord.Activity {
+	me, _ := s.Cabinet.Me()
+	if me == nil {
+		return nil
+	}
+
+	return s.UserCustomStatus(0, me.ID)
+}
+
+// UserPresence returns the presence of the user in the guild. If the guild
+// doesn't have it, the presence outside of guilds is used, which friends and
+// the user themselves have. The guild ID may be 0.
+func (s *State) UserPresence(guildID discord.GuildID, userID discord.UserID) *discord.Presence {
+	p, _ := s.PresenceStore.Presence(guildID, userID)
+	if p == nil && guildID.IsValid() {
+		p, _ = s.PresenceStore.Presence(0, userID)
+	}
+	return p
+}
+
+// UserCustomStatus returns the custom status of the user in the guild, or nil
+// if they have none. The presence is looked up like UserPresence.
+func (s *State) UserCustomStatus(guildID discord.GuildID, userID discord.UserID) *discord.Activity {
+	p := s.UserPresence(guildID, userID)
+	if p == nil {
+		return nil
+	}
+
+	for i, activity := range p.Activities {
+		if activity.Type == discord.CustomActivity {
+			return &p.Activities[i]
+		}
+	}
+
+	return nil
+}
+
+// ownActivities returns a copy of the user's current activities.
+func (s *State) ownActivities() []discord.Activity {
+	activities := []discord.Activity{}
+
+	if me, _ := s.Cabinet.Me(); me != nil {
+		if p, _ := s.PresenceStore.Presence(0, me.ID); p != nil {
+			activities = append(activities, p.Activities...)
+		}
+	}
+
+	return activities
+}
+
+// customStatusActivity returns the activity that the Gateway shows for the
+// custom status from the user settings.
+func customStatusActivity(custom *gateway.CustomUserStatus) discord.Activity {
+	activity := discord.Activity{
+		Name:  "Custom Status",
+		Type:  discord.CustomActivity,
+		State: custom.Text,
+	}
+
+	if custom.EmojiName != "" {
+		activity.Emoji = &discord.Emoji{
+			ID:   custom.EmojiID,
+			Name: custom.EmojiName,
+		}
+	}
+
+	return activity
+}
+
+// CustomStatusExpired returns true if the custom status has an expiry time
+// that has passed.
+func CustomStatusExpired(custom *gateway.CustomUserStatus) bool {
+	return custom.ExpiresAt.IsValid() && !custom.ExpiresAt.Time().After(time.Now())
+}
+
+// SetCustomStatus sets the user's custom status while keeping their status
+// and other activities. If custom is nil, the custom status is cleared. The
+// custom status is saved in the user settings, which clear it once it expires.
+func (s *State) SetCustomStatus(custom *gateway.CustomUserStatus) error {
+	status := s.Status()
+	if status == discord.OfflineStatus || status == discord.UnknownStatus {
+		status = discord.OnlineStatus
+	}
+
+	activities := []discord.Activity{}
+	for _, activity := range s.ownActivities() {
+		if activity.Type != discord.CustomActivity {
+			activities = append(activities, activity)
+		}
+	}
+
+	if custom != nil {
+		activities = append(activities, customStatusActivity(custom))
+	}
+
+	afk, since := s.presenceAFK()
+
+	err := s.Gateway().Send(s.Context(), &gateway.UpdatePresenceCommand{
+		Status:     status,
+		AFK:        afk,
+		Since:      since,
+		Activities: activities,
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot update gateway")
+	}
+
+	err = s.FastRequest("PATCH", api.EndpointMe+"/settings", httputil.WithJSONBody(map[string]interface{}{
+		"custom_status": custom,
+	}))
+	if err != nil {
+		return errors.Wrap(err, "cannot update user settings API")
+	}
+
+	s.setSettingsCustomStatus(custom)
+	s.putCustomStatus(custom, true)
+	return nil
+}
+
+// presenceState is the part of the user's presence that's kept on top of
+// ningen. It's shared by the copies of State made by WithContext.
+type presenceState struct {
+	sync.Mutex
+	// custom is the custom status from the user settings. It's kept so that
+	// it can be put back into the user's presence, and so that it can be
+	// cleared once it expires in case the instance doesn't do that.
+	custom *gateway.CustomUserStatus
+	expiry *time.Timer
+	// afkSince is when the user went AFK in Unix milliseconds, or 0 if
+	// they're not AFK. It's kept so that other presence updates don't reset
+	// it.
+	afkSince int64
+	// auto are the automatic statuses in effect. user is the status that the
+	// user chose before the first one, and set is the status that was sent
+	// for them last.
+	auto map[AutoStatus]autoStatus
+	user discord.Status
+	set  discord.Status
+	// closed is true once the session is closed.
+	closed bool
+}
+
+func (p *presenceState) close() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.closed = true
+	if p.expiry != nil {
+		p.expiry.Stop()
+		p.expiry = nil
+	}
+}
+
+// settingsCustomStatus returns the custom status from the user settings.
+func (s *State) settingsCustomStatus() *gateway.CustomUserStatus {
+	s.presence.Lock()
+	defer s.presence.Unlock()
+
+	return s.presence.custom
+}
+
+// setSettingsCustomStatus sets the custom status from the user settings. nil
+// clears it.
+func (s *State) setSettingsCustomStatus(custom *gateway.CustomUserStatus) {
+	p := s.presence
+	p.Lock()
+	defer p.Unlock()
+
+	p.custom = custom
+
+	if p.expiry != nil {
+		p.expiry.Stop()
+		p.expiry = nil
+	}
+
+	if p.closed {
+		return
+	}
+
+	if custom != nil && custom.ExpiresAt.IsValid() && !CustomStatusExpired(custom) {
+		p.expiry = time.AfterFunc(time.Until(custom.ExpiresAt.Time()), func() {
+			p.Lock()
+			closed := p.closed
+			p.Unlock()
+
+			if closed {
+				return
+			}
+
+			if err := s.SetCustomStatus(nil); err != nil {
+				log.Println("cannot clear expired custom status:", err)
+			}
+		})
+	}
+}
+
+// bindCustomStatus keeps the custom status from the user settings in the
+// user's presence. ningen adds it as a game named after the text, so it's
+// never found by CustomStatus otherwise, and it's missing from the presence
+// built from the sessions if the instance doesn't add it there.
+func (s *State) bindCustomStatus() {
+	s.AddSyncHandler(func(ev gateway.Event) {
+		switch ev := ev.(type) {
+		case *gateway.ReadyEvent:
+			if ev.UserSettings != nil {
+				s.setSettingsCustomStatus(ev.UserSettings.CustomStatus)
+			}
+		case *gateway.UserSettingsUpdateEvent:
+			// Settings updates only have the settings that changed, so a nil
+			// custom status doesn't mean it was cleared.
+			if ev.CustomStatus != nil {
+				s.setSettingsCustomStatus(ev.CustomStatus)
+				s.putCustomStatus(ev.CustomStatus, true)
+			}
+		case *gateway.SessionsReplaceEvent:
+			if custom := s.settingsCustomStatus(); custom != nil {
+				s.putCustomStatus(custom, false)
+			}
+		}
+	})
+}
+
+// putCustomStatus puts the custom status into the user's presence, or removes
+// it if custom is nil. If replace is false, a custom status that's already in
+// the presence is kept.
+func (s *State) putCustomStatus(custom *gateway.CustomUserStatus, replace bool) {
+	me, _ := s.Cabinet.Me()
+	if me == nil {
+		return
+	}
+
+	p, _ := s.PresenceStore.Presence(0, me.ID)
+	if p == nil {
+		return
+	}
+
+	new := *p
+	new.Activities = make([]discord.Activity, 0, len(p.Activities)+1)
+
+	for _, activity := range p.Activities {
+		if activity.Type == discord.CustomActivity && !replace {
+			return
+		}
+		if activity.Type == discord.CustomActivity || (custom != nil && isNingenCustomStatus(activity, custom)) {
+			continue
+		}
+		new.Activities = append(new.Activities, activity)
+	}
+
+	if custom != nil && !CustomStatusExpired(custom) {
+		new.Activities = append(new.Activities, customStatusActivity(custom))
+	}
+
+	s.PresenceSet(p.GuildID, &new, true)
+}
+
+// isNingenCustomStatus returns true if the activity is the custom status that
+// ningen adds, which is a game with only a name and maybe an emoji.
+func isNingenCustomStatus(activity discord.Activity, custom *gateway.CustomUserStatus) bool {
+	return activity.Type == discord.GameActivity &&
+		activity.Name == custom.Text &&
+		activity.Details == "" &&
+		activity.State == "" &&
+		activity.Timestamps == nil &&
+		activity.AppID == 0
+}
+
+func (s *State) presenceAFK() (bool, discord.UnixMsTimestamp) {
+	s.presence.Lock()
+	defer s.presence.Unlock()
+
+	return s.presence.afkSince != 0, discord.UnixMsTimestamp(s.presence.afkSince)
+}
+
+// AutoStatus is a reason for changing the user's status automatically.
+type AutoStatus uint8
+
+const (
+	// IdleAutoStatus is used while the user is away from the computer.
+	IdleAutoStatus AutoStatus = iota
+	// QuietHoursAutoStatus is used during quiet hours.
+	QuietHoursAutoStatus
+)
+
+type autoStatus struct {
+	status discord.Status
+	afk    bool
+}
+
+// statusRank orders the statuses from the least to the most restrictive.
+func statusRank(status discord.Status) int {
+	switch status {
+	case discord.OnlineStatus:
+		return 1
+	case discord.IdleStatus:
+		return 2
+	case discord.DoNotDisturbStatus:
+		return 3
+	case discord.InvisibleStatus:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// resolveAutoStatus returns the status and AFK state to use for the automatic
+// statuses. The most restrictive status wins, including the user's own, so an
+// automatic Idle doesn't override Do Not Disturb.
+func resolveAutoStatus(user discord.Status, auto map[AutoStatus]autoStatus) (discord.Status, bool) {
+	status := user
+	afk := false
+
+	for _, a := range auto {
+		if statusRank(a.status) > statusRank(status) {
+			status = a.status
+		}
+		afk = afk || a.afk
+	}
+
+	return status, afk
+}
+
+// SetAutoStatus changes the status for the given reason until ClearAutoStatus
+// is called. The State keeps the status that the user chose, so multiple
+// reasons can overlap. Like SetAFK, it doesn't change the status saved in the
+// user settings. Nothing happens while the status isn't known yet.
+func (s *State) SetAutoStatus(reason AutoStatus, status discord.Status, afk bool) error {
+	current := s.Status()
+
+	p := s.presence
+	p.Lock()
+
+	if len(p.auto) == 0 {
+		if statusRank(current) == 0 {
+			p.Unlock()
+			return nil
+		}
+		p.user = current
+		p.auto = make(map[AutoStatus]autoStatus, 2)
+	}
+
+	p.auto[reason] = autoStatus{status, afk}
+	status, afk = resolveAutoStatus(p.user, p.auto)
+	p.set = status
+
+	p.Unlock()
+
+	return s.SetAFK(status, afk)
+}
+
+// ClearAutoStatus stops changing the status for the given reason. Once no
+// reason is left, the status that the user chose is restored, unless they
+// changed it elsewhere in the meantime.
+func (s *State) ClearAutoStatus(reason AutoStatus) error {
+	current := s.Status()
+
+	p := s.presence
+	p.Lock()
+
+	if _, ok := p.auto[reason]; !ok {
+		p.Unlock()
+		return nil
+	}
+
+	delete(p.auto, reason)
+
+	status, afk := resolveAutoStatus(p.user, p.auto)
+	if len(p.auto) == 0 {
+		// The status may still be the user's if the Gateway didn't echo the
+		// automatic one back yet.
+		if current != p.set && current != p.user {
+			status = current
+		}
+		p.auto = nil
+		p.user = ""
+	}
+	p.set = status
+
+	p.Unlock()
+
+	return s.SetAFK(status, afk)
+}
+
+// UserStatus returns the status that the user chose, ignoring automatic
+// changes.
+func (s *State) UserStatus() discord.Status {
+	current := s.Status()
+
+	s.presence.Lock()
+	defer s.presence.Unlock()
+
+	if len(s.presence.auto) > 0 {
+		return s.presence.user
+	}
+	return current
+}
+
+// SetStatus sets the status that the user chose. Automatic statuses are
+// dropped, since they'd restore an outdated status later.
+func (s *State) SetStatus(status discord.Status, custom *gateway.CustomUserStatus, activities ...discord.Activity) error {
+	s.presence.Lock()
+	s.presence.auto = nil
+	s.presence.user = ""
+	s.presence.set = ""
+	s.presence.afkSince = 0
+	s.presence.Unlock()
+
+	return s.State.SetStatus(status, custom, activities...)
+}
+
+// SetAFK sends the status and whether the user is away from the keyboard over
+// the Gateway, keeping the activities. Unlike SetStatus, it doesn't change the
+// status saved in the user settings, so it's meant for automatic changes such
+// as going idle. Discord only sends push notifications to mobile devices while
+// the desktop session is AFK.
+func (s *State) SetAFK(status discord.Status, afk bool) error {
+	s.presence.Lock()
+	since := s.presence.afkSince
+	switch {
+	case !afk:
+		since = 0
+	case since == 0:
+		since = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	s.presence.afkSince = since
+	s.presence.Unlock()
+
+	cmd := gateway.UpdatePresenceCommand{
+		Status:     status,
+		AFK:        afk,
+		Since:      discord.UnixMsTimestamp(since),
+		Activities: s.ownActivities(),
+	}
+
+	return errors.Wrap(s.Gateway().Send(s.Context(), &cmd), "cannot update gateway")
+}
+
+// SetActivities replaces the user's activities over the Gateway, keeping their
+// status, custom status and AFK state.
+func (s *State) SetActivities(activities []discord.Activity) error {
+	all := make([]discord.Activity, 0, len(activities)+1)
+	all = append(all, activities...)
+	if custom := s.CustomStatus(); custom != nil {
+		all = append(all, *custom)
+	}
+
+	afk, since := s.presenceAFK()
+
+	cmd := gateway.UpdatePresenceCommand{
+		Status:     s.Status(),
+		AFK:        afk,
+		Since:      since,
+		Activities: all,
+	}
+
+	if err := s.Gateway().Send(s.Context(), &cmd); err != nil {
+		return errors.Wrap(err, "cannot update gateway")
+	}
+
+	// Other presence updates, including ningen's SetStatus, take the
+	// activities from the presence store, so keep them there.
+	s.putActivities(cmd.Status, all)
+	return nil
+}
+
+// Activities returns the user's activities other than their custom status.
+func (s *State) Activities() []discord.Activity {
+	var activities []discord.Activity
+	for _, activity := range s.ownActivities() {
+		if activity.Type != discord.CustomActivity {
+			activities = append(activities, activity)
+		}
+	}
+	return activities
+}
+
+// putActivities replaces the activities in the user's presence.
+func (s *State) putActivities(status discord.Status, activities []discord.Activity) {
+	me, _ := s.Cabinet.Me()
+	if me == nil {
+		return
+	}
+
+	new := discord.Presence{User: *me, Status: status}
+	if p, _ := s.PresenceStore.Presence(0, me.ID); p != nil {
+		new = *p
+	}
+	new.Activities = activities
+
+	s.PresenceSet(0, &new, true)
+}
+
+// StatusIcon returns the icon name for the status.
+func StatusIcon(status discord.Status) string {
+	switch status {
+	case discord.OnlineStatus:
+		return "user-available"
+	case discord.DoNotDisturbStatus:
+		return "user-busy"
+	case discord.IdleStatus:
+		return "user-idle"
+	case discord.InvisibleStatus:
+		return "user-invisible"
+	case discord.OfflineStatus:
+		return "user-offline"
+	case discord.UnknownStatus:
+		fallthrough
+	default:
+		return "user-status-pending"
+	}
+}
+
+// StatusText returns the name of the status.
+func StatusText(status discord.Status) string {
+	switch status {
+	case discord.OnlineStatus:
+		return "Online"
+	case discord.DoNotDisturbStatus:
+		return "Busy"
+	case discord.IdleStatus:
+		return "Idle"
+	case discord.InvisibleStatus:
+		return "Invisible"
+	case discord.OfflineStatus:
+		return "Offline"
+	case discord.UnknownStatus:
+		fallthrough
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,34 @@
+package gtkcord
+
+import (
+	"testing"
+
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+)
+
+func TestResolveAutoStatus(t *testing.T) {
+	idle := autoStatus{discord.IdleStatus, true}
+	dnd := autoStatus{discord.DoNotDisturbStatus, false}
+
+	tests := []struct {
+		name   string
+		user   discord.Status
+		auto   map[AutoStatus]autoStatus
+		status discord.Status
+		afk    bool
+	}{
+		{"none", discord.OnlineStatus, nil, discord.OnlineStatus, false},
+		{"idle", discord.OnlineStatus, map[AutoStatus]autoStatus{IdleAutoStatus: idle}, discord.IdleStatus, true},
+		{"idle keeps dnd", discord.DoNotDisturbStatus, map[AutoStatus]autoStatus{IdleAutoStatus: idle}, discord.DoNotDisturbStatus, true},
+		{"idle keeps invisible", discord.InvisibleStatus, map[AutoStatus]autoStatus{IdleAutoStatus: idle}, discord.InvisibleStatus, true},
+		{"quiet hours", discord.OnlineStatus, map[AutoStatus]autoStatus{QuietHoursAutoStatus: dnd}, discord.DoNotDisturbStatus, false},
+		{"both", discord.OnlineStatus, map[AutoStatus]autoStatus{IdleAutoStatus: idle, QuietHoursAutoStatus: dnd}, discord.DoNotDisturbStatus, true},
+	}
+
+	for _, test := range tests {
+		status, afk := resolveAutoStatus(test.user, test.auto)
+		if status != test.status || afk != test.afk {
+			t.Errorf("%s: expected %s (AFK %v), got %s (AFK %v)", test.name, test.status, test.afk, status, afk)
+		}
+	}
+}
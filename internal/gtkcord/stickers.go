@@ -0,0 +1,118 @@
+package gtkcord
+
+import (
+	"mime/multipart"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/api"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/thekrafter/arikawa-spacebar/v3/utils/sendpart"
+	"github.com/thekrafter/arikawa-spacebar/v3/utils/ws"
+)
+
+// MaxMessageStickers is the maximum number of stickers in a message.
+const MaxMessageStickers = 3
+
+// SendMessageData is api.SendMessageData with stickers, which arikawa doesn't
+// support sending.
+type SendMessageData struct {
+	api.SendMessageData
+	// StickerIDs are the IDs of the stickers to send, up to MaxMessageStickers.
+	StickerIDs []discord.StickerID `json:"sticker_ids,omitempty"`
+}
+
+// NeedsMultipart returns true if the message has files.
+func (data SendMessageData) NeedsMultipart() bool {
+	return len(data.Files) > 0
+}
+
+// WriteMultipart writes the message and its files into the multipart body.
+func (data SendMessageData) WriteMultipart(body *multipart.Writer) error {
+	return sendpart.Write(body, data, data.Files)
+}
+
+// SendMessageWithStickers sends a message like SendMessageComplex does, except
+// stickers can be sent along with it.
+func (s *State) SendMessageWithStickers(chID discord.ChannelID, data SendMessageData) (*discord.Message, error) {
+	if len(data.StickerIDs) == 0 {
+		return s.SendMessageComplex(chID, data.SendMessageData)
+	}
+
+	if len(data.StickerIDs) > MaxMessageStickers {
+		return nil, errors.New("too many stickers")
+	}
+
+	if data.AllowedMentions != nil {
+		if err := data.AllowedMentions.Verify(); err != nil {
+			return nil, errors.Wrap(err, "allowedMentions error")
+		}
+	}
+
+	var msg *discord.Message
+	return msg, sendpart.POST(
+		s.Client.Client, data, &msg,
+		api.EndpointChannels+chID.String()+"/messages",
+	)
+}
+
+// GuildStickersUpdateEvent is sent when the stickers of a guild change.
+// arikawa doesn't have it, so it's registered here.
+type GuildStickersUpdateEvent struct {
+	GuildID  discord.GuildID   `json:"guild_id"`
+	Stickers []discord.Sticker `json:"stickers"`
+}
+
+// Op implements ws.Event. It returns the dispatch opcode.
+func (*GuildStickersUpdateEvent) Op() ws.OpCode { return 0 }
+
+// EventType implements ws.Event.
+func (*GuildStickersUpdateEvent) EventType() ws.EventType { return "GUILD_STICKERS_UPDATE" }
+
+func init() {
+	gateway.OpUnmarshalers.Add(func() ws.Event { return new(GuildStickersUpdateEvent) })
+}
+
+var guildStickers = struct {
+	sync.Mutex
+	stickers map[discord.GuildID][]discord.Sticker
+}{
+	stickers: make(map[discord.GuildID][]discord.Sticker),
+}
+
+// GuildStickers returns the stickers of the guild. The stickers are fetched
+// once and cached for the rest of the session.
+func (s *State) GuildStickers(guildID discord.GuildID) ([]discord.Sticker, error) {
+	guildStickers.Lock()
+	stickers, ok := guildStickers.stickers[guildID]
+	guildStickers.Unlock()
+
+	if ok {
+		return stickers, nil
+	}
+
+	err := s.RequestJSON(
+		&stickers, "GET",
+		api.EndpointGuilds+guildID.String()+"/stickers",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	guildStickers.Lock()
+	guildStickers.stickers[guildID] = stickers
+	guildStickers.Unlock()
+
+	return stickers, nil
+}
+
+// bindGuildStickers drops the cached stickers of a guild once they change, so
+// that they're fetched again.
+func (s *State) bindGuildStickers() {
+	s.AddHandler(func(ev *GuildStickersUpdateEvent) {
+		guildStickers.Lock()
+		delete(guildStickers.stickers, ev.GuildID)
+		guildStickers.Unlock()
+	})
+}
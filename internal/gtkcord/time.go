@@ -0,0 +1,265 @@
+package gtkcord
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/app/prefs"
+)
+
+const (
+	clockSystem = "System"
+	clock12Hour = "12-hour"
+	clock24Hour = "24-hour"
+)
+
+var clockFormat = prefs.NewEnumList(clockSystem, prefs.EnumListMeta{
+	PropMeta: prefs.PropMeta{
+		Name:        "Clock Format",
+		Section:     "Timestamps",
+		Description: "Whether times are shown in the system's format, 12-hour or 24-hour time.",
+	},
+	Options: []string{clockSystem, clock12Hour, clock24Hour},
+})
+
+var relativeTimestamps = prefs.NewBool(true, prefs.PropMeta{
+	Name:        "Relative Timestamps",
+	Section:     "Timestamps",
+	Description: "Show message timestamps relative to now, such as \"5 minutes ago\", instead of the full date.",
+})
+
+var displayTimezone = prefs.NewString("", prefs.StringMeta{
+	Name:        "Timezone",
+	Section:     "Timestamps",
+	Description: "The IANA timezone to show times in, such as Europe/Berlin. Leave empty to use the system's timezone.",
+	Placeholder: "Local",
+	Validate: func(tz string) error {
+		if tz == "" {
+			return nil
+		}
+		_, err := time.LoadLocation(tz)
+		return err
+	},
+})
+
+// timeLocation holds the *time.Location parsed from displayTimezone, so that
+// it's not loaded again for every timestamp. Notifications use it outside of
+// the main thread.
+var timeLocation atomic.Value
+
+// timeTicker is published every tickInterval and every time a timestamp
+// preference changes.
+var timeTicker = prefs.NewPubsub()
+
+const tickInterval = 30 // seconds
+
+var startTicker sync.Once
+
+func init() {
+	prefs.Order(clockFormat, relativeTimestamps, displayTimezone)
+
+	clockFormat.SubscribeInit(timeTicker.Publish)
+	relativeTimestamps.SubscribeInit(timeTicker.Publish)
+	displayTimezone.SubscribeInit(func() {
+		timeLocation.Store(loadTimeLocation(displayTimezone.Value()))
+		timeTicker.Publish()
+	})
+}
+
+// SubscribeTimestamps calls f every time the shown timestamps may need to be
+// updated, for as long as the widget is mapped. Relative timestamps use this
+// to stay accurate.
+func SubscribeTimestamps(w gtk.Widgetter, f func()) {
+	startTicker.Do(func() {
+		glib.TimeoutSecondsAdd(tickInterval, func() bool {
+			timeTicker.Publish()
+			return true
+		})
+	})
+	timeTicker.SubscribeWidget(w, f)
+}
+
+// TimeLocation returns the timezone that times should be shown in.
+func TimeLocation() *time.Location {
+	if loc, ok := timeLocation.Load().(*time.Location); ok {
+		return loc
+	}
+	return time.Local
+}
+
+func loadTimeLocation(tz string) *time.Location {
+	if tz != "" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+func clockLayout(seconds bool) string {
+	switch clockFormat.Value() {
+	case clock12Hour:
+		if seconds {
+			return "%l:%M:%S %p"
+		}
+		return "%l:%M %p"
+	case clock24Hour:
+		if seconds {
+			return "%H:%M:%S"
+		}
+		return "%H:%M"
+	default:
+		return "%X"
+	}
+}
+
+// doubleSpaceCollider gets rid of the padding spaces that some formats, such
+// as %l, add.
+var doubleSpaceCollider = strings.NewReplacer("  ", " ")
+
+func formatGLib(t time.Time, layout string) string {
+	t = t.In(TimeLocation())
+
+	// GDateTime only goes from year 1 to 9999.
+	glibTime := glib.NewDateTimeFromGo(t)
+	if glibTime == nil {
+		return t.Format("Mon, 02 Jan 2006 15:04:05")
+	}
+
+	return strings.TrimSpace(doubleSpaceCollider.Replace(glibTime.Format(layout)))
+}
+
+// FormatTime formats the given time using the user's preferred clock format
+// and timezone. If long is true, then the date is included.
+func FormatTime(t time.Time, long bool) string {
+	if !long {
+		return formatGLib(t, clockLayout(false))
+	}
+	if clockFormat.Value() == clockSystem {
+		return formatGLib(t, "%c")
+	}
+	return formatGLib(t, "%a %x "+clockLayout(true))
+}
+
+// FormatTimeAgo formats the given time for a message header. If relative
+// timestamps are enabled, then the time is shown relative to now. The result
+// changes over time, so callers should refresh it using SubscribeTimestamps.
+func FormatTimeAgo(t time.Time) string {
+	if !relativeTimestamps.Value() {
+		return formatGLib(t, "%x "+clockLayout(false))
+	}
+
+	loc := TimeLocation()
+	now := time.Now().In(loc)
+	t = t.In(loc)
+
+	switch d := now.Sub(t); {
+	case d < time.Minute:
+		return locale.Get("Just now")
+	case d < 2*time.Minute:
+		return locale.Get("1 minute ago")
+	case d < time.Hour:
+		return locale.Get("%d minutes ago", int(d/time.Minute))
+	}
+
+	clock := clockLayout(false)
+
+	ty, tm, td := t.Date()
+	ny, nm, nd := now.Date()
+	today := time.Date(ny, nm, nd, 0, 0, 0, 0, loc)
+	day := time.Date(ty, tm, td, 0, 0, 0, 0, loc)
+
+	switch days := int(today.Sub(day).Hours()/24 + 0.5); {
+	case days == 0:
+		return locale.Get("Today at %s", formatGLib(t, clock))
+	case days == 1:
+		return locale.Get("Yesterday at %s", formatGLib(t, clock))
+	case days < 7:
+		return locale.Get("%s at %s", formatGLib(t, "%A"), formatGLib(t, clock))
+	default:
+		return formatGLib(t, "%x "+clock)
+	}
+}
+
+// FormatTimestampTag formats the given time using the style of a Discord
+// timestamp tag, such as <t:1700000000:R>. Unknown styles are treated as the
+// default style, f.
+func FormatTimestampTag(t time.Time, style byte) string {
+	clock := clockLayout(false)
+
+	switch style {
+	case 't':
+		return formatGLib(t, clock)
+	case 'T':
+		return formatGLib(t, clockLayout(true))
+	case 'd':
+		return formatGLib(t, "%x")
+	case 'D':
+		return formatGLib(t, "%e %B %Y")
+	case 'F':
+		return formatGLib(t, "%A, %e %B %Y "+clock)
+	case 'R':
+		return formatRelative(t)
+	default:
+		return formatGLib(t, "%e %B %Y "+clock)
+	}
+}
+
+type relativeUnit struct {
+	d         time.Duration
+	one, many string
+}
+
+var relativeUnits = []relativeUnit{
+	{Year, "a year", "%d years"},
+	{30 * Day, "a month", "%d months"},
+	{Day, "a day", "%d days"},
+	{time.Hour, "an hour", "%d hours"},
+	{time.Minute, "a minute", "%d minutes"},
+}
+
+const (
+	// Day is a day in duration.
+	Day = 24 * time.Hour
+	// Year is a non-leap year in duration.
+	Year = 365 * Day
+)
+
+// formatRelative formats t relative to now, such as "in 5 minutes" or "2 days
+// ago".
+func formatRelative(t time.Time) string {
+	d := time.Until(t)
+	future := d > 0
+	if !future {
+		d = -d
+	}
+	// Durations are capped at around 292 years, and negating the smallest
+	// one overflows.
+	if d < 0 {
+		d = math.MaxInt64
+	}
+
+	amount := locale.Get("a few seconds")
+	for _, unit := range relativeUnits {
+		if d < unit.d || d < 45*time.Second {
+			continue
+		}
+		if n := int(d / unit.d); n == 1 {
+			amount = locale.Get(unit.one)
+		} else {
+			amount = locale.Get(unit.many, n)
+		}
+		break
+	}
+
+	if future {
+		return locale.Get("in %s", amount)
+	}
+	return locale.Get("%s ago", amount)
+}
@@ -0,0 +1,145 @@
+package gtkcord
+
+import (
+	"github.com/diamondburned/ningen/v3"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+)
+
+// UnreadCounts summarizes the user's unread messages.
+type UnreadCounts struct {
+	// Unread is the number of channels with unread messages, not counting
+	// muted ones.
+	Unread int `json:"unread"`
+	// Mentions is the number of mentions plus the number of direct message
+	// channels with unread messages.
+	Mentions int `json:"mentions"`
+}
+
+// UnreadCounts counts the user's unread channels and mentions across all
+// guilds and direct messages.
+func (s *State) UnreadCounts() UnreadCounts {
+	var counts UnreadCounts
+
+	guilds, _ := s.Cabinet.Guilds()
+	for _, guild := range guilds {
+		guildCounts := s.GuildUnreadCounts(guild.ID)
+		counts.Unread += guildCounts.Unread
+		counts.Mentions += guildCounts.Mentions
+	}
+
+	chs, _ := s.Cabinet.PrivateChannels()
+	for _, ch := range chs {
+		if s.ChannelIsUnread(ch.ID) == ningen.ChannelRead {
+			continue
+		}
+
+		counts.Unread++
+
+		mentions := 1
+		if read := s.ReadState.ReadState(ch.ID); read != nil && read.MentionCount > mentions {
+			mentions = read.MentionCount
+		}
+		counts.Mentions += mentions
+	}
+
+	return counts
+}
+
+// GuildUnreadCounts counts the unread channels and mentions in the guild.
+// Unread channels are only counted if the guild isn't muted, unless they
+// mention the user.
+func (s *State) GuildUnreadCounts(guildID discord.GuildID) UnreadCounts {
+	var counts UnreadCounts
+
+	typeMap := make(map[discord.ChannelType]bool, len(AllowedChannelTypes))
+	for _, typ := range AllowedChannelTypes {
+		typeMap[typ] = true
+	}
+
+	muted := s.MutedState.Guild(guildID, false)
+
+	chs, _ := s.Cabinet.Channels(guildID)
+	for i := range chs {
+		if !typeMap[chs[i].Type] {
+			continue
+		}
+
+		switch s.ChannelUnread(&chs[i]) {
+		case ningen.ChannelMentioned:
+			counts.Unread++
+		case ningen.ChannelUnread:
+			if !muted {
+				counts.Unread++
+			}
+		}
+
+		counts.Mentions += s.ChannelMentionCount(&chs[i])
+	}
+
+	return counts
+}
+
+// ChannelUnread is like ChannelIsUnread, except the channel's effective
+// notification settings are followed: mentions in channels that are set to
+// never notify only count as unread, and unread messages in muted channels or
+// categories are ignored.
+func (s *State) ChannelUnread(ch *discord.Channel) ningen.UnreadIndication {
+	unread := s.ChannelIsUnread(ch.ID)
+	if unread == ningen.ChannelRead {
+		return unread
+	}
+
+	if unread == ningen.ChannelMentioned && s.ChannelNotifications(ch) == gateway.NoNotifications {
+		unread = ningen.ChannelUnread
+	}
+
+	if unread == ningen.ChannelUnread && s.channelMuted(ch) {
+		return ningen.ChannelRead
+	}
+
+	return unread
+}
+
+// ChannelMentionCount returns the number of unread mentions in the channel.
+// Channels that are set to never notify have none.
+func (s *State) ChannelMentionCount(ch *discord.Channel) int {
+	if s.ChannelNotifications(ch) == gateway.NoNotifications {
+		return 0
+	}
+
+	read := s.ReadState.ReadState(ch.ID)
+	if read == nil {
+		return 0
+	}
+
+	return read.MentionCount
+}
+
+// GuildUnread is like GuildIsUnread, except each channel is checked using
+// ChannelUnread.
+func (s *State) GuildUnread(guildID discord.GuildID, types []discord.ChannelType) ningen.UnreadIndication {
+	typeMap := make(map[discord.ChannelType]bool, len(types))
+	for _, typ := range types {
+		typeMap[typ] = true
+	}
+
+	ind := ningen.ChannelRead
+
+	chs, _ := s.Cabinet.Channels(guildID)
+	for i := range chs {
+		if !typeMap[chs[i].Type] {
+			continue
+		}
+		if unread := s.ChannelUnread(&chs[i]); unread > ind {
+			ind = unread
+		}
+	}
+
+	// Only show mentions for muted guilds.
+	if ind != ningen.ChannelMentioned && s.MutedState.Guild(guildID, false) {
+		return ningen.ChannelRead
+	}
+
+	return ind
+}
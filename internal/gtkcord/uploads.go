@@ -0,0 +1,109 @@
+package gtkcord
+
+import (
+	"sync"
+	"time"
+
+	"github.com/thekrafter/arikawa-spacebar/v3/api"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+)
+
+// DefaultMaxUploadSize is the upload size limit used when neither the instance
+// nor the guild says otherwise.
+const DefaultMaxUploadSize = 25 * 1024 * 1024
+
+// InstanceLimits is the part of the instance's limits policy that is relevant
+// to gtkcord.
+type InstanceLimits struct {
+	Message struct {
+		// MaxAttachmentSize is the maximum size of an attachment in bytes.
+		MaxAttachmentSize int64 `json:"maxAttachmentSize"`
+	} `json:"message"`
+}
+
+// limitsRetryMin and limitsRetryMax bound how long a failed limits fetch is
+// remembered before it's tried again.
+const (
+	limitsRetryMin = 30 * time.Second
+	limitsRetryMax = 30 * time.Minute
+)
+
+// limitsCache caches the instance's limits policy for a session.
+type limitsCache struct {
+	mu      sync.Mutex
+	limits  *InstanceLimits
+	err     error
+	retry   time.Time     // when to try again after err
+	backoff time.Duration // how long to wait after the next failure
+}
+
+// InstanceLimits fetches the limits policy of the instance. The limits are
+// fetched once and cached for the rest of the session. Failures are cached
+// too, and the fetch is retried with an increasing delay.
+func (s *State) InstanceLimits() (*InstanceLimits, error) {
+	c := s.limits
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.limits != nil {
+		return c.limits, nil
+	}
+
+	if c.err != nil && time.Now().Before(c.retry) {
+		return nil, c.err
+	}
+
+	limits := &InstanceLimits{}
+	if err := s.RequestJSON(limits, "GET", api.Endpoint+"policies/instance/limits"); err != nil {
+		if c.backoff < limitsRetryMin {
+			c.backoff = limitsRetryMin
+		}
+		c.err = err
+		c.retry = time.Now().Add(c.backoff)
+		if c.backoff *= 2; c.backoff > limitsRetryMax {
+			c.backoff = limitsRetryMax
+		}
+		return nil, err
+	}
+
+	c.limits = limits
+	c.err = nil
+	return limits, nil
+}
+
+// MaxUploadSize returns the maximum size of a file that the user can upload
+// into the given guild. The instance's limit is used if it has one, otherwise
+// the limit is guessed from the guild's boost level and the user's Nitro. It
+// may do network calls, so it should be called in a goroutine.
+func (s *State) MaxUploadSize(guildID discord.GuildID) int64 {
+	if limits, err := s.InstanceLimits(); err == nil && limits.Message.MaxAttachmentSize > 0 {
+		return limits.Message.MaxAttachmentSize
+	}
+
+	var max int64 = DefaultMaxUploadSize
+
+	if guildID.IsValid() {
+		if guild, err := s.Cabinet.Guild(guildID); err == nil {
+			switch guild.NitroBoost {
+			case discord.NitroLevel2:
+				max = 50 * 1024 * 1024
+			case discord.NitroLevel3:
+				max = 100 * 1024 * 1024
+			}
+		}
+	}
+
+	if me, err := s.Cabinet.Me(); err == nil {
+		switch me.Nitro {
+		case discord.NitroFull:
+			max = 500 * 1024 * 1024
+		case discord.NitroBasic:
+			if max < 50*1024*1024 {
+				max = 50 * 1024 * 1024
+			}
+		}
+	}
+
+	return max
+}
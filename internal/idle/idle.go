@@ -0,0 +1,150 @@
+package idle
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/godbus/dbus/v5"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+var autoIdle = prefs.NewBool(true, prefs.PropMeta{
+	Name:    "Set Idle Automatically",
+	Section: "Presence",
+	Description: "Switch to Idle while away from the computer or while the screen is locked, " +
+		"so that notifications go to your other devices instead.",
+})
+
+var idleTimeout = prefs.NewInt(10, prefs.IntMeta{
+	Name:        "Idle Timeout",
+	Section:     "Presence",
+	Description: "Minutes without any input before switching to Idle.",
+	Min:         1,
+	Max:         120,
+})
+
+func init() {
+	prefs.Order(autoIdle, idleTimeout)
+}
+
+// presence switches the user's status to Idle while the Monitor reports them
+// as idle and restores it once they're back.
+type presence struct {
+	ctx     context.Context
+	state   *gtkcord.State
+	monitor *Monitor
+	// starting is incremented every time the Monitor is restarted, so that
+	// a Monitor that finished starting after the prefs changed is discarded.
+	starting int
+	// idle is true while the status is changed because the user is idle. The
+	// status to restore is kept by the State.
+	idle bool
+}
+
+// Start starts switching the user's status to Idle while they're away. It
+// stops once ctx is done. The state is taken from ctx.
+func Start(ctx context.Context) {
+	p := &presence{
+		ctx:   ctx,
+		state: gtkcord.FromContext(ctx),
+	}
+
+	p.restart()
+
+	rm1 := autoIdle.Subscribe(p.restart)
+	rm2 := idleTimeout.Subscribe(p.restart)
+
+	go func() {
+		<-ctx.Done()
+		glib.IdleAdd(func() {
+			rm1()
+			rm2()
+			p.stop()
+		})
+	}()
+}
+
+func (p *presence) stop() {
+	p.starting++
+
+	if p.monitor != nil {
+		monitor := p.monitor
+		p.monitor = nil
+		go monitor.Close()
+	}
+
+	// The session is gone once ctx is done, so there's nothing to restore.
+	if p.ctx.Err() != nil {
+		p.idle = false
+		return
+	}
+
+	p.setIdle(false)
+}
+
+func (p *presence) restart() {
+	p.stop()
+
+	if !autoIdle.Value() || p.ctx.Err() != nil {
+		return
+	}
+
+	starting := p.starting
+	timeout := time.Duration(idleTimeout.Value()) * time.Minute
+
+	gtkutil.Async(p.ctx, func() func() {
+		conn, err := dbus.SessionBus()
+		if err != nil {
+			log.Println("idle: cannot connect to session bus:", err)
+			return nil
+		}
+
+		monitor, err := NewMonitor(conn, timeout, func(idle bool) {
+			glib.IdleAdd(func() {
+				if p.starting == starting {
+					p.setIdle(idle)
+				}
+			})
+		})
+		if err != nil {
+			log.Println("idle:", err)
+			return nil
+		}
+
+		return func() {
+			if p.starting != starting {
+				go monitor.Close()
+				return
+			}
+			p.monitor = monitor
+		}
+	})
+}
+
+// setIdle switches the status to Idle or restores it. Do Not Disturb and
+// Invisible are kept while idle, but the user is still marked as AFK.
+func (p *presence) setIdle(idle bool) {
+	if idle == p.idle {
+		return
+	}
+	p.idle = idle
+
+	state := p.state
+	gtkutil.Async(context.Background(), func() func() {
+		var err error
+		if idle {
+			err = state.SetAutoStatus(gtkcord.IdleAutoStatus, discord.IdleStatus, true)
+		} else {
+			err = state.ClearAutoStatus(gtkcord.IdleAutoStatus)
+		}
+		if err != nil {
+			log.Println("idle: cannot set status:", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,210 @@
+// Package idle switches the user's status to Idle while they're away from the
+// desktop.
+package idle
+
+import (
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+)
+
+// The D-Bus services that the Monitor uses. The Mutter idle monitor is
+// preferred, since it notifies the Monitor instead of being polled.
+const (
+	MutterName  = "org.gnome.Mutter.IdleMonitor"
+	MutterPath  = "/org/gnome/Mutter/IdleMonitor/Core"
+	MutterIface = "org.gnome.Mutter.IdleMonitor"
+
+	ScreenSaverName  = "org.freedesktop.ScreenSaver"
+	ScreenSaverPath  = "/org/freedesktop/ScreenSaver"
+	ScreenSaverIface = "org.freedesktop.ScreenSaver"
+)
+
+// ErrUnsupported is returned if the session has neither service.
+var ErrUnsupported = errors.New("no idle monitor on the session bus")
+
+// pollInterval is how often the screensaver's idle time is polled if Mutter
+// isn't available. It's a variable so that tests can shorten it.
+var pollInterval = 15 * time.Second
+
+// Monitor reports when the user becomes idle and when they're back. The user
+// is idle once there was no input for the timeout or while the screensaver is
+// active.
+//
+// The Monitor only talks to the services through the given connection, so it
+// works with a private bus and fake services as well.
+type Monitor struct {
+	conn    *dbus.Conn
+	timeout time.Duration
+	f       func(idle bool)
+
+	signals chan *dbus.Signal
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	// mutter is true if the Mutter idle monitor is used. Otherwise, the
+	// screensaver's idle time is polled.
+	mutter      bool
+	idleWatch   uint32
+	activeWatch uint32
+
+	inputIdle bool
+	locked    bool
+	idle      bool
+}
+
+// NewMonitor creates a new Monitor. f is called with true when the user
+// becomes idle and with false when they're back. It's called from the
+// Monitor's goroutine.
+func NewMonitor(conn *dbus.Conn, timeout time.Duration, f func(idle bool)) (*Monitor, error) {
+	m := &Monitor{
+		conn:    conn,
+		timeout: timeout,
+		f:       f,
+		signals: make(chan *dbus.Signal, 8),
+		done:    make(chan struct{}),
+	}
+
+	mutter := conn.Object(MutterName, MutterPath)
+
+	err := mutter.Call(MutterIface+".AddIdleWatch", 0, uint64(timeout/time.Millisecond)).Store(&m.idleWatch)
+	m.mutter = err == nil
+
+	if !m.mutter {
+		var idleTime uint32
+		err := conn.Object(ScreenSaverName, ScreenSaverPath).
+			Call(ScreenSaverIface+".GetSessionIdleTime", 0).
+			Store(&idleTime)
+		if err != nil {
+			return nil, ErrUnsupported
+		}
+	}
+
+	conn.Signal(m.signals)
+	m.matchSignals(conn.AddMatchSignal)
+
+	m.wg.Add(1)
+	go m.loop()
+
+	return m, nil
+}
+
+func (m *Monitor) matchSignals(f func(...dbus.MatchOption) error) {
+	if m.mutter {
+		f(
+			dbus.WithMatchObjectPath(MutterPath),
+			dbus.WithMatchInterface(MutterIface),
+			dbus.WithMatchMember("WatchFired"),
+		)
+	}
+	f(
+		dbus.WithMatchInterface(ScreenSaverIface),
+		dbus.WithMatchMember("ActiveChanged"),
+	)
+}
+
+// Close stops the Monitor. f isn't called after Close returns.
+func (m *Monitor) Close() {
+	close(m.done)
+	m.wg.Wait()
+
+	m.matchSignals(m.conn.RemoveMatchSignal)
+	m.conn.RemoveSignal(m.signals)
+
+	if m.mutter {
+		mutter := m.conn.Object(MutterName, MutterPath)
+		mutter.Call(MutterIface+".RemoveWatch", 0, m.idleWatch)
+		if m.activeWatch != 0 {
+			mutter.Call(MutterIface+".RemoveWatch", 0, m.activeWatch)
+		}
+	}
+}
+
+func (m *Monitor) loop() {
+	defer m.wg.Done()
+
+	var poll <-chan time.Time
+	if !m.mutter {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case sig := <-m.signals:
+			m.handleSignal(sig)
+		case <-poll:
+			m.poll()
+		}
+	}
+}
+
+func (m *Monitor) handleSignal(sig *dbus.Signal) {
+	switch sig.Name {
+	case MutterIface + ".WatchFired":
+		if len(sig.Body) != 1 {
+			return
+		}
+		id, _ := sig.Body[0].(uint32)
+
+		switch id {
+		case m.idleWatch:
+			// Mutter removes user active watches once they fire, so a new
+			// one is added every time the user becomes idle.
+			err := m.conn.Object(MutterName, MutterPath).
+				Call(MutterIface+".AddUserActiveWatch", 0).
+				Store(&m.activeWatch)
+			if err != nil {
+				return
+			}
+			m.inputIdle = true
+		case m.activeWatch:
+			m.activeWatch = 0
+			m.inputIdle = false
+		default:
+			return
+		}
+
+	case ScreenSaverIface + ".ActiveChanged":
+		if len(sig.Body) != 1 {
+			return
+		}
+		m.locked, _ = sig.Body[0].(bool)
+		if !m.locked {
+			// Unlocking the screen means the user is back.
+			m.inputIdle = false
+		}
+
+	default:
+		return
+	}
+
+	m.update()
+}
+
+func (m *Monitor) poll() {
+	// The idle time is in seconds.
+	var idleTime uint32
+	err := m.conn.Object(ScreenSaverName, ScreenSaverPath).
+		Call(ScreenSaverIface+".GetSessionIdleTime", 0).
+		Store(&idleTime)
+	if err != nil {
+		return
+	}
+
+	m.inputIdle = time.Duration(idleTime)*time.Second >= m.timeout
+	m.update()
+}
+
+func (m *Monitor) update() {
+	idle := m.inputIdle || m.locked
+	if idle != m.idle {
+		m.idle = idle
+		m.f(idle)
+	}
+}
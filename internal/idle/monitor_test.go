@@ -0,0 +1,286 @@
+package idle
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// privateBus starts a private session bus and returns its address. The test
+// is skipped if dbus-daemon isn't installed.
+func privateBus(t *testing.T) string {
+	t.Helper()
+
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	cmd := exec.Command(path, "--session", "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal("cannot start dbus-daemon:", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal("cannot read bus address:", err)
+	}
+
+	return strings.TrimSpace(addr)
+}
+
+func connect(t *testing.T, addr string) *dbus.Conn {
+	t.Helper()
+
+	conn, err := dbus.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if err := conn.Auth(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Hello(); err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func export(t *testing.T, conn *dbus.Conn, v interface{}, name string, path dbus.ObjectPath, iface string) {
+	t.Helper()
+
+	if err := conn.Export(v, path, iface); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("cannot own %s: %v", name, err)
+	}
+}
+
+type fakeMutter struct {
+	mu      sync.Mutex
+	next    uint32
+	timeout uint64
+	idle    uint32
+	active  uint32
+	removed []uint32
+}
+
+func (f *fakeMutter) AddIdleWatch(ms uint64) (uint32, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.next++
+	f.idle = f.next
+	f.timeout = ms
+	return f.idle, nil
+}
+
+func (f *fakeMutter) AddUserActiveWatch() (uint32, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.next++
+	f.active = f.next
+	return f.active, nil
+}
+
+func (f *fakeMutter) RemoveWatch(id uint32) *dbus.Error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.removed = append(f.removed, id)
+	return nil
+}
+
+func (f *fakeMutter) watches() (idle, active uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.idle, f.active
+}
+
+func (f *fakeMutter) removedWatches() []uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]uint32(nil), f.removed...)
+}
+
+type fakeScreenSaver struct {
+	mu       sync.Mutex
+	idleTime uint32
+}
+
+func (f *fakeScreenSaver) GetSessionIdleTime() (uint32, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.idleTime, nil
+}
+
+func (f *fakeScreenSaver) setIdleTime(secs uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.idleTime = secs
+}
+
+// newTestMonitor creates a Monitor that sends its reports into the returned
+// channel.
+func newTestMonitor(t *testing.T, conn *dbus.Conn, timeout time.Duration) (*Monitor, <-chan bool) {
+	t.Helper()
+
+	reports := make(chan bool, 8)
+
+	m, err := NewMonitor(conn, timeout, func(idle bool) { reports <- idle })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m, reports
+}
+
+func expectReport(t *testing.T, reports <-chan bool, idle bool) {
+	t.Helper()
+
+	select {
+	case got := <-reports:
+		if got != idle {
+			t.Fatalf("expected idle = %v, got %v", idle, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected idle = %v, got nothing", idle)
+	}
+}
+
+func expectNoReport(t *testing.T, reports <-chan bool) {
+	t.Helper()
+
+	select {
+	case got := <-reports:
+		t.Fatalf("unexpected idle = %v", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestMonitorUnsupported(t *testing.T) {
+	conn := connect(t, privateBus(t))
+
+	if _, err := NewMonitor(conn, time.Minute, func(bool) {}); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestMonitorMutter(t *testing.T) {
+	addr := privateBus(t)
+	service := connect(t, addr)
+	client := connect(t, addr)
+
+	mutter := &fakeMutter{}
+	export(t, service, mutter, MutterName, MutterPath, MutterIface)
+
+	m, reports := newTestMonitor(t, client, 10*time.Minute)
+
+	mutter.mu.Lock()
+	timeout := mutter.timeout
+	mutter.mu.Unlock()
+
+	if timeout != uint64(10*time.Minute/time.Millisecond) {
+		t.Fatalf("unexpected idle watch timeout %d", timeout)
+	}
+
+	fire := func(id uint32) {
+		t.Helper()
+		if err := service.Emit(MutterPath, MutterIface+".WatchFired", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idleWatch, _ := mutter.watches()
+
+	// Watches that the Monitor didn't add are ignored.
+	fire(idleWatch + 100)
+	expectNoReport(t, reports)
+
+	fire(idleWatch)
+	expectReport(t, reports, true)
+
+	_, activeWatch := mutter.watches()
+	if activeWatch == 0 {
+		t.Fatal("no user active watch was added")
+	}
+
+	fire(activeWatch)
+	expectReport(t, reports, false)
+
+	// Locking the screen makes the user idle regardless of input.
+	if err := service.Emit(ScreenSaverPath, ScreenSaverIface+".ActiveChanged", true); err != nil {
+		t.Fatal(err)
+	}
+	expectReport(t, reports, true)
+
+	m.Close()
+
+	if removed := mutter.removedWatches(); len(removed) != 1 || removed[0] != idleWatch {
+		t.Fatalf("expected the idle watch %d to be removed, got %v", idleWatch, removed)
+	}
+
+	fire(idleWatch)
+	expectNoReport(t, reports)
+}
+
+func TestMonitorScreenSaver(t *testing.T) {
+	addr := privateBus(t)
+	service := connect(t, addr)
+	client := connect(t, addr)
+
+	saver := &fakeScreenSaver{}
+	export(t, service, saver, ScreenSaverName, ScreenSaverPath, ScreenSaverIface)
+
+	interval := pollInterval
+	pollInterval = 50 * time.Millisecond
+	t.Cleanup(func() { pollInterval = interval })
+
+	m, reports := newTestMonitor(t, client, time.Minute)
+	defer m.Close()
+
+	saver.setIdleTime(59)
+	expectNoReport(t, reports)
+
+	saver.setIdleTime(60)
+	expectReport(t, reports, true)
+
+	saver.setIdleTime(0)
+	expectReport(t, reports, false)
+
+	lock := func(locked bool) {
+		t.Helper()
+		if err := service.Emit(ScreenSaverPath, ScreenSaverIface+".ActiveChanged", locked); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lock(true)
+	expectReport(t, reports, true)
+
+	lock(false)
+	expectReport(t, reports, false)
+}
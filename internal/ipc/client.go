@@ -0,0 +1,140 @@
+package ipc
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// Client calls the methods of a running instance. It implements Handler.
+type Client struct {
+	obj dbus.BusObject
+}
+
+var _ Handler = (*Client)(nil)
+
+// NewClient creates a new Client that calls the instance on the given
+// connection.
+func NewClient(conn *dbus.Conn) *Client {
+	return &Client{obj: conn.Object(BusName, ObjectPath)}
+}
+
+// IsRunning returns true if an instance owns BusName on the connection.
+func IsRunning(conn *dbus.Conn) bool {
+	var owned bool
+	err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, BusName).Store(&owned)
+	return err == nil && owned
+}
+
+func (c *Client) call(method string, args ...interface{}) *dbus.Call {
+	call := c.obj.Call(Interface+"."+method, 0, args...)
+	call.Err = clientError(call.Err)
+	return call
+}
+
+// clientError converts a D-Bus error returned by the server back into the
+// errors of this package.
+func clientError(err error) error {
+	var dbusErr dbus.Error
+	if !errors.As(err, &dbusErr) {
+		return err
+	}
+
+	switch dbusErr.Name {
+	case ErrorNotLoggedIn:
+		return ErrNotLoggedIn
+	case ErrorInvalidArgs:
+		return errors.Wrap(ErrInvalidArgs, dbusErr.Error())
+	default:
+		return err
+	}
+}
+
+// OpenChannel implements Handler.
+func (c *Client) OpenChannel(cmd gtkcord.OpenChannelCommand) error {
+	return c.call("OpenChannel", uint64(cmd.ChannelID), uint64(cmd.MessageID)).Err
+}
+
+// SendMessage implements Handler.
+func (c *Client) SendMessage(cmd gtkcord.SendMessageCommand) (discord.MessageID, error) {
+	var msgID uint64
+	err := c.call("SendMessage", uint64(cmd.ChannelID), cmd.Content).Store(&msgID)
+	return discord.MessageID(msgID), err
+}
+
+// SetStatus implements Handler.
+func (c *Client) SetStatus(cmd gtkcord.SetStatusCommand) error {
+	return c.call("SetStatus", string(cmd.Status)).Err
+}
+
+// SetCustomStatus implements Handler.
+func (c *Client) SetCustomStatus(cmd gtkcord.SetCustomStatusCommand) error {
+	var expiresAt int64
+	if !cmd.ExpiresAt.IsZero() {
+		expiresAt = cmd.ExpiresAt.Unix()
+	}
+	return c.call("SetCustomStatus", cmd.Text, cmd.Emoji, expiresAt).Err
+}
+
+// UnreadCounts implements Handler.
+func (c *Client) UnreadCounts() (gtkcord.UnreadCounts, error) {
+	var unread, mentions int32
+	err := c.call("GetUnreadCounts").Store(&unread, &mentions)
+	return gtkcord.UnreadCounts{
+		Unread:   int(unread),
+		Mentions: int(mentions),
+	}, err
+}
+
+// Guilds implements Handler.
+func (c *Client) Guilds() ([]gtkcord.GuildInfo, error) {
+	var entries []entry
+	if err := c.call("ListGuilds").Store(&entries); err != nil {
+		return nil, err
+	}
+
+	guilds := make([]gtkcord.GuildInfo, len(entries))
+	for i, entry := range entries {
+		guilds[i] = gtkcord.GuildInfo{
+			ID:       discord.GuildID(entry.ID),
+			Name:     entry.Name,
+			Unread:   entry.Unread,
+			Mentions: int(entry.Mentions),
+		}
+	}
+
+	return guilds, nil
+}
+
+// Channels implements Handler.
+func (c *Client) Channels(guildID discord.GuildID) ([]gtkcord.ChannelInfo, error) {
+	var entries []entry
+	if err := c.call("ListChannels", uint64(guildID)).Store(&entries); err != nil {
+		return nil, err
+	}
+
+	chs := make([]gtkcord.ChannelInfo, len(entries))
+	for i, entry := range entries {
+		chs[i] = gtkcord.ChannelInfo{
+			ID:       discord.ChannelID(entry.ID),
+			Name:     entry.Name,
+			Unread:   entry.Unread,
+			Mentions: int(entry.Mentions),
+		}
+	}
+
+	return chs, nil
+}
+
+// MarkRead implements Handler.
+func (c *Client) MarkRead(cmd gtkcord.MarkReadCommand) error {
+	return c.call("MarkRead", uint64(cmd.ChannelID), uint64(cmd.MessageID)).Err
+}
+
+// Instance implements Handler.
+func (c *Client) Instance() (string, error) {
+	var host string
+	err := c.call("GetInstance").Store(&host)
+	return host, err
+}
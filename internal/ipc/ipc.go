@@ -0,0 +1,268 @@
+// Package ipc implements the D-Bus interface that scripts and other
+// applications use to control a running gtkcord4 instance.
+//
+// The interface is exported under the bus name and object path below on the
+// session bus. Snowflake IDs are passed as unsigned 64-bit integers, and a
+// guild ID of 0 refers to the direct messages. For example:
+//
+//	gdbus call --session \
+//		--dest xyz.krafterdev.gtkcord4_spacebar \
+//		--object-path /xyz/krafterdev/gtkcord4_spacebar \
+//		--method xyz.krafterdev.gtkcord4_spacebar.Client.GetUnreadCounts
+//
+// The server and the client both take a *dbus.Conn, so they can be used with a
+// private bus, such as one started by dbus-run-session or dbus-daemon
+// --session --print-address.
+package ipc
+
+import (
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+const (
+	// BusName is the bus name that the running instance owns.
+	BusName = "xyz.krafterdev.gtkcord4_spacebar"
+	// ObjectPath is the path of the exported object.
+	ObjectPath = "/xyz/krafterdev/gtkcord4_spacebar"
+	// Interface is the name of the exported interface.
+	Interface = BusName + ".Client"
+)
+
+// D-Bus error names returned by the methods.
+const (
+	ErrorNotLoggedIn = Interface + ".Error.NotLoggedIn"
+	ErrorInvalidArgs = "org.freedesktop.DBus.Error.InvalidArgs"
+	ErrorFailed      = "org.freedesktop.DBus.Error.Failed"
+)
+
+var (
+	// ErrNotLoggedIn is returned if the instance isn't logged in yet.
+	ErrNotLoggedIn = errors.New("not logged in")
+	// ErrInvalidArgs is returned if the arguments of a call are invalid.
+	ErrInvalidArgs = errors.New("invalid arguments")
+)
+
+const introspection = `
+<interface name="` + Interface + `">
+	<!-- OpenChannel opens the channel in the window. If message isn't 0, the
+	     message is highlighted. -->
+	<method name="OpenChannel">
+		<arg name="channel" type="t" direction="in"/>
+		<arg name="message" type="t" direction="in"/>
+	</method>
+	<!-- SendMessage sends a message and returns its ID. -->
+	<method name="SendMessage">
+		<arg name="channel" type="t" direction="in"/>
+		<arg name="content" type="s" direction="in"/>
+		<arg name="message" type="t" direction="out"/>
+	</method>
+	<!-- SetStatus sets the status to online, idle, dnd or invisible. -->
+	<method name="SetStatus">
+		<arg name="status" type="s" direction="in"/>
+	</method>
+	<!-- SetCustomStatus sets the custom status. The emoji is either a Unicode
+	     emoji or "name:id" for a custom emoji. The custom status is cleared
+	     at expires_at, in Unix seconds, unless it is 0. An empty text and
+	     emoji clears the custom status. -->
+	<method name="SetCustomStatus">
+		<arg name="text" type="s" direction="in"/>
+		<arg name="emoji" type="s" direction="in"/>
+		<arg name="expires_at" type="x" direction="in"/>
+	</method>
+	<!-- GetUnreadCounts returns the number of unread channels that aren't
+	     muted and the number of mentions. -->
+	<method name="GetUnreadCounts">
+		<arg name="unread" type="i" direction="out"/>
+		<arg name="mentions" type="i" direction="out"/>
+	</method>
+	<!-- ListGuilds returns the ID, name, unread state and mention count of
+	     each guild. -->
+	<method name="ListGuilds">
+		<arg name="guilds" type="a(tsbi)" direction="out"/>
+	</method>
+	<!-- ListChannels returns the ID, name, unread state and mention count of
+	     each channel in the guild, or of each direct message if guild is 0. -->
+	<method name="ListChannels">
+		<arg name="guild" type="t" direction="in"/>
+		<arg name="channels" type="a(tsbi)" direction="out"/>
+	</method>
+	<!-- GetInstance returns the host of the instance that the client is
+	     connected to. -->
+	<method name="GetInstance">
+		<arg name="host" type="s" direction="out"/>
+	</method>
+	<!-- MarkRead marks the channel as read up to the message, or up to the
+	     latest message if message is 0. -->
+	<method name="MarkRead">
+		<arg name="channel" type="t" direction="in"/>
+		<arg name="message" type="t" direction="in"/>
+	</method>
+</interface>`
+
+// Handler handles the calls made to the server. Its methods are called from
+// the connection's goroutine.
+type Handler interface {
+	OpenChannel(gtkcord.OpenChannelCommand) error
+	SendMessage(gtkcord.SendMessageCommand) (discord.MessageID, error)
+	SetStatus(gtkcord.SetStatusCommand) error
+	SetCustomStatus(gtkcord.SetCustomStatusCommand) error
+	UnreadCounts() (gtkcord.UnreadCounts, error)
+	Guilds() ([]gtkcord.GuildInfo, error)
+	Channels(discord.GuildID) ([]gtkcord.ChannelInfo, error)
+	MarkRead(gtkcord.MarkReadCommand) error
+	Instance() (string, error)
+}
+
+// entry is the D-Bus structure of a guild or channel in a listing.
+type entry struct {
+	ID       uint64
+	Name     string
+	Unread   bool
+	Mentions int32
+}
+
+// dbusError converts an error returned by the Handler into a D-Bus error.
+func dbusError(err error) *dbus.Error {
+	if err == nil {
+		return nil
+	}
+
+	name := ErrorFailed
+	switch {
+	case errors.Is(err, ErrNotLoggedIn):
+		name = ErrorNotLoggedIn
+	case errors.Is(err, ErrInvalidArgs):
+		name = ErrorInvalidArgs
+	}
+
+	return dbus.NewError(name, []interface{}{err.Error()})
+}
+
+// Server exports a Handler on a D-Bus connection.
+type Server struct {
+	conn *dbus.Conn
+}
+
+// serverMethods are the methods exported under Interface.
+type serverMethods struct {
+	h Handler
+}
+
+// Export exports the handler on the connection and requests BusName. It fails
+// if another instance already owns the name.
+func Export(conn *dbus.Conn, h Handler) (*Server, error) {
+	s := &Server{conn: conn}
+
+	if err := conn.Export(&serverMethods{h}, ObjectPath, Interface); err != nil {
+		return nil, errors.Wrap(err, "cannot export interface")
+	}
+
+	node := `<node>` + introspect.IntrospectDataString + introspection + `</node>`
+	conn.Export(introspect.Introspectable(node), ObjectPath, introspect.IntrospectData.Name)
+
+	reply, err := conn.RequestName(BusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		s.unexport()
+		return nil, errors.Wrap(err, "cannot request bus name")
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		s.unexport()
+		return nil, errors.New("bus name is already taken")
+	}
+
+	return s, nil
+}
+
+// Close releases the bus name and unexports the handler.
+func (s *Server) Close() {
+	s.conn.ReleaseName(BusName)
+	s.unexport()
+}
+
+func (s *Server) unexport() {
+	s.conn.Export(nil, ObjectPath, Interface)
+	s.conn.Export(nil, ObjectPath, introspect.IntrospectData.Name)
+}
+
+func (m *serverMethods) OpenChannel(chID, msgID uint64) *dbus.Error {
+	return dbusError(m.h.OpenChannel(gtkcord.OpenChannelCommand{
+		ChannelID: discord.ChannelID(chID),
+		MessageID: discord.MessageID(msgID),
+	}))
+}
+
+func (m *serverMethods) SendMessage(chID uint64, content string) (uint64, *dbus.Error) {
+	msgID, err := m.h.SendMessage(gtkcord.SendMessageCommand{
+		ChannelID: discord.ChannelID(chID),
+		Content:   content,
+	})
+	return uint64(msgID), dbusError(err)
+}
+
+func (m *serverMethods) SetStatus(status string) *dbus.Error {
+	return dbusError(m.h.SetStatus(gtkcord.SetStatusCommand{
+		Status: discord.Status(status),
+	}))
+}
+
+func (m *serverMethods) SetCustomStatus(text, emoji string, expiresAt int64) *dbus.Error {
+	cmd := gtkcord.SetCustomStatusCommand{
+		Text:  text,
+		Emoji: emoji,
+	}
+	if expiresAt > 0 {
+		cmd.ExpiresAt = time.Unix(expiresAt, 0)
+	}
+	return dbusError(m.h.SetCustomStatus(cmd))
+}
+
+func (m *serverMethods) GetUnreadCounts() (int32, int32, *dbus.Error) {
+	counts, err := m.h.UnreadCounts()
+	return int32(counts.Unread), int32(counts.Mentions), dbusError(err)
+}
+
+func (m *serverMethods) ListGuilds() ([]entry, *dbus.Error) {
+	guilds, err := m.h.Guilds()
+	if err != nil {
+		return nil, dbusError(err)
+	}
+
+	entries := make([]entry, len(guilds))
+	for i, guild := range guilds {
+		entries[i] = entry{uint64(guild.ID), guild.Name, guild.Unread, int32(guild.Mentions)}
+	}
+
+	return entries, nil
+}
+
+func (m *serverMethods) ListChannels(guildID uint64) ([]entry, *dbus.Error) {
+	chs, err := m.h.Channels(discord.GuildID(guildID))
+	if err != nil {
+		return nil, dbusError(err)
+	}
+
+	entries := make([]entry, len(chs))
+	for i, ch := range chs {
+		entries[i] = entry{uint64(ch.ID), ch.Name, ch.Unread, int32(ch.Mentions)}
+	}
+
+	return entries, nil
+}
+
+func (m *serverMethods) GetInstance() (string, *dbus.Error) {
+	host, err := m.h.Instance()
+	return host, dbusError(err)
+}
+
+func (m *serverMethods) MarkRead(chID, msgID uint64) *dbus.Error {
+	return dbusError(m.h.MarkRead(gtkcord.MarkReadCommand{
+		ChannelID: discord.ChannelID(chID),
+		MessageID: discord.MessageID(msgID),
+	}))
+}
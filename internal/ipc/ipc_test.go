@@ -0,0 +1,266 @@
+package ipc
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// privateBus starts a private session bus and returns its address. The test
+// is skipped if dbus-daemon isn't installed.
+func privateBus(t *testing.T) string {
+	t.Helper()
+
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	cmd := exec.Command(path, "--session", "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal("cannot start dbus-daemon:", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal("cannot read bus address:", err)
+	}
+
+	return strings.TrimSpace(addr)
+}
+
+func connect(t *testing.T, addr string) *dbus.Conn {
+	t.Helper()
+
+	conn, err := dbus.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if err := conn.Auth(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Hello(); err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+// fakeHandler records the commands that it receives and fails with err if
+// it's set.
+type fakeHandler struct {
+	mu    sync.Mutex
+	err   error
+	opens []gtkcord.OpenChannelCommand
+	sends []gtkcord.SendMessageCommand
+}
+
+var _ Handler = (*fakeHandler)(nil)
+
+func (h *fakeHandler) setError(err error) {
+	h.mu.Lock()
+	h.err = err
+	h.mu.Unlock()
+}
+
+func (h *fakeHandler) OpenChannel(cmd gtkcord.OpenChannelCommand) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.err != nil {
+		return h.err
+	}
+	h.opens = append(h.opens, cmd)
+	return nil
+}
+
+func (h *fakeHandler) SendMessage(cmd gtkcord.SendMessageCommand) (discord.MessageID, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.err != nil {
+		return 0, h.err
+	}
+	if cmd.Content == "" {
+		return 0, errors.Wrap(ErrInvalidArgs, "empty message")
+	}
+	h.sends = append(h.sends, cmd)
+	return discord.MessageID(1000 + len(h.sends)), nil
+}
+
+func (h *fakeHandler) SetStatus(gtkcord.SetStatusCommand) error { return h.err }
+
+func (h *fakeHandler) SetCustomStatus(gtkcord.SetCustomStatusCommand) error { return h.err }
+
+func (h *fakeHandler) UnreadCounts() (gtkcord.UnreadCounts, error) {
+	return gtkcord.UnreadCounts{}, h.err
+}
+
+func (h *fakeHandler) Guilds() ([]gtkcord.GuildInfo, error) { return nil, h.err }
+
+func (h *fakeHandler) Channels(discord.GuildID) ([]gtkcord.ChannelInfo, error) {
+	return nil, h.err
+}
+
+func (h *fakeHandler) MarkRead(gtkcord.MarkReadCommand) error { return h.err }
+
+func (h *fakeHandler) Instance() (string, error) { return "example.com", h.err }
+
+// newTestClient exports a fakeHandler on a private bus and returns a Client
+// connected to it over another connection.
+func newTestClient(t *testing.T) (*Client, *fakeHandler, *Server) {
+	t.Helper()
+
+	addr := privateBus(t)
+
+	h := &fakeHandler{}
+	server, err := Export(connect(t, addr), h)
+	if err != nil {
+		t.Fatal("cannot export:", err)
+	}
+
+	return NewClient(connect(t, addr)), h, server
+}
+
+func TestOpenChannel(t *testing.T) {
+	client, h, _ := newTestClient(t)
+
+	cmd := gtkcord.OpenChannelCommand{ChannelID: 123, MessageID: 456}
+	if err := client.OpenChannel(cmd); err != nil {
+		t.Fatal("cannot open channel:", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.opens) != 1 || h.opens[0] != cmd {
+		t.Fatalf("handler got %v, want [%v]", h.opens, cmd)
+	}
+}
+
+func TestOpenChannelNotLoggedIn(t *testing.T) {
+	client, h, _ := newTestClient(t)
+	h.setError(ErrNotLoggedIn)
+
+	err := client.OpenChannel(gtkcord.OpenChannelCommand{ChannelID: 123})
+	if !errors.Is(err, ErrNotLoggedIn) {
+		t.Fatalf("got error %v, want ErrNotLoggedIn", err)
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	client, h, _ := newTestClient(t)
+
+	cmd := gtkcord.SendMessageCommand{ChannelID: 123, Content: "hello, world"}
+	msgID, err := client.SendMessage(cmd)
+	if err != nil {
+		t.Fatal("cannot send message:", err)
+	}
+	if msgID != 1001 {
+		t.Errorf("got message ID %d, want 1001", msgID)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.sends) != 1 || h.sends[0] != cmd {
+		t.Fatalf("handler got %v, want [%v]", h.sends, cmd)
+	}
+}
+
+func TestSendMessageErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     error
+		check   func(error) bool
+	}{
+		{
+			name:    "invalid args",
+			content: "",
+			check:   func(err error) bool { return errors.Is(err, ErrInvalidArgs) },
+		},
+		{
+			name:    "not logged in",
+			content: "hi",
+			err:     ErrNotLoggedIn,
+			check:   func(err error) bool { return errors.Is(err, ErrNotLoggedIn) },
+		},
+		{
+			name:    "failed",
+			content: "hi",
+			err:     errors.New("channel not found"),
+			check: func(err error) bool {
+				var dbusErr dbus.Error
+				return errors.As(err, &dbusErr) &&
+					dbusErr.Name == ErrorFailed &&
+					strings.Contains(dbusErr.Error(), "channel not found")
+			},
+		},
+	}
+
+	client, h, _ := newTestClient(t)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h.setError(test.err)
+
+			msgID, err := client.SendMessage(gtkcord.SendMessageCommand{
+				ChannelID: 123,
+				Content:   test.content,
+			})
+			if err == nil {
+				t.Fatalf("got message ID %d, want error", msgID)
+			}
+			if !test.check(err) {
+				t.Fatalf("unexpected error %#v", err)
+			}
+		})
+	}
+}
+
+func TestExportTaken(t *testing.T) {
+	addr := privateBus(t)
+	conn := connect(t, addr)
+
+	server, err := Export(connect(t, addr), &fakeHandler{})
+	if err != nil {
+		t.Fatal("cannot export:", err)
+	}
+
+	if !IsRunning(conn) {
+		t.Fatal("instance isn't running after Export")
+	}
+
+	if _, err := Export(connect(t, addr), &fakeHandler{}); err == nil {
+		t.Fatal("second Export succeeded")
+	}
+
+	server.Close()
+
+	if IsRunning(conn) {
+		t.Fatal("instance is still running after Close")
+	}
+
+	err = NewClient(conn).OpenChannel(gtkcord.OpenChannelCommand{ChannelID: 123})
+	if err == nil {
+		t.Fatal("OpenChannel succeeded after Close")
+	}
+}
@@ -0,0 +1,239 @@
+package ipc
+
+import (
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/ningen/v3"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// StateHandler implements Handler using the state of the window's session.
+type StateHandler struct {
+	// State returns the current state, or nil if the user isn't logged in.
+	// It's called in the main thread.
+	State func() *gtkcord.State
+	// Open opens the channel in the window. It's called in the main thread.
+	Open func(gtkcord.OpenChannelCommand)
+}
+
+var _ Handler = StateHandler{}
+
+// invoke calls f in the main thread and waits for it to return.
+func invoke(f func()) {
+	done := make(chan struct{})
+	glib.IdleAdd(func() {
+		f()
+		close(done)
+	})
+	<-done
+}
+
+func (h StateHandler) state() (*gtkcord.State, error) {
+	var state *gtkcord.State
+	invoke(func() { state = h.State() })
+
+	if state == nil {
+		return nil, ErrNotLoggedIn
+	}
+	return state, nil
+}
+
+// OpenChannel implements Handler.
+func (h StateHandler) OpenChannel(cmd gtkcord.OpenChannelCommand) error {
+	state, err := h.state()
+	if err != nil {
+		return err
+	}
+
+	if _, err := state.Cabinet.Channel(cmd.ChannelID); err != nil {
+		return errors.Wrap(ErrInvalidArgs, "unknown channel")
+	}
+
+	invoke(func() { h.Open(cmd) })
+	return nil
+}
+
+// SendMessage implements Handler.
+func (h StateHandler) SendMessage(cmd gtkcord.SendMessageCommand) (discord.MessageID, error) {
+	if strings.TrimSpace(cmd.Content) == "" {
+		return 0, errors.Wrap(ErrInvalidArgs, "empty message")
+	}
+
+	state, err := h.state()
+	if err != nil {
+		return 0, err
+	}
+
+	msg, err := state.SendMessage(cmd.ChannelID, cmd.Content)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot send message")
+	}
+
+	return msg.ID, nil
+}
+
+// SetStatus implements Handler.
+func (h StateHandler) SetStatus(cmd gtkcord.SetStatusCommand) error {
+	switch cmd.Status {
+	case discord.OnlineStatus, discord.IdleStatus, discord.DoNotDisturbStatus, discord.InvisibleStatus:
+	default:
+		return errors.Wrapf(ErrInvalidArgs, "unknown status %q", cmd.Status)
+	}
+
+	state, err := h.state()
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(state.SetStatus(cmd.Status, nil), "cannot set status")
+}
+
+// SetCustomStatus implements Handler.
+func (h StateHandler) SetCustomStatus(cmd gtkcord.SetCustomStatusCommand) error {
+	var custom *gateway.CustomUserStatus
+
+	if cmd.Text != "" || cmd.Emoji != "" {
+		custom = &gateway.CustomUserStatus{
+			Text:      cmd.Text,
+			EmojiName: cmd.Emoji,
+		}
+
+		if name, id, ok := strings.Cut(cmd.Emoji, ":"); ok {
+			sf, err := discord.ParseSnowflake(id)
+			if err != nil {
+				return errors.Wrapf(ErrInvalidArgs, "invalid emoji ID %q", id)
+			}
+			custom.EmojiName = name
+			custom.EmojiID = discord.EmojiID(sf)
+		}
+
+		if !cmd.ExpiresAt.IsZero() {
+			custom.ExpiresAt = discord.NewTimestamp(cmd.ExpiresAt)
+		}
+	}
+
+	state, err := h.state()
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(state.SetCustomStatus(custom), "cannot set custom status")
+}
+
+// UnreadCounts implements Handler.
+func (h StateHandler) UnreadCounts() (gtkcord.UnreadCounts, error) {
+	state, err := h.state()
+	if err != nil {
+		return gtkcord.UnreadCounts{}, err
+	}
+
+	return state.UnreadCounts(), nil
+}
+
+// Guilds implements Handler.
+func (h StateHandler) Guilds() ([]gtkcord.GuildInfo, error) {
+	state, err := h.state()
+	if err != nil {
+		return nil, err
+	}
+
+	guilds, err := state.Cabinet.Guilds()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get guilds")
+	}
+
+	infos := make([]gtkcord.GuildInfo, len(guilds))
+	for i, guild := range guilds {
+		counts := state.GuildUnreadCounts(guild.ID)
+		infos[i] = gtkcord.GuildInfo{
+			ID:       guild.ID,
+			Name:     guild.Name,
+			Unread:   counts.Unread > 0,
+			Mentions: counts.Mentions,
+		}
+	}
+
+	return infos, nil
+}
+
+// Channels implements Handler.
+func (h StateHandler) Channels(guildID discord.GuildID) ([]gtkcord.ChannelInfo, error) {
+	state, err := h.state()
+	if err != nil {
+		return nil, err
+	}
+
+	var chs []discord.Channel
+	if guildID.IsValid() {
+		if _, err := state.Cabinet.Guild(guildID); err != nil {
+			return nil, errors.Wrap(ErrInvalidArgs, "unknown guild")
+		}
+
+		all, err := state.Cabinet.Channels(guildID)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot get channels")
+		}
+
+		chs = make([]discord.Channel, 0, len(all))
+		for _, ch := range all {
+			for _, typ := range gtkcord.AllowedChannelTypes {
+				if ch.Type == typ {
+					chs = append(chs, ch)
+					break
+				}
+			}
+		}
+	} else {
+		chs, err = state.Cabinet.PrivateChannels()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot get direct messages")
+		}
+	}
+
+	infos := make([]gtkcord.ChannelInfo, len(chs))
+	for i := range chs {
+		info := gtkcord.ChannelInfo{
+			ID:     chs[i].ID,
+			Name:   gtkcord.ChannelName(&chs[i]),
+			Unread: state.ChannelIsUnread(chs[i].ID) != ningen.ChannelRead,
+		}
+		if read := state.ReadState.ReadState(chs[i].ID); read != nil {
+			info.Mentions = read.MentionCount
+		}
+		infos[i] = info
+	}
+
+	return infos, nil
+}
+
+// MarkRead implements Handler.
+func (h StateHandler) MarkRead(cmd gtkcord.MarkReadCommand) error {
+	state, err := h.state()
+	if err != nil {
+		return err
+	}
+
+	ch, err := state.Cabinet.Channel(cmd.ChannelID)
+	if err != nil {
+		return errors.Wrap(ErrInvalidArgs, "unknown channel")
+	}
+
+	msgID := cmd.MessageID
+	if !msgID.IsValid() {
+		msgID = ch.LastMessageID
+	}
+	if msgID.IsValid() {
+		state.ReadState.MarkRead(ch.ID, msgID)
+	}
+
+	return nil
+}
+
+// Instance implements Handler.
+func (h StateHandler) Instance() (string, error) {
+	return gtkcord.InstanceHost(), nil
+}
@@ -0,0 +1,583 @@
+package message
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/cairo"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+)
+
+// audioSpeeds are the playback speeds that the speed button cycles through.
+var audioSpeeds = []float64{1, 1.25, 1.5, 2, 0.75}
+
+const (
+	// waveformBars is the number of bars drawn in the waveform.
+	waveformBars = 64
+	// waveformRate is the sample rate that audio is decoded at to draw the
+	// waveform. It doesn't need to be high, since only the peaks are used.
+	waveformRate = 4000
+	// ffmpegTimeout is the time that FFmpeg is given to decode or convert an
+	// audio file.
+	ffmpegTimeout = 30 * time.Second
+	// maxWaveformSize is the largest attachment that is decoded to draw its
+	// waveform.
+	maxWaveformSize = 50 << 20 // 50 MB
+	// maxWaveformDuration is how much of the audio is decoded to draw its
+	// waveform. Longer audio only shows the waveform of its beginning.
+	maxWaveformDuration = 10 * time.Minute
+)
+
+var (
+	ffmpegOnce  sync.Once
+	ffmpegFound bool
+)
+
+// hasFFmpeg returns true if FFmpeg is installed. Without it, audio players
+// don't have a waveform or speed controls.
+func hasFFmpeg() bool {
+	ffmpegOnce.Do(func() {
+		_, err := exec.LookPath("ffmpeg")
+		ffmpegFound = err == nil
+		if !ffmpegFound {
+			log.Println("ffmpeg not found, audio waveforms and playback speeds are disabled")
+		}
+	})
+	return ffmpegFound
+}
+
+// audioPlayer is an inline player for audio attachments. Only one audioPlayer
+// plays at a time in each View.
+type audioPlayer struct {
+	*gtk.Box
+	play     *gtk.Button
+	waveform *gtk.DrawingArea
+	time     *gtk.Label
+	speed    *gtk.Button
+
+	ctx        context.Context
+	view       *View
+	attachment *discord.Attachment
+	stream     *gtk.MediaFile
+	// uri is the URI of the audio at the current speed.
+	uri string
+
+	peaks []float64
+	// duration is the duration of the audio at normal speed, or 0 if it's not
+	// known yet.
+	duration time.Duration
+	// speedIx is the index into audioSpeeds.
+	speedIx int
+	// seekTo is the position to seek to once the stream is prepared, or -1.
+	seekTo time.Duration
+	// waveformLoaded is true once the waveform started decoding.
+	waveformLoaded bool
+}
+
+var audioPlayerCSS = cssutil.Applier("message-audio", `
+	.message-audio {
+		padding: 4px;
+		border-radius: 6px;
+		background-color: alpha(@theme_fg_color, 0.08);
+	}
+	.message-audio-name {
+		font-size: 0.9em;
+		padding: 0 4px;
+	}
+	.message-audio-time {
+		font-size: 0.85em;
+		font-feature-settings: "tnum";
+		color: alpha(@theme_fg_color, 0.75);
+		margin: 0 4px;
+	}
+	.message-audio-speed {
+		font-size: 0.85em;
+		min-width: 3em;
+	}
+`)
+
+func newAudioPlayer(ctx context.Context, view *View, attachment *discord.Attachment) *audioPlayer {
+	p := audioPlayer{
+		ctx:        ctx,
+		view:       view,
+		attachment: attachment,
+		uri:        attachment.URL,
+		seekTo:     -1,
+	}
+
+	p.play = gtk.NewButtonFromIconName("media-playback-start-symbolic")
+	p.play.AddCSSClass("circular")
+	p.play.AddCSSClass("flat")
+	p.play.SetVAlign(gtk.AlignCenter)
+	p.play.SetTooltipText(locale.Get("Play"))
+	p.play.ConnectClicked(p.togglePlaying)
+
+	p.waveform = gtk.NewDrawingArea()
+	p.waveform.SetContentHeight(32)
+	p.waveform.SetContentWidth(waveformBars * 3)
+	p.waveform.SetHExpand(true)
+	p.waveform.SetDrawFunc(p.drawWaveform)
+	p.waveform.SetCursorFromName("pointer")
+	p.bindSeeking()
+
+	p.time = gtk.NewLabel("")
+	p.time.AddCSSClass("message-audio-time")
+
+	p.speed = gtk.NewButtonWithLabel("")
+	p.speed.AddCSSClass("flat")
+	p.speed.AddCSSClass("message-audio-speed")
+	p.speed.SetVAlign(gtk.AlignCenter)
+	p.speed.SetTooltipText(locale.Get("Playback Speed"))
+	p.speed.ConnectClicked(func() {
+		p.setSpeed((p.speedIx + 1) % len(audioSpeeds))
+	})
+
+	controls := gtk.NewBox(gtk.OrientationHorizontal, 2)
+	controls.Append(p.play)
+	controls.Append(p.waveform)
+	controls.Append(p.time)
+	controls.Append(p.speed)
+
+	name := gtk.NewLabel(fmt.Sprintf(
+		"%s (%s)", attachment.Filename, humanize.Bytes(attachment.Size),
+	))
+	name.AddCSSClass("message-audio-name")
+	name.SetXAlign(0)
+	name.SetEllipsize(pango.EllipsizeMiddle)
+
+	p.Box = gtk.NewBox(gtk.OrientationVertical, 0)
+	p.Box.AddCSSClass("message-richframe")
+	p.Box.SetHAlign(gtk.AlignStart)
+	p.Box.SetSizeRequest(300, -1)
+	p.Box.Append(name)
+	p.Box.Append(controls)
+	p.Box.SetTooltipText(attachment.Filename)
+
+	// Switching channels unmaps the view, so stop playing with it.
+	p.ConnectUnmap(p.pause)
+	// Only decode the waveform once the player is actually shown.
+	p.ConnectMap(p.loadWaveform)
+
+	// Without FFmpeg, the waveform is only a progress bar.
+	p.speed.SetVisible(hasFFmpeg())
+
+	p.update()
+
+	audioPlayerCSS(p)
+	return &p
+}
+
+func (p *audioPlayer) togglePlaying() {
+	if p.stream != nil && p.stream.Playing() {
+		p.pause()
+		return
+	}
+
+	if p.stream == nil {
+		p.setStream(p.uri)
+	}
+
+	p.loadWaveform()
+	p.view.setPlayingAudio(p)
+	p.stream.Play()
+}
+
+func (p *audioPlayer) pause() {
+	if p.stream != nil {
+		p.stream.Pause()
+	}
+}
+
+// setStream replaces the current stream with one playing the given URI. The
+// position and playing state are carried over.
+func (p *audioPlayer) setStream(uri string) {
+	var playing bool
+	if p.stream != nil {
+		playing = p.stream.Playing()
+		p.seekTo = p.position()
+		p.stream.Pause()
+		p.stream.Clear()
+	}
+
+	stream := gtk.NewMediaFileForFile(gio.NewFileForURI(uri))
+	stream.NotifyProperty("prepared", func() {
+		if stream.Duration() > 0 && p.duration == 0 {
+			p.duration = p.fromStreamTime(stream.Duration())
+		}
+		if p.seekTo >= 0 && stream.IsSeekable() {
+			stream.Seek(p.toStreamTime(p.seekTo))
+			p.seekTo = -1
+		}
+		p.update()
+	})
+	stream.NotifyProperty("playing", p.update)
+	stream.NotifyProperty("timestamp", p.update)
+	stream.NotifyProperty("ended", func() {
+		if stream.GetEnded() {
+			stream.Pause()
+			stream.Seek(0)
+		}
+		p.update()
+	})
+	stream.NotifyProperty("error", func() {
+		if err := stream.Error(); err != nil {
+			app.Error(p.ctx, errors.Wrap(err, "cannot play audio"))
+		}
+	})
+
+	p.stream = stream
+	if playing {
+		p.stream.Play()
+	}
+}
+
+// position returns the current position at normal speed.
+func (p *audioPlayer) position() time.Duration {
+	if p.seekTo >= 0 {
+		return p.seekTo
+	}
+	if p.stream == nil {
+		return 0
+	}
+	return p.fromStreamTime(p.stream.Timestamp())
+}
+
+// fromStreamTime converts a timestamp in microseconds of the current stream to
+// a position at normal speed.
+func (p *audioPlayer) fromStreamTime(us int64) time.Duration {
+	return time.Duration(float64(us) * float64(time.Microsecond) * audioSpeeds[p.speedIx])
+}
+
+// toStreamTime converts a position at normal speed to a timestamp in
+// microseconds of the current stream.
+func (p *audioPlayer) toStreamTime(pos time.Duration) int64 {
+	return int64(float64(pos) / audioSpeeds[p.speedIx] / float64(time.Microsecond))
+}
+
+func (p *audioPlayer) seek(pos time.Duration) {
+	if p.stream == nil {
+		p.setStream(p.uri)
+	}
+	if p.stream.IsPrepared() && p.stream.IsSeekable() {
+		p.stream.Seek(p.toStreamTime(pos))
+	} else {
+		p.seekTo = pos
+	}
+	p.update()
+}
+
+func (p *audioPlayer) bindSeeking() {
+	seekAt := func(x float64) {
+		if p.duration == 0 {
+			return
+		}
+		frac := x / float64(p.waveform.AllocatedWidth())
+		switch {
+		case frac < 0:
+			frac = 0
+		case frac > 1:
+			frac = 1
+		}
+		p.seek(time.Duration(frac * float64(p.duration)))
+	}
+
+	var startX float64
+
+	drag := gtk.NewGestureDrag()
+	drag.SetButton(gdk.BUTTON_PRIMARY)
+	drag.ConnectDragBegin(func(x, y float64) {
+		startX = x
+		seekAt(x)
+	})
+	drag.ConnectDragUpdate(func(x, y float64) {
+		seekAt(startX + x)
+	})
+
+	p.waveform.AddController(drag)
+}
+
+// setSpeed switches the playback speed. GStreamer doesn't let us change the
+// rate through GtkMediaStream, so FFmpeg is used to make a copy of the audio
+// at the new speed.
+func (p *audioPlayer) setSpeed(ix int) {
+	speed := audioSpeeds[ix]
+	if speed == 1 {
+		p.switchSpeed(ix, p.attachment.URL)
+		return
+	}
+
+	p.speed.SetSensitive(false)
+
+	url := p.attachment.URL
+	dst := app.FromContext(p.ctx).CachePath(
+		"audio", fmt.Sprintf("%d-%s.wav", p.attachment.ID, strconv.FormatFloat(speed, 'f', -1, 64)),
+	)
+
+	gtkutil.Async(p.ctx, func() func() {
+		err := changeAudioSpeed(p.ctx, url, dst, speed)
+		return func() {
+			p.speed.SetSensitive(true)
+			if err != nil {
+				app.Error(p.ctx, errors.Wrap(err, "cannot change playback speed"))
+				return
+			}
+			p.switchSpeed(ix, gio.NewFileForPath(dst).URI())
+		}
+	})
+}
+
+func (p *audioPlayer) switchSpeed(ix int, uri string) {
+	if p.stream == nil {
+		p.speedIx = ix
+		p.uri = uri
+		p.update()
+		return
+	}
+
+	pos := p.position()
+	p.speedIx = ix
+	p.uri = uri
+	p.setStream(uri)
+	p.seekTo = pos
+	p.update()
+}
+
+func (p *audioPlayer) update() {
+	playing := p.stream != nil && p.stream.Playing()
+	if playing {
+		p.play.SetIconName("media-playback-pause-symbolic")
+		p.play.SetTooltipText(locale.Get("Pause"))
+	} else {
+		p.play.SetIconName("media-playback-start-symbolic")
+		p.play.SetTooltipText(locale.Get("Play"))
+	}
+
+	p.speed.SetLabel(strconv.FormatFloat(audioSpeeds[p.speedIx], 'f', -1, 64) + "×")
+
+	if p.duration > 0 {
+		p.time.SetText(formatDuration(p.position()) + " / " + formatDuration(p.duration))
+	} else {
+		p.time.SetText(formatDuration(p.position()))
+	}
+
+	p.waveform.QueueDraw()
+}
+
+func (p *audioPlayer) drawWaveform(_ *gtk.DrawingArea, cr *cairo.Context, w, h int) {
+	styles := p.waveform.StyleContext()
+
+	fg := styles.Color()
+	accent, ok := styles.LookupColor("accent_color")
+	if !ok {
+		accent = fg
+	}
+
+	var progress float64
+	if p.duration > 0 {
+		progress = float64(p.position()) / float64(p.duration)
+	}
+
+	peaks := p.peaks
+	if peaks == nil {
+		// Draw a flat line until the waveform is decoded.
+		peaks = make([]float64, waveformBars)
+	}
+
+	barW := float64(w) / float64(len(peaks))
+	for i, peak := range peaks {
+		barH := peak * float64(h)
+		if barH < 2 {
+			barH = 2
+		}
+
+		if (float64(i)+0.5)/float64(len(peaks)) <= progress {
+			cr.SetSourceRGBA(float64(accent.Red()), float64(accent.Green()), float64(accent.Blue()), 1)
+		} else {
+			cr.SetSourceRGBA(float64(fg.Red()), float64(fg.Green()), float64(fg.Blue()), 0.35)
+		}
+
+		cr.Rectangle(float64(i)*barW+barW*0.15, (float64(h)-barH)/2, barW*0.7, barH)
+		cr.Fill()
+	}
+}
+
+// loadWaveform decodes the audio in the background to draw its waveform. It
+// does nothing if the waveform was already loaded or can't be.
+func (p *audioPlayer) loadWaveform() {
+	if p.waveformLoaded || p.attachment.Size > maxWaveformSize || !hasFFmpeg() {
+		return
+	}
+	p.waveformLoaded = true
+
+	url := p.attachment.URL
+
+	gtkutil.Async(p.ctx, func() func() {
+		samples, err := decodeAudioSamples(p.ctx, url)
+		if err != nil {
+			log.Println("cannot decode audio for waveform:", err)
+			return nil
+		}
+
+		peaks := audioPeaks(samples, waveformBars)
+		duration := time.Duration(len(samples)) * time.Second / waveformRate
+
+		return func() {
+			p.peaks = peaks
+			if p.duration == 0 {
+				p.duration = duration
+			}
+			p.update()
+		}
+	})
+}
+
+// decodeAudioSamples decodes up to maxWaveformDuration of the audio at the
+// given URL into mono 16-bit samples at waveformRate.
+func decodeAudioSamples(ctx context.Context, url string) ([]int16, error) {
+	ctx, cancel := context.WithTimeout(ctx, ffmpegTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-loglevel", "warning", "-i", url,
+		"-t", strconv.Itoa(int(maxWaveformDuration/time.Second)),
+		"-vn", "-ac", "1", "-ar", strconv.Itoa(waveformRate),
+		"-f", "s16le", "-",
+	)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get ffmpeg stdout")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "cannot start ffmpeg")
+	}
+
+	// -t should already stop FFmpeg, but don't trust it with our memory.
+	maxBytes := int64(maxWaveformDuration/time.Second) * waveformRate * 2
+	out, err := io.ReadAll(io.LimitReader(stdout, maxBytes))
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, errors.Wrap(err, "cannot read ffmpeg output")
+	}
+
+	// Drain whatever is left so that FFmpeg can exit.
+	io.Copy(io.Discard, stdout)
+
+	if err := cmd.Wait(); err != nil {
+		return nil, errors.Wrap(err, "ffmpeg failed")
+	}
+
+	samples := make([]int16, len(out)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(out[i*2:]))
+	}
+
+	return samples, nil
+}
+
+// audioPeaks splits the samples into n buckets and returns the peak of each,
+// normalized so that the loudest bucket is 1.
+func audioPeaks(samples []int16, n int) []float64 {
+	peaks := make([]float64, n)
+	if len(samples) == 0 {
+		return peaks
+	}
+
+	var loudest float64
+	for i := range peaks {
+		start := i * len(samples) / n
+		end := (i + 1) * len(samples) / n
+
+		var peak float64
+		for _, s := range samples[start:end] {
+			v := float64(s)
+			if v < 0 {
+				v = -v
+			}
+			if v > peak {
+				peak = v
+			}
+		}
+
+		peaks[i] = peak
+		if peak > loudest {
+			loudest = peak
+		}
+	}
+
+	if loudest > 0 {
+		for i := range peaks {
+			peaks[i] /= loudest
+		}
+	}
+
+	return peaks
+}
+
+// changeAudioSpeed writes a copy of the audio at the given URL into dst with
+// its tempo changed by the given factor. The copy is reused if it exists.
+func changeAudioSpeed(ctx context.Context, url, dst string, speed float64) error {
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ffmpegTimeout)
+	defer cancel()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrap(err, "cannot create cache folder")
+	}
+
+	tmp := dst + ".tmp.wav"
+	err := exec.CommandContext(ctx, "ffmpeg",
+		"-y", "-loglevel", "warning", "-i", url,
+		"-vn", "-filter:a", "atempo="+strconv.FormatFloat(speed, 'f', -1, 64),
+		tmp,
+	).Run()
+	if err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "ffmpeg failed")
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d/time.Minute) % 60
+	s := int(d/time.Second) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// setPlayingAudio pauses the audio player that was playing before p.
+func (v *View) setPlayingAudio(p *audioPlayer) {
+	if v.audio != nil && v.audio != p {
+		v.audio.pause()
+	}
+	v.audio = p
+}
@@ -39,12 +39,19 @@ type File struct {
 	Type string // MIME type
 	Size int64
 	Open func() (io.ReadCloser, error)
+	// Prepare is called asynchronously before Open if it's not nil. It may
+	// change the file, such as the name and type of a processed image.
+	Prepare func(*File) error
+	// Cleanup is called once the file is no longer needed, which is when it's
+	// removed from the tray or after it's been sent. It may be nil.
+	Cleanup func()
 }
 
 // SendingMessage is the message created to be sent.
 type SendingMessage struct {
 	Content      string
 	Files        []File
+	Stickers     []discord.StickerItem
 	ReplyingTo   discord.MessageID
 	ReplyMention bool
 }
@@ -92,8 +99,9 @@ type View struct {
 	ctrl Controller
 	chID discord.ChannelID
 
-	rightBox   *gtk.Box
-	sendButton *gtk.Button
+	rightBox      *gtk.Box
+	sendButton    *gtk.Button
+	stickerButton *gtk.MenuButton
 
 	leftBox      *gtk.Box
 	uploadButton *gtk.Button
@@ -189,7 +197,7 @@ func NewView(ctx context.Context, ctrl Controller, chID discord.ChannelID) *View
 		revealer.SetRevealChild(start.Offset() == end.Offset())
 	})
 
-	v.UploadTray = NewUploadTray()
+	v.UploadTray = NewUploadTray(ctx)
 
 	middle := gtk.NewBox(gtk.OrientationVertical, 0)
 	middle.Append(overlay)
@@ -210,6 +218,26 @@ func NewView(ctx context.Context, ctrl Controller, chID discord.ChannelID) *View
 	v.sendButton.SetHasFrame(false)
 	v.sendButton.ConnectClicked(v.send)
 
+	var guildID discord.GuildID
+	state := gtkcord.FromContext(ctx)
+	if ch, _ := state.Cabinet.Channel(chID); ch != nil {
+		guildID = ch.GuildID
+	}
+
+	v.stickerButton = gtk.NewMenuButton()
+	v.stickerButton.AddCSSClass("composer-action")
+	v.stickerButton.SetHasFrame(false)
+	v.stickerButton.SetHAlign(gtk.AlignCenter)
+	v.stickerButton.SetVAlign(gtk.AlignCenter)
+	v.stickerButton.SetIconName(stickerIcon)
+	v.stickerButton.SetTooltipText(locale.Get("Stickers"))
+	v.stickerButton.SetPopover(NewStickerPicker(ctx, guildID, v.sendSticker))
+
+	gtkutil.Async(ctx, func() func() {
+		maxSize := state.MaxUploadSize(guildID)
+		return func() { v.UploadTray.SetMaxSize(maxSize) }
+	})
+
 	v.rightBox = gtk.NewBox(gtk.OrientationHorizontal, 0)
 	v.rightBox.AddCSSClass("composer-right-actions")
 	v.rightBox.SetHAlign(gtk.AlignEnd)
@@ -224,7 +252,6 @@ func NewView(ctx context.Context, ctrl Controller, chID discord.ChannelID) *View
 
 	v.SetPlaceholderMarkup("")
 
-	state := gtkcord.FromContext(ctx)
 	state.BindWidget(v,
 		func(ev gateway.Event) {
 			switch ev := ev.(type) {
@@ -336,8 +363,11 @@ func (v *View) setActions(actions actions) {
 
 func (v *View) resetAction() {
 	v.setActions(actions{
-		left:  []actionButton{existingActionButton{v.uploadButton}},
-		right: []actionButton{existingActionButton{v.sendButton}},
+		left: []actionButton{existingActionButton{v.uploadButton}},
+		right: []actionButton{
+			existingActionButton{v.stickerButton},
+			existingActionButton{v.sendButton},
+		},
 	})
 }
 
@@ -411,14 +441,34 @@ func (v *View) send() {
 		return
 	}
 
+	v.sendMessage(nil)
+}
+
+// sendSticker sends the sticker along with the text and files that are in the
+// composer.
+func (v *View) sendSticker(sticker discord.StickerItem) {
+	if v.state.editing {
+		return
+	}
+
+	v.sendMessage([]discord.StickerItem{sticker})
+}
+
+func (v *View) sendMessage(stickers []discord.StickerItem) {
+	// Keep everything in the composer so that the user can fix the files.
+	if v.UploadTray.HasOversized() {
+		return
+	}
+
 	text, files := v.commit()
-	if text == "" && len(files) == 0 {
+	if text == "" && len(files) == 0 && len(stickers) == 0 {
 		return
 	}
 
 	v.ctrl.SendMessage(SendingMessage{
 		Content:      text,
 		Files:        files,
+		Stickers:     stickers,
 		ReplyingTo:   v.state.id,
 		ReplyMention: v.state.replying == replyingMention,
 	})
@@ -430,7 +480,11 @@ func (v *View) send() {
 
 func (v *View) edit() {
 	editingID := v.state.id
-	text, _ := v.commit()
+	text, files := v.commit()
+	// Files can't be added to an edited message.
+	for _, file := range files {
+		cleanupFile(file)
+	}
 
 	state := gtkcord.FromContext(v.ctx)
 
@@ -524,6 +578,7 @@ func (v *View) StartReplyingTo(msg *discord.Message) {
 		},
 		right: []actionButton{
 			existingActionButton{mentionToggle},
+			existingActionButton{v.stickerButton},
 			actionButtonData{
 				Name: "Reply",
 				Icon: replyIcon,
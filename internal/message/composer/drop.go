@@ -0,0 +1,168 @@
+package composer
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/core/gioutil"
+	"github.com/diamondburned/gotk4/pkg/core/glib"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/pkg/errors"
+)
+
+const uriListMIME = "text/uri-list"
+
+// noDragAction rejects a drop when given to Finish.
+var noDragAction gdk.DragAction
+
+// maxDropTextSize is the maximum size of dropped text that is put into the
+// input.
+const maxDropTextSize = 64 * 1024
+
+// textDropMIMEs are the text MIME types that are accepted in order of
+// preference.
+var textDropMIMEs = []string{
+	"text/plain;charset=utf-8",
+	"text/plain",
+	"utf8_string",
+}
+
+// DropFormats returns the content formats that the composer accepts from drag
+// and drop. Other image types are accepted too, as long as they're offered
+// along with one of these.
+func DropFormats() *gdk.ContentFormats {
+	mimeTypes := []string{uriListMIME, "image/png", "image/jpeg", "image/gif", "image/webp"}
+	mimeTypes = append(mimeTypes, textDropMIMEs...)
+	return gdk.NewContentFormats(mimeTypes)
+}
+
+// ReadDrop reads the dropped files, image data or text into the composer.
+// Files and images are added to the upload tray, while text goes into the
+// input. The drop is finished once it's read.
+func (v *View) ReadDrop(dropper gdk.Dropper) bool {
+	drop := gdk.BaseDrop(dropper)
+	mimeTypes := drop.Formats().MIMETypes()
+
+	if containsMIME(mimeTypes, uriListMIME) {
+		v.readDrop(drop, []string{uriListMIME}, v.addDroppedURIs)
+		return true
+	}
+
+	var images []string
+	for _, mime := range mimeTypes {
+		if strings.HasPrefix(mime, "image/") {
+			images = append(images, mime)
+		}
+	}
+	if len(images) > 0 {
+		v.readDrop(drop, images, v.addDroppedImage)
+		return true
+	}
+
+	for _, mime := range textDropMIMEs {
+		if containsMIME(mimeTypes, mime) {
+			v.readDrop(drop, []string{mime}, v.addDroppedText)
+			return true
+		}
+	}
+
+	drop.Finish(noDragAction)
+	return false
+}
+
+// readDrop reads the drop as one of the given MIME types. f is called in a
+// goroutine with the stream.
+func (v *View) readDrop(drop *gdk.Drop, mimeTypes []string, f func(typ string, stream gio.InputStreamer) (func(), error)) {
+	drop.ReadAsync(v.ctx, mimeTypes, int(glib.PriorityDefault), func(res gio.AsyncResulter) {
+		typ, stream, err := drop.ReadFinish(res)
+		if err != nil {
+			drop.Finish(noDragAction)
+			app.Error(v.ctx, errors.Wrap(err, "failed to read dropped data"))
+			return
+		}
+
+		gtkutil.Async(v.ctx, func() func() {
+			done, err := f(typ, stream)
+			if err != nil {
+				return func() {
+					drop.Finish(noDragAction)
+					app.Error(v.ctx, errors.Wrap(err, "cannot read dropped data"))
+				}
+			}
+
+			return func() {
+				drop.Finish(gdk.ActionCopy)
+				done()
+			}
+		})
+	})
+}
+
+func (v *View) addDroppedURIs(typ string, stream gio.InputStreamer) (func(), error) {
+	b, err := readStream(v.ctx, stream, maxDropTextSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		files := gio.NewListStore(gio.GTypeFile)
+
+		for _, line := range strings.Split(string(b), "\n") {
+			line = strings.TrimSpace(line)
+			// Lines starting with # are comments.
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			if u, err := url.Parse(line); err != nil || u.Scheme == "" {
+				continue
+			}
+
+			files.Append(gio.NewFileForURI(line).Object)
+		}
+
+		v.addFiles(files)
+	}, nil
+}
+
+func (v *View) addDroppedImage(typ string, stream gio.InputStreamer) (func(), error) {
+	file, err := consumeStream(v.ctx, "image", typ, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() { v.UploadTray.AddFile(file) }, nil
+}
+
+func (v *View) addDroppedText(typ string, stream gio.InputStreamer) (func(), error) {
+	b, err := readStream(v.ctx, stream, maxDropTextSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		v.Input.Buffer.InsertAtCursor(string(b))
+		v.Input.GrabFocus()
+	}, nil
+}
+
+func readStream(ctx context.Context, streamer gio.InputStreamer, max int64) ([]byte, error) {
+	reader := gioutil.Reader(ctx, gio.BaseInputStream(streamer))
+	defer reader.Close()
+
+	return io.ReadAll(io.LimitReader(reader, max))
+}
+
+func containsMIME(mimeTypes []string, mime string) bool {
+	for _, m := range mimeTypes {
+		if m == mime {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,443 @@
+package composer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/pkg/errors"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ImageOptions are the options for processing an image before it's uploaded.
+// The zero value leaves the image untouched.
+type ImageOptions struct {
+	// MaxSize is the maximum width or height of the image. Larger images are
+	// downscaled to fit. 0 keeps the original size.
+	MaxSize int
+	// Quality is the JPEG quality from 1 to 100 that the image is recompressed
+	// with. PNGs are recompressed losslessly with the best compression. 0
+	// keeps the original encoding.
+	Quality int
+	// StripMetadata strips EXIF and XMP metadata from the image. This is done
+	// losslessly if the image doesn't have to be re-encoded anyway.
+	StripMetadata bool
+}
+
+// defaultJPEGQuality is the quality used when an image has to be re-encoded
+// without the user asking for a quality.
+const defaultJPEGQuality = 92
+
+// imageIsProcessable returns true if processImage can handle the given MIME
+// type.
+func imageIsProcessable(typ string) bool {
+	switch typ {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	default:
+		return false
+	}
+}
+
+// imageExtension returns the file extension for the MIME types that
+// processImage outputs.
+func imageExtension(typ string) string {
+	switch typ {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ""
+	}
+}
+
+// encodedImageType returns the MIME type that the decoded image is encoded
+// with. We can't encode WebP, so it falls back to PNG for images with
+// transparency and JPEG for everything else.
+func encodedImageType(img image.Image, typ string) string {
+	if typ != "image/webp" {
+		return typ
+	}
+	if imageOpaque(img) {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// imageOpaque returns true if the image has no transparent pixels. The color
+// model doesn't say, since lossless WebP images always decode as NRGBA.
+func imageOpaque(img image.Image) bool {
+	if img, ok := img.(interface{ Opaque() bool }); ok {
+		return img.Opaque()
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xFFFF {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func imageNeedsReencode(data []byte, typ string, opts ImageOptions) bool {
+	if opts.Quality > 0 {
+		return true
+	}
+
+	if opts.MaxSize > 0 {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err == nil && (cfg.Width > opts.MaxSize || cfg.Height > opts.MaxSize) {
+			return true
+		}
+	}
+
+	// Stripping EXIF also strips the orientation, so the image has to be
+	// rotated for real.
+	if opts.StripMetadata && typ == "image/jpeg" && jpegOrientation(data) > 1 {
+		return true
+	}
+
+	return false
+}
+
+// processImage processes the image data of the given MIME type according to
+// the options. The processed data and its MIME type are returned.
+func processImage(data []byte, typ string, opts ImageOptions) ([]byte, string, error) {
+	if opts == (ImageOptions{}) || !imageIsProcessable(typ) {
+		return data, typ, nil
+	}
+
+	if !imageNeedsReencode(data, typ, opts) {
+		if !opts.StripMetadata {
+			return data, typ, nil
+		}
+
+		stripped, err := stripMetadata(data, typ)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "cannot strip metadata")
+		}
+		return stripped, typ, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "cannot decode image")
+	}
+
+	if typ == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	if opts.MaxSize > 0 {
+		img = downscaleImage(img, opts.MaxSize)
+	}
+
+	outType := encodedImageType(img, typ)
+
+	var buf bytes.Buffer
+	switch outType {
+	case "image/jpeg":
+		quality := opts.Quality
+		if quality == 0 {
+			quality = defaultJPEGQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	case "image/png":
+		enc := png.Encoder{CompressionLevel: png.DefaultCompression}
+		if opts.Quality > 0 {
+			enc.CompressionLevel = png.BestCompression
+		}
+		err = enc.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, "", errors.Wrap(err, "cannot encode image")
+	}
+
+	return buf.Bytes(), outType, nil
+}
+
+// downscaleImage scales the image down to fit within maxSize pixels.
+func downscaleImage(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxSize && h <= maxSize {
+		return img
+	}
+
+	if w > h {
+		h = h * maxSize / w
+		w = maxSize
+	} else {
+		w = w * maxSize / h
+		h = maxSize
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Rect, img, bounds, xdraw.Src, nil)
+	return dst
+}
+
+// applyOrientation rotates and flips the image according to its EXIF
+// orientation.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+
+	// Orientations 5 to 8 swap the width and height.
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // flip horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotate 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // flip vertically
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+
+	return dst
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	xdraw.Draw(rgba, rgba.Rect, img, bounds.Min, xdraw.Src)
+	return rgba
+}
+
+// stripMetadata losslessly removes the metadata from the image.
+func stripMetadata(data []byte, typ string) ([]byte, error) {
+	switch typ {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	default:
+		return data, nil
+	}
+}
+
+// stripJPEG removes the APP1 (EXIF and XMP), APP13 (IPTC) and comment segments
+// from the JPEG. Segments that affect how the image looks, like the ICC
+// profile in APP2, are kept.
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("not a JPEG")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG marker at %d", i)
+		}
+
+		marker := data[i+1]
+		// Start of scan: the rest is image data.
+		if marker == 0xDA {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errors.New("truncated JPEG segment")
+		}
+
+		switch marker {
+		case 0xE1, 0xED, 0xFE:
+			// drop
+		default:
+			out = append(out, data[i:end]...)
+		}
+
+		i = end
+	}
+
+	return append(out, data[i:]...), nil
+}
+
+// jpegOrientation returns the EXIF orientation of the JPEG, or 0 if it has
+// none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0
+	}
+
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+		if marker == 0xDA {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			break
+		}
+
+		segment := data[i+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+
+		i = end
+	}
+
+	return 0
+}
+
+// exifOrientation reads the orientation tag from the first IFD of the TIFF
+// data.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+
+	n := int(order.Uint16(tiff[ifd:]))
+	for j := 0; j < n; j++ {
+		entry := ifd + 2 + j*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		// 0x0112 is the orientation tag, which is always a SHORT.
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 0
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// stripPNG removes the textual, time and EXIF chunks from the PNG.
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("not a PNG")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+
+	i := len(pngSignature)
+	for i+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length // length, type, data and CRC
+		if length < 0 || end > len(data) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+			// drop
+		default:
+			out = append(out, data[i:end]...)
+		}
+
+		i = end
+	}
+
+	return out, nil
+}
+
+// stripWebP removes the EXIF and XMP chunks from the WebP.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("not a WebP")
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+
+	i := 12
+	for i+8 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2 // chunks are padded to an even size
+		if end > len(data) {
+			end = len(data)
+		}
+
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+			// drop
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				// Clear the EXIF and XMP flags.
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
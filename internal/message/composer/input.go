@@ -224,7 +224,7 @@ func (i *Input) readClipboard() {
 
 	// Ignore anything text.
 	for _, mime := range mimeTypes {
-		if mimeIsText(mime) {
+		if gtkcord.MIMEIsText(mime) {
 			return
 		}
 	}
@@ -237,44 +237,52 @@ func (i *Input) readClipboard() {
 		}
 
 		gtkutil.Async(i.ctx, func() func() {
-			stream := gio.BaseInputStream(streamer)
-			reader := gioutil.Reader(i.ctx, stream)
-			defer reader.Close()
-
-			f, err := osutil.Consume(reader)
-			if err != nil {
-				app.Error(i.ctx, errors.Wrap(err, "cannot clone clipboard"))
-				return nil
-			}
-
-			s, err := f.Stat()
+			file, err := consumeStream(i.ctx, "clipboard", typ, streamer)
 			if err != nil {
-				app.Error(i.ctx, errors.Wrap(err, "cannot stat clipboard file"))
+				app.Error(i.ctx, errors.Wrap(err, "cannot read clipboard"))
 				return nil
 			}
 
-			// We're too lazy to do reference-counting, so just forbid Open from
-			// being called more than once.
-			var openedOnce bool
-
-			file := File{
-				Name: "clipboard",
-				Type: typ,
-				Size: s.Size(),
-				Open: func() (io.ReadCloser, error) {
-					if !openedOnce {
-						openedOnce = true
-						return f, nil
-					}
-					return nil, errors.New("Open called more than once on TempFile")
-				},
-			}
-
-			if exts, _ := mime.ExtensionsByType(typ); len(exts) > 0 {
-				file.Name += exts[0]
-			}
-
 			return func() { i.ctrl.PasteClipboardFile(file) }
 		})
 	})
 }
+
+// consumeStream reads the whole stream into a temporary file and returns a
+// File for it. The temporary file is removed by the File's Cleanup. The
+// extension of name is guessed from the MIME type. It must be called in a
+// goroutine.
+func consumeStream(ctx context.Context, name, typ string, streamer gio.InputStreamer) (File, error) {
+	stream := gio.BaseInputStream(streamer)
+	reader := gioutil.Reader(ctx, stream)
+	defer reader.Close()
+
+	f, err := osutil.Consume(reader)
+	if err != nil {
+		return File{}, errors.Wrap(err, "cannot clone stream")
+	}
+
+	s, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return File{}, errors.Wrap(err, "cannot stat file")
+	}
+
+	file := File{
+		Name: name,
+		Type: typ,
+		Size: s.Size(),
+		Open: func() (io.ReadCloser, error) {
+			return f.Open()
+		},
+		Cleanup: func() {
+			f.Close()
+		},
+	}
+
+	if exts, _ := mime.ExtensionsByType(typ); len(exts) > 0 {
+		file.Name += exts[0]
+	}
+
+	return file, nil
+}
@@ -0,0 +1,262 @@
+package composer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/sticker"
+)
+
+const stickerIcon = "emoji-objects-symbolic"
+
+const stickerPickerSize = 80 // px
+
+// StickerPicker is a popover that lists the guild stickers that the user can
+// send.
+type StickerPicker struct {
+	*gtk.Popover
+	search *gtk.SearchEntry
+	flow   *gtk.FlowBox
+	stack  *gtk.Stack
+	status *gtk.Label
+
+	ctx     context.Context
+	guildID discord.GuildID
+	loading bool
+	loaded  bool
+
+	stickers []pickerSticker
+	onPick   func(discord.StickerItem)
+}
+
+type pickerSticker struct {
+	discord.Sticker
+	guild  string
+	search string // lowercase name and tags
+}
+
+var stickerPickerCSS = cssutil.Applier("composer-sticker-picker", `
+	.composer-sticker-picker flowboxchild {
+		padding: 4px;
+		border-radius: 6px;
+	}
+	.composer-sticker-picker-status {
+		padding: 24px;
+		color: alpha(@theme_fg_color, 0.75);
+	}
+`)
+
+// NewStickerPicker creates a new sticker picker for the given guild. onPick is
+// called when the user picks a sticker.
+func NewStickerPicker(ctx context.Context, guildID discord.GuildID, onPick func(discord.StickerItem)) *StickerPicker {
+	p := StickerPicker{
+		ctx:     ctx,
+		guildID: guildID,
+		onPick:  onPick,
+	}
+
+	p.search = gtk.NewSearchEntry()
+	p.search.SetObjectProperty("placeholder-text", locale.Get("Search stickers"))
+	p.search.ConnectSearchChanged(func() { p.flow.InvalidateFilter() })
+	p.search.ConnectActivate(p.pickFirst)
+
+	p.flow = gtk.NewFlowBox()
+	p.flow.SetSelectionMode(gtk.SelectionNone)
+	p.flow.SetActivateOnSingleClick(true)
+	p.flow.SetHomogeneous(true)
+	p.flow.SetMinChildrenPerLine(4)
+	p.flow.SetMaxChildrenPerLine(4)
+	p.flow.SetVAlign(gtk.AlignStart)
+	p.flow.SetFilterFunc(p.filter)
+	p.flow.ConnectChildActivated(func(child *gtk.FlowBoxChild) {
+		p.pick(child.Index())
+	})
+
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scroll.SetMinContentHeight(300)
+	scroll.SetChild(p.flow)
+
+	p.status = gtk.NewLabel("")
+	p.status.AddCSSClass("composer-sticker-picker-status")
+	p.status.SetWrap(true)
+
+	spinner := gtk.NewSpinner()
+	spinner.SetSizeRequest(32, 32)
+	spinner.Start()
+
+	p.stack = gtk.NewStack()
+	p.stack.AddNamed(spinner, "loading")
+	p.stack.AddNamed(p.status, "status")
+	p.stack.AddNamed(scroll, "stickers")
+	p.stack.SetVisibleChildName("loading")
+
+	box := gtk.NewBox(gtk.OrientationVertical, 6)
+	box.SetSizeRequest(4*(stickerPickerSize+12), -1)
+	box.Append(p.search)
+	box.Append(p.stack)
+
+	p.Popover = gtk.NewPopover()
+	p.Popover.SetChild(box)
+	p.Popover.ConnectShow(func() {
+		p.load()
+		p.search.GrabFocus()
+	})
+	p.Popover.ConnectClosed(func() { p.search.SetText("") })
+
+	stickerPickerCSS(p)
+	return &p
+}
+
+// load fetches the stickers of all guilds that the user can send stickers
+// from. Stickers from other guilds need Nitro. If fetching fails, it's tried
+// again the next time the picker is opened.
+func (p *StickerPicker) load() {
+	if p.loaded || p.loading {
+		return
+	}
+	p.loading = true
+	p.stack.SetVisibleChildName("loading")
+
+	state := gtkcord.FromContext(p.ctx)
+
+	guildIDs := []discord.GuildID{}
+	if p.guildID.IsValid() {
+		guildIDs = append(guildIDs, p.guildID)
+	}
+
+	if state.EmojiState.HasNitro() {
+		guilds, _ := state.Cabinet.Guilds()
+		for _, guild := range guilds {
+			if guild.ID != p.guildID {
+				guildIDs = append(guildIDs, guild.ID)
+			}
+		}
+	}
+
+	gtkutil.Async(p.ctx, func() func() {
+		var stickers []pickerSticker
+		var lastErr error
+
+		for _, guildID := range guildIDs {
+			guildStickers, err := state.GuildStickers(guildID)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			var guildName string
+			if guild, _ := state.Cabinet.Guild(guildID); guild != nil {
+				guildName = guild.Name
+			}
+
+			for _, s := range guildStickers {
+				if !s.Available {
+					continue
+				}
+				stickers = append(stickers, pickerSticker{
+					Sticker: s,
+					guild:   guildName,
+					search:  strings.ToLower(s.Name + " " + strings.Join(s.TagList(), " ")),
+				})
+			}
+		}
+
+		return func() {
+			p.loading = false
+			p.loaded = lastErr == nil
+			p.setStickers(stickers, lastErr)
+		}
+	})
+}
+
+func (p *StickerPicker) setStickers(stickers []pickerSticker, err error) {
+	p.stickers = stickers
+
+	for child := p.flow.ChildAtIndex(0); child != nil; child = p.flow.ChildAtIndex(0) {
+		p.flow.Remove(child)
+	}
+
+	if len(stickers) == 0 {
+		if err != nil {
+			p.status.SetText(locale.Get("Cannot load stickers: ") + err.Error())
+		} else {
+			p.status.SetText(locale.Get("No stickers available."))
+		}
+		p.stack.SetVisibleChildName("status")
+		return
+	}
+
+	for _, s := range stickers {
+		picture := sticker.NewPicture(p.ctx, stickerPickerSize)
+		picture.SetSticker(discord.StickerItem{
+			ID:         s.ID,
+			Name:       s.Name,
+			FormatType: s.FormatType,
+		})
+		picture.EnableAnimation().OnHover()
+
+		child := gtk.NewFlowBoxChild()
+		child.SetChild(picture)
+		if s.guild != "" {
+			child.SetTooltipText(s.Name + " (" + s.guild + ")")
+		} else {
+			child.SetTooltipText(s.Name)
+		}
+
+		p.flow.Insert(child, -1)
+	}
+
+	p.stack.SetVisibleChildName("stickers")
+}
+
+func (p *StickerPicker) filter(child *gtk.FlowBoxChild) bool {
+	query := strings.ToLower(strings.TrimSpace(p.search.Text()))
+	if query == "" {
+		return true
+	}
+
+	i := child.Index()
+	if i < 0 || i >= len(p.stickers) {
+		return false
+	}
+
+	for _, word := range strings.Fields(query) {
+		if !strings.Contains(p.stickers[i].search, word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pickFirst picks the first sticker that matches the search.
+func (p *StickerPicker) pickFirst() {
+	for i := range p.stickers {
+		child := p.flow.ChildAtIndex(i)
+		if child != nil && p.filter(child) {
+			p.pick(i)
+			return
+		}
+	}
+}
+
+func (p *StickerPicker) pick(i int) {
+	if i < 0 || i >= len(p.stickers) {
+		return
+	}
+
+	s := p.stickers[i]
+	p.Popdown()
+	p.onPick(discord.StickerItem{
+		ID:         s.ID,
+		Name:       s.Name,
+		FormatType: s.FormatType,
+	})
+}
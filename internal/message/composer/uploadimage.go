@@ -0,0 +1,377 @@
+package composer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	xdraw "golang.org/x/image/draw"
+)
+
+// maxProcessSize is the largest image that is loaded into memory for
+// processing. Larger images are uploaded as-is.
+const maxProcessSize = 64 * 1024 * 1024
+
+// uploadThumbnailSize is the size of the thumbnail shown when hovering over an
+// image in the upload tray.
+const uploadThumbnailSize = 200
+
+// imageMaxSizes are the choices for the maximum image dimension. 0 keeps the
+// original size.
+var imageMaxSizes = []int{0, 4096, 2560, 1920, 1280}
+
+// imageFitSteps are the options that are tried in order when an image has to
+// be compressed to fit within the upload limit.
+var imageFitSteps = []ImageOptions{
+	{MaxSize: 4096, Quality: 90},
+	{MaxSize: 2560, Quality: 85},
+	{MaxSize: 1920, Quality: 80},
+	{MaxSize: 1280, Quality: 75},
+	{MaxSize: 1280, Quality: 50},
+}
+
+var uploadImageCSS = cssutil.Applier("composer-upload-image", `
+	.composer-upload-image-options {
+		padding: 6px;
+	}
+	.composer-upload-image-sizes {
+		color: alpha(@theme_fg_color, 0.75);
+	}
+`)
+
+// uploadImage holds an image in the upload tray along with the options to
+// process it with before it's uploaded.
+type uploadImage struct {
+	ctx  context.Context
+	file File
+	opts ImageOptions
+
+	options    *gtk.MenuButton
+	strip      *gtk.CheckButton
+	maxSize    *gtk.DropDown
+	recompress *gtk.CheckButton
+	quality    *gtk.Scale
+	sizes      *gtk.Label
+	thumbnail  gdk.Paintabler
+	onUpdate   func(size int64)
+
+	// gen is incremented every time the options change, so that stale
+	// previews are discarded.
+	gen int
+	// setting is true while setOptions is updating the widgets.
+	setting bool
+
+	load struct {
+		sync.Once
+		data []byte
+		err  error
+	}
+}
+
+// newUploadImage creates an uploadImage for the given file. Nil is returned if
+// the file can't be processed. onUpdate is called with the processed size.
+func newUploadImage(ctx context.Context, file File, onUpdate func(size int64)) *uploadImage {
+	if !imageIsProcessable(file.Type) || file.Size > maxProcessSize {
+		return nil
+	}
+
+	u := uploadImage{
+		ctx:      ctx,
+		file:     file,
+		onUpdate: onUpdate,
+		opts:     ImageOptions{StripMetadata: true},
+	}
+
+	u.strip = gtk.NewCheckButtonWithLabel(locale.Get("Strip Metadata"))
+	u.strip.SetTooltipText(locale.Get("Remove EXIF and XMP metadata, such as the location the photo was taken at"))
+	u.strip.SetActive(u.opts.StripMetadata)
+	u.strip.ConnectToggled(func() {
+		u.opts.StripMetadata = u.strip.Active()
+		u.update()
+	})
+
+	sizeLabels := make([]string, len(imageMaxSizes))
+	for i, size := range imageMaxSizes {
+		if size == 0 {
+			sizeLabels[i] = locale.Get("Original Size")
+		} else {
+			sizeLabels[i] = fmt.Sprintf("%d px", size)
+		}
+	}
+
+	u.maxSize = gtk.NewDropDownFromStrings(sizeLabels)
+	u.maxSize.SetTooltipText(locale.Get("Downscale the image to fit within this size"))
+	u.maxSize.NotifyProperty("selected", func() {
+		u.opts.MaxSize = imageMaxSizes[u.maxSize.Selected()]
+		u.update()
+	})
+
+	sizeBox := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	sizeBox.Append(gtk.NewLabel(locale.Get("Max Size")))
+	sizeBox.Append(u.maxSize)
+
+	u.quality = gtk.NewScaleWithRange(gtk.OrientationHorizontal, 10, 100, 5)
+	u.quality.SetHExpand(true)
+	u.quality.SetDrawValue(true)
+	u.quality.SetValue(85)
+	u.quality.SetSensitive(false)
+
+	u.recompress = gtk.NewCheckButtonWithLabel(locale.Get("Recompress"))
+	u.recompress.SetTooltipText(locale.Get("Re-encode the image with the given JPEG quality"))
+	u.recompress.ConnectToggled(func() {
+		u.quality.SetSensitive(u.recompress.Active())
+		u.setQuality(u.recompress.Active(), u.quality.Value())
+	})
+
+	// Wait for the slider to settle before reprocessing.
+	qualityTimer := glib.SourceHandle(0)
+	u.quality.ConnectValueChanged(func() {
+		if qualityTimer != 0 {
+			glib.SourceRemove(qualityTimer)
+		}
+		qualityTimer = glib.TimeoutAdd(300, func() {
+			qualityTimer = 0
+			u.setQuality(u.recompress.Active(), u.quality.Value())
+		})
+	})
+
+	u.sizes = gtk.NewLabel("")
+	u.sizes.AddCSSClass("composer-upload-image-sizes")
+	u.sizes.SetXAlign(0)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 6)
+	box.AddCSSClass("composer-upload-image-options")
+	box.Append(u.strip)
+	box.Append(sizeBox)
+	box.Append(u.recompress)
+	box.Append(u.quality)
+	box.Append(u.sizes)
+
+	popover := gtk.NewPopover()
+	popover.SetChild(box)
+
+	u.options = gtk.NewMenuButton()
+	u.options.SetIconName("emblem-system-symbolic")
+	u.options.SetHasFrame(false)
+	u.options.SetTooltipText(locale.Get("Image Options"))
+	u.options.SetPopover(popover)
+	uploadImageCSS(u.options)
+
+	u.update()
+
+	gtkutil.Async(ctx, func() func() {
+		thumb := u.newThumbnail()
+		if thumb == nil {
+			return nil
+		}
+
+		return func() {
+			u.thumbnail = gdk.NewMemoryTexture(
+				thumb.Rect.Dx(), thumb.Rect.Dy(),
+				gdk.MemoryR8G8B8A8Premultiplied,
+				glib.NewBytesWithGo(thumb.Pix),
+				uint(thumb.Stride),
+			)
+		}
+	})
+
+	return &u
+}
+
+func (u *uploadImage) setQuality(enabled bool, quality float64) {
+	if enabled {
+		u.opts.Quality = int(quality)
+	} else {
+		u.opts.Quality = 0
+	}
+	u.update()
+}
+
+// update processes the image with the current options in the background and
+// shows the resulting size.
+func (u *uploadImage) update() {
+	if u.setting {
+		return
+	}
+
+	u.gen++
+	gen := u.gen
+	opts := u.opts
+
+	u.sizes.SetText(locale.Get("Processing…"))
+
+	gtkutil.Async(u.ctx, func() func() {
+		data, _, err := u.process(opts)
+
+		return func() {
+			if gen != u.gen {
+				return
+			}
+
+			if err != nil {
+				u.sizes.SetText(locale.Get("Cannot process image: ") + err.Error())
+				return
+			}
+
+			u.sizes.SetText(fmt.Sprintf(
+				"%s → %s",
+				humanize.Bytes(uint64(u.file.Size)), humanize.Bytes(uint64(len(data))),
+			))
+			u.onUpdate(int64(len(data)))
+		}
+	})
+}
+
+// setOptions sets the options and updates the widgets to match.
+func (u *uploadImage) setOptions(opts ImageOptions) {
+	u.setting = true
+
+	u.strip.SetActive(opts.StripMetadata)
+	for i, size := range imageMaxSizes {
+		if size == opts.MaxSize {
+			u.maxSize.SetSelected(uint(i))
+		}
+	}
+	u.recompress.SetActive(opts.Quality > 0)
+	if opts.Quality > 0 {
+		u.quality.SetValue(float64(opts.Quality))
+	}
+
+	u.setting = false
+
+	u.opts = opts
+	u.update()
+}
+
+// fitWithin finds the options that make the image fit within maxSize bytes
+// and applies them. done is called with an error if the image can't be made
+// small enough.
+func (u *uploadImage) fitWithin(maxSize int64, done func(error)) {
+	strip := u.opts.StripMetadata
+
+	gtkutil.Async(u.ctx, func() func() {
+		for _, opts := range imageFitSteps {
+			opts.StripMetadata = strip
+
+			data, _, err := u.process(opts)
+			if err != nil {
+				return func() { done(err) }
+			}
+
+			if int64(len(data)) <= maxSize {
+				size := int64(len(data))
+				return func() {
+					u.setOptions(opts)
+					u.onUpdate(size)
+					done(nil)
+				}
+			}
+		}
+
+		return func() { done(errors.New("image is still too large")) }
+	})
+}
+
+// original returns the original image data. The file is only read once, since
+// files from the clipboard can only be opened once.
+func (u *uploadImage) original() ([]byte, error) {
+	u.load.Do(func() {
+		r, err := u.file.Open()
+		if err != nil {
+			u.load.err = err
+			return
+		}
+		defer r.Close()
+
+		u.load.data, u.load.err = io.ReadAll(io.LimitReader(r, maxProcessSize))
+	})
+	return u.load.data, u.load.err
+}
+
+func (u *uploadImage) process(opts ImageOptions) ([]byte, string, error) {
+	data, err := u.original()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "cannot read image")
+	}
+	return processImage(data, u.file.Type, opts)
+}
+
+// newThumbnail creates the thumbnail image. It's meant to be called in a
+// goroutine.
+func (u *uploadImage) newThumbnail() *image.RGBA {
+	data, err := u.original()
+	if err != nil {
+		return nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+
+	if u.file.Type == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > h {
+		w, h = uploadThumbnailSize, h*uploadThumbnailSize/w
+	} else {
+		w, h = w*uploadThumbnailSize/h, uploadThumbnailSize
+	}
+
+	thumb := image.NewRGBA(image.Rect(0, 0, max1(w), max1(h)))
+	xdraw.ApproxBiLinear.Scale(thumb, thumb.Rect, img, bounds, xdraw.Src, nil)
+
+	return thumb
+}
+
+func max1(v int) int {
+	if v < 1 {
+		return 1
+	}
+	return v
+}
+
+// File returns the file to upload. The image is processed with the current
+// options when the file is prepared, since its type may change.
+func (u *uploadImage) File() File {
+	file := u.file
+	opts := u.opts
+
+	file.Prepare = func(f *File) error {
+		data, typ, err := u.process(opts)
+		if err != nil {
+			return err
+		}
+
+		if typ != f.Type {
+			ext := path.Ext(f.Name)
+			f.Name = strings.TrimSuffix(f.Name, ext) + imageExtension(typ)
+			f.Type = typ
+		}
+
+		f.Size = int64(len(data))
+		f.Open = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+
+		return nil
+	}
+
+	return file
+}
@@ -1,35 +1,44 @@
 package composer
 
 import (
+	"context"
 	"fmt"
 	"html"
+	"io"
+	"log"
 	"strings"
 
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotk4/pkg/pango"
 	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/dustin/go-humanize"
 )
 
-func mimeIsText(mime string) bool {
-	// How is utf8_string a valid MIME type? GTK, what the fuck?
-	return strings.HasPrefix(mime, "text") || mime == "utf8_string"
-}
-
 // UploadTray is the tray holding files to be uploaded.
 type UploadTray struct {
 	*gtk.Box
-	files []uploadFile
+	ctx     context.Context
+	files   []*uploadFile
+	maxSize int64
 }
 
 type uploadFile struct {
 	*gtk.Box
-	icon *gtk.Image
-	name *gtk.Label
-	del  *gtk.Button
+	row     *gtk.Box
+	icon    *gtk.Image
+	name    *gtk.Label
+	spoiler *gtk.ToggleButton
+	del     *gtk.Button
+
+	warning      *gtk.Box
+	warningLabel *gtk.Label
+	compress     *gtk.Button
 
-	file File
+	file  File
+	size  int64        // size after processing
+	image *uploadImage // nil if not a processable image
 }
 
 var uploadTrayCSS = cssutil.Applier("composer-upload-tray", `
@@ -39,19 +48,55 @@ var uploadTrayCSS = cssutil.Applier("composer-upload-tray", `
 		margin-bottom: 1px;
 		margin-right:  6px;
 	}
+	.composer-upload-warning {
+		margin-left: 22px;
+		margin-bottom: 4px;
+	}
+	.composer-upload-warning > label {
+		color: @error_color;
+		font-size: 0.9em;
+	}
+	.composer-upload-warning > button {
+		min-height: 0;
+		padding: 2px 8px;
+	}
 `)
 
 // NewUploadTray creates a new UploadTray.
-func NewUploadTray() *UploadTray {
-	t := UploadTray{}
+func NewUploadTray(ctx context.Context) *UploadTray {
+	t := UploadTray{ctx: ctx}
 	t.Box = gtk.NewBox(gtk.OrientationVertical, 0)
+	// The files are never sent once the composer is gone, so remove them.
+	t.Box.ConnectUnrealize(t.removeAll)
 	uploadTrayCSS(t.Box)
 	return &t
 }
 
+// SetMaxSize sets the maximum size of each file. Files larger than this are
+// warned about. 0 disables the check.
+func (t *UploadTray) SetMaxSize(maxSize int64) {
+	t.maxSize = maxSize
+	for _, f := range t.files {
+		t.validate(f)
+	}
+}
+
+// HasOversized returns true if the tray has files that are larger than the
+// maximum size. The warnings of these files are flashed.
+func (t *UploadTray) HasOversized() bool {
+	var oversized bool
+	for _, f := range t.files {
+		if t.isOversized(f) {
+			f.warning.ErrorBell()
+			oversized = true
+		}
+	}
+	return oversized
+}
+
 // AddFile adds a file into the tray.
 func (t *UploadTray) AddFile(file File) {
-	f := uploadFile{file: file}
+	f := &uploadFile{file: file, size: file.Size}
 
 	f.icon = gtk.NewImageFromIconName(mimeIcon(file.Type))
 
@@ -60,29 +105,196 @@ func (t *UploadTray) AddFile(file File) {
 	f.name.SetXAlign(0)
 	f.name.SetHExpand(true)
 
-	if file.Size > 0 {
-		f.name.SetMarkup(fmt.Sprintf(
-			`%s <span size="small" alpha="85%%">%s</span>`,
-			html.EscapeString(file.Name), humanize.Bytes(uint64(file.Size)),
-		))
-	}
+	setFileSize(f.name, file, file.Size)
+
+	f.image = newUploadImage(t.ctx, file, func(size int64) {
+		f.size = size
+		setFileSize(f.name, f.file, size)
+		t.validate(f)
+	})
+
+	f.spoiler = gtk.NewToggleButton()
+	f.spoiler.SetIconName("view-conceal-symbolic")
+	f.spoiler.SetHasFrame(false)
+	f.spoiler.SetTooltipText(locale.Get("Mark as Spoiler"))
 
 	f.del = gtk.NewButtonFromIconName("edit-clear-all-symbolic")
 	f.del.SetHasFrame(false)
 	f.del.SetTooltipText(locale.Get("Remove File"))
 
-	// TODO: hover to preview?
-	f.Box = gtk.NewBox(gtk.OrientationHorizontal, 0)
-	f.Box.AddCSSClass("composer-upload-item")
-	f.Box.SetHExpand(true)
-	f.Box.Append(f.icon)
-	f.Box.Append(f.name)
-	f.Box.Append(f.del)
+	f.row = gtk.NewBox(gtk.OrientationHorizontal, 0)
+	f.row.AddCSSClass("composer-upload-item")
+	f.row.SetHExpand(true)
+	f.row.Append(f.icon)
+	f.row.Append(f.name)
+	if f.image != nil {
+		f.row.Append(f.image.options)
+		f.bindThumbnail()
+	}
+	f.row.Append(f.spoiler)
+	f.row.Append(f.del)
+
+	f.warningLabel = gtk.NewLabel("")
+	f.warningLabel.SetXAlign(0)
+	f.warningLabel.SetHExpand(true)
+	f.warningLabel.SetWrap(true)
+
+	f.compress = gtk.NewButtonWithLabel(locale.Get("Compress"))
+	f.compress.ConnectClicked(func() { t.compress(f) })
+
+	remove := gtk.NewButtonWithLabel(locale.Get("Remove"))
+	remove.ConnectClicked(t.bindDelete(f))
+
+	f.warning = gtk.NewBox(gtk.OrientationHorizontal, 6)
+	f.warning.AddCSSClass("composer-upload-warning")
+	f.warning.SetVisible(false)
+	f.warning.Append(f.warningLabel)
+	f.warning.Append(f.compress)
+	f.warning.Append(remove)
+
+	f.Box = gtk.NewBox(gtk.OrientationVertical, 0)
+	f.Box.AddCSSClass("composer-upload-file")
+	f.Box.Append(f.row)
+	f.Box.Append(f.warning)
 
 	t.Box.Append(f)
 	t.files = append(t.files, f)
+	t.validate(f)
 
 	f.del.ConnectClicked(t.bindDelete(f))
+
+	if file.Size <= 0 {
+		t.measure(f)
+	}
+}
+
+// measure finds the size of a file whose size isn't known, such as one that
+// isn't a local file, by reading it.
+func (t *UploadTray) measure(f *uploadFile) {
+	file := f.file
+
+	gtkutil.Async(t.ctx, func() func() {
+		r, err := file.Open()
+		if err != nil {
+			log.Printf("cannot open %q to measure it: %v", file.Name, err)
+			return nil
+		}
+		defer r.Close()
+
+		size, err := io.Copy(io.Discard, r)
+		if err != nil {
+			log.Printf("cannot measure %q: %v", file.Name, err)
+			return nil
+		}
+
+		return func() {
+			// The file may have been compressed in the meantime.
+			if f.file.Size > 0 {
+				return
+			}
+			f.file.Size = size
+			f.size = size
+			setFileSize(f.name, f.file, size)
+			t.validate(f)
+		}
+	})
+}
+
+func (t *UploadTray) isOversized(f *uploadFile) bool {
+	return t.maxSize > 0 && f.size > t.maxSize
+}
+
+// validate shows or hides the warning of the file depending on whether it's
+// too large.
+func (t *UploadTray) validate(f *uploadFile) {
+	if !t.isOversized(f) {
+		f.warning.SetVisible(false)
+		return
+	}
+
+	f.warningLabel.SetText(fmt.Sprintf(
+		locale.Get("Larger than the upload limit of %s."),
+		humanize.Bytes(uint64(t.maxSize)),
+	))
+	f.compress.SetVisible(f.image != nil || canCompressVideo(f.file))
+	f.warning.SetVisible(true)
+}
+
+// compress compresses the file so that it fits within the maximum size.
+func (t *UploadTray) compress(f *uploadFile) {
+	f.compress.SetSensitive(false)
+	f.warningLabel.SetText(locale.Get("Compressing…"))
+
+	done := func(err error) {
+		f.compress.SetSensitive(true)
+		t.validate(f)
+		if err != nil {
+			f.warningLabel.SetText(locale.Get("Cannot compress: ") + err.Error())
+		}
+	}
+
+	if f.image != nil {
+		f.image.fitWithin(t.maxSize, done)
+		return
+	}
+
+	maxSize := t.maxSize
+	file := f.file
+
+	gtkutil.Async(t.ctx, func() func() {
+		compressed, err := compressVideo(t.ctx, file, maxSize)
+		return func() {
+			if err == nil && !t.has(f) {
+				// The file was removed while it was being compressed.
+				cleanupFile(compressed)
+				return
+			}
+			if err == nil {
+				cleanupFile(f.file)
+				f.file = compressed
+				f.size = compressed.Size
+				setFileSize(f.name, compressed, compressed.Size)
+			}
+			done(err)
+		}
+	})
+}
+
+// setFileSize shows the file name along with the given size.
+func setFileSize(label *gtk.Label, file File, size int64) {
+	if size <= 0 {
+		return
+	}
+
+	var sizeText string
+	if size != file.Size {
+		sizeText = fmt.Sprintf(
+			"%s → %s",
+			humanize.Bytes(uint64(file.Size)), humanize.Bytes(uint64(size)),
+		)
+	} else {
+		sizeText = humanize.Bytes(uint64(size))
+	}
+
+	label.SetMarkup(fmt.Sprintf(
+		`%s <span size="small" alpha="85%%">%s</span>`,
+		html.EscapeString(file.Name), sizeText,
+	))
+}
+
+// bindThumbnail shows the image's thumbnail when the file is hovered over.
+func (f *uploadFile) bindThumbnail() {
+	f.row.SetHasTooltip(true)
+	f.row.ConnectQueryTooltip(func(x, y int, keyboard bool, tooltip *gtk.Tooltip) bool {
+		if f.image.thumbnail == nil {
+			return false
+		}
+
+		picture := gtk.NewPictureForPaintable(f.image.thumbnail)
+		picture.SetCanShrink(false)
+		tooltip.SetCustom(picture)
+		return true
+	})
 }
 
 func mimeIcon(mime string) string {
@@ -102,23 +314,56 @@ func mimeIcon(mime string) string {
 	}
 }
 
-func (t *UploadTray) bindDelete(this uploadFile) func() {
+func (t *UploadTray) bindDelete(this *uploadFile) func() {
 	return func() {
 		for i, f := range t.files {
-			if f.Box == this.Box {
+			if f == this {
 				t.Box.Remove(t.files[i])
 				t.files = append(t.files[:i], t.files[i+1:]...)
+				cleanupFile(f.file)
 				return
 			}
 		}
 	}
 }
 
-// Clear clears the tray and returns the list of paths that it held.
+func (t *UploadTray) has(this *uploadFile) bool {
+	for _, f := range t.files {
+		if f == this {
+			return true
+		}
+	}
+	return false
+}
+
+// removeAll removes all files from the tray without sending them.
+func (t *UploadTray) removeAll() {
+	for _, f := range t.files {
+		t.Box.Remove(f)
+		cleanupFile(f.file)
+	}
+	t.files = nil
+}
+
+func cleanupFile(file File) {
+	if file.Cleanup != nil {
+		file.Cleanup()
+	}
+}
+
+// Clear clears the tray and returns the list of paths that it held. Files
+// marked as spoilers are prefixed with SPOILER_, which is how Discord knows to
+// hide them.
 func (t *UploadTray) Clear() []File {
 	paths := make([]File, len(t.files))
 	for i, file := range t.files {
 		paths[i] = file.file
+		if file.image != nil {
+			paths[i] = file.image.File()
+		}
+		if file.spoiler.Active() && !strings.HasPrefix(paths[i].Name, "SPOILER_") {
+			paths[i].Name = "SPOILER_" + paths[i].Name
+		}
 		t.Remove(file)
 	}
 
@@ -0,0 +1,130 @@
+package composer
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// audioBitrate is the bitrate of the audio in compressed videos in bits per
+// second.
+const audioBitrate = 96_000
+
+// compressVideo re-encodes the video with FFmpeg so that it fits within
+// maxSize bytes. The returned file is backed by a temporary file that is
+// removed by its Cleanup. It must be called in a goroutine.
+func compressVideo(ctx context.Context, file File, maxSize int64) (File, error) {
+	in, err := os.CreateTemp("", "gtkcord4-video-*"+path.Ext(file.Name))
+	if err != nil {
+		return File{}, errors.Wrap(err, "cannot create temporary file")
+	}
+	defer os.Remove(in.Name())
+
+	r, err := file.Open()
+	if err != nil {
+		in.Close()
+		return File{}, errors.Wrap(err, "cannot open video")
+	}
+
+	_, err = io.Copy(in, r)
+	r.Close()
+	in.Close()
+	if err != nil {
+		return File{}, errors.Wrap(err, "cannot copy video")
+	}
+
+	duration, err := videoDuration(ctx, in.Name())
+	if err != nil {
+		return File{}, err
+	}
+
+	// Leave some room for the container overhead.
+	bitrate := int64(float64(maxSize*8)*0.9/duration) - audioBitrate
+	if bitrate < 100_000 {
+		return File{}, errors.New("video is too long to fit within the upload limit")
+	}
+
+	out, err := os.CreateTemp("", "gtkcord4-video-*.mp4")
+	if err != nil {
+		return File{}, errors.Wrap(err, "cannot create temporary file")
+	}
+	out.Close()
+
+	err = exec.CommandContext(ctx, "ffmpeg",
+		"-y", "-loglevel", "warning", "-i", in.Name(),
+		"-c:v", "libx264", "-preset", "veryfast",
+		"-b:v", strconv.FormatInt(bitrate, 10),
+		"-maxrate", strconv.FormatInt(bitrate, 10),
+		"-bufsize", strconv.FormatInt(bitrate*2, 10),
+		"-c:a", "aac", "-b:a", strconv.Itoa(audioBitrate),
+		"-movflags", "+faststart",
+		out.Name(),
+	).Run()
+	if err != nil {
+		os.Remove(out.Name())
+		return File{}, errors.Wrap(err, "ffmpeg failed")
+	}
+
+	s, err := os.Stat(out.Name())
+	if err != nil {
+		os.Remove(out.Name())
+		return File{}, errors.Wrap(err, "cannot stat compressed video")
+	}
+
+	name := strings.TrimSuffix(file.Name, path.Ext(file.Name)) + ".mp4"
+	outPath := out.Name()
+
+	return File{
+		Name: name,
+		Type: "video/mp4",
+		Size: s.Size(),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(outPath)
+		},
+		Cleanup: func() {
+			os.Remove(outPath)
+		},
+	}, nil
+}
+
+// videoDuration returns the duration of the video in seconds.
+func videoDuration(ctx context.Context, path string) (float64, error) {
+	out, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	).Output()
+	if err != nil {
+		return 0, errors.Wrap(err, "ffprobe failed")
+	}
+
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || duration <= 0 {
+		return 0, errors.New("cannot get video duration")
+	}
+
+	return duration, nil
+}
+
+// canCompressVideo returns true if the file is a video that can be compressed
+// with FFmpeg.
+func canCompressVideo(file File) bool {
+	if !strings.HasPrefix(file.Type, "video/") {
+		return false
+	}
+
+	for _, bin := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
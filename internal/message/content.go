@@ -281,24 +281,28 @@ func (c *Content) Update(m *discord.Message, customs ...gtk.Widgetter) {
 		(len(m.Embeds) != 1 || m.Embeds[0].Type != discord.ImageEmbed || m.Embeds[0].URL != m.Content):
 
 		src := []byte(m.Content)
-		node := discordmd.ParseWithMessage(src, *state.Cabinet, m, true)
+		node := parseContent(src, state, m)
 
 		c.mdview = mdrender.NewMarkdownViewer(c.ctx, src, node, renderers...)
 		c.append(c.mdview)
 	}
 
+	if m.EditedTimestamp.IsValid() {
+		c.append(newEditedMarker(m, func() { c.view.ShowEditHistory(m.ID) }))
+	}
+
 	for i := range m.Stickers {
 		v := newSticker(c.ctx, &m.Stickers[i])
 		c.append(v)
 	}
 
 	for i := range m.Attachments {
-		v := newAttachment(c.ctx, &m.Attachments[i])
+		v := newAttachment(c.ctx, c.view, &m.Attachments[i])
 		c.append(v)
 	}
 
 	for i := range m.Embeds {
-		v := newEmbed(c.ctx, m, &m.Embeds[i])
+		v := newEmbed(c.ctx, m, &m.Embeds[i], c.view.OpenMedia)
 		c.append(v)
 	}
 
@@ -358,6 +362,9 @@ var renderers = []mdrender.OptionFunc{
 	mdrender.WithRenderer(discordmd.KindEmoji, renderEmoji),
 	mdrender.WithRenderer(discordmd.KindInline, renderInline),
 	mdrender.WithRenderer(discordmd.KindMention, renderMention),
+	mdrender.WithRenderer(KindTimestamp, renderTimestamp),
+	mdrender.WithRenderer(ast.KindLink, renderLink),
+	mdrender.WithRenderer(ast.KindAutoLink, renderAutoLink),
 }
 
 var inlineEmojiTag = textutil.TextTag{
@@ -417,9 +424,98 @@ func renderInline(r *mdrender.Renderer, n ast.Node) ast.WalkStatus {
 		}
 	}
 
+	if inline.Attr.Has(discordmd.AttrSpoiler) {
+		table := r.State.TagTable()
+		text.Buffer.ApplyTag(spoilerTag.FromTable(table, "spoiler"), start, end)
+		setSpoilerWidgetsHidden(start, end, true)
+		bindSpoilerReveal(text.TextView)
+	}
+
 	return ast.WalkSkipChildren
 }
 
+// spoilerTag hides the text behind a solid block until it's revealed.
+var spoilerTag = textutil.TextTag{
+	"foreground": "#7F7F7F",
+	"background": "#7F7F7F",
+}
+
+// revealedSpoilerTag marks spoiler text that has been revealed.
+var revealedSpoilerTag = textutil.TextTag{
+	"background": "#7F7F7F33",
+}
+
+// bindSpoilerReveal makes clicking on spoiler text in the TextView reveal it.
+// Links inside the spoiler can't be clicked until then. The TextView is marked
+// so that the handler is only bound once.
+func bindSpoilerReveal(tview *gtk.TextView) {
+	if tview.HasCSSClass("message-has-spoiler") {
+		return
+	}
+	tview.AddCSSClass("message-has-spoiler")
+
+	click := gtk.NewGestureClick()
+	click.SetButton(1)
+	// Run before the link handler, so that the click can be taken from it.
+	click.SetPropagationPhase(gtk.PhaseCapture)
+	click.ConnectPressed(func(nPress int, x, y float64) {
+		bx, by := tview.WindowToBufferCoords(gtk.TextWindowWidget, int(x), int(y))
+		iter, ok := tview.IterAtLocation(bx, by)
+		if !ok {
+			return
+		}
+
+		buffer := tview.Buffer()
+		table := buffer.TagTable()
+
+		tag := table.Lookup("spoiler")
+		if tag == nil || !iter.HasTag(tag) {
+			return
+		}
+
+		start := iter.Copy()
+		if !start.StartsTag(tag) {
+			start.BackwardToTagToggle(tag)
+		}
+		end := iter.Copy()
+		end.ForwardToTagToggle(tag)
+
+		buffer.RemoveTag(tag, start, end)
+		buffer.ApplyTag(revealedSpoilerTag.FromTable(table, "spoiler-revealed"), start, end)
+		setSpoilerWidgetsHidden(start, end, false)
+
+		// Only reveal the spoiler on this click.
+		click.SetState(gtk.EventSequenceClaimed)
+	})
+
+	tview.AddController(click)
+}
+
+// setSpoilerWidgetsHidden hides or shows the widgets anchored between start
+// and end, such as emojis and timestamps, since the spoiler tag only hides
+// text. Hidden widgets keep their space so that the spoiler doesn't move
+// around once revealed.
+func setSpoilerWidgetsHidden(start, end *gtk.TextIter, hidden bool) {
+	opacity := 1.0
+	if hidden {
+		opacity = 0
+	}
+
+	iter := start.Copy()
+	for iter.Offset() < end.Offset() {
+		if anchor := iter.ChildAnchor(); anchor != nil {
+			for _, w := range anchor.Widgets() {
+				widget := gtk.BaseWidget(w)
+				widget.SetOpacity(opacity)
+				widget.SetCanTarget(!hidden)
+			}
+		}
+		if !iter.ForwardChar() {
+			break
+		}
+	}
+}
+
 // rgba(111, 120, 219, 0.3)
 const defaultMentionColor = "#6F78DB"
 
@@ -0,0 +1,70 @@
+package message
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/message/composer"
+)
+
+var dropZoneCSS = cssutil.Applier("message-drop-zone", `
+	.message-drop-zone {
+		margin: 8px;
+		border: 2px dashed alpha(@theme_selected_bg_color, 0.75);
+		border-radius: 12px;
+		background-color: alpha(@theme_bg_color, 0.85);
+	}
+	.message-drop-zone image {
+		color: @theme_selected_bg_color;
+	}
+	.message-drop-zone label {
+		font-weight: bold;
+		font-size: 1.25em;
+	}
+`)
+
+// bindDrop wraps the view's box in an overlay that accepts dropped files,
+// images and text for the composer. A drop zone is shown while something is
+// dragged over the view.
+func (v *View) bindDrop() {
+	icon := gtk.NewImageFromIconName("document-send-symbolic")
+	icon.SetPixelSize(64)
+
+	label := gtk.NewLabel(locale.Get("Drop to Upload"))
+
+	zone := gtk.NewBox(gtk.OrientationVertical, 12)
+	zone.SetHAlign(gtk.AlignFill)
+	zone.SetVAlign(gtk.AlignFill)
+	zone.SetCanTarget(false)
+	zone.SetVisible(false)
+
+	inner := gtk.NewBox(gtk.OrientationVertical, 12)
+	inner.SetHExpand(true)
+	inner.SetVExpand(true)
+	inner.SetHAlign(gtk.AlignCenter)
+	inner.SetVAlign(gtk.AlignCenter)
+	inner.Append(icon)
+	inner.Append(label)
+	zone.Append(inner)
+	dropZoneCSS(zone)
+
+	v.dropOverlay = gtk.NewOverlay()
+	v.dropOverlay.SetChild(v.Box)
+	v.dropOverlay.AddOverlay(zone)
+
+	target := gtk.NewDropTargetAsync(composer.DropFormats(), gdk.ActionCopy)
+	target.ConnectDragEnter(func(drop gdk.Dropper, x, y float64) gdk.DragAction {
+		zone.SetVisible(true)
+		return gdk.ActionCopy
+	})
+	target.ConnectDragLeave(func(drop gdk.Dropper) {
+		zone.SetVisible(false)
+	})
+	target.ConnectDrop(func(drop gdk.Dropper, x, y float64) bool {
+		zone.SetVisible(false)
+		return v.Composer.ReadDrop(drop)
+	})
+
+	v.dropOverlay.AddController(target)
+}
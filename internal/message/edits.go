@@ -0,0 +1,262 @@
+package message
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// messageEdit is a previous version of an edited message.
+type messageEdit struct {
+	Content   string
+	Timestamp discord.Timestamp
+}
+
+// recordEdit records the old content of the message if it's different from
+// the new content. The history only lives as long as the View does.
+func (v *View) recordEdit(old, new *discord.Message) {
+	if old.Content == new.Content {
+		return
+	}
+
+	timestamp := old.EditedTimestamp
+	if !timestamp.IsValid() {
+		timestamp = old.Timestamp
+	}
+
+	if v.edits == nil {
+		v.edits = make(map[discord.MessageID][]messageEdit)
+	}
+
+	v.edits[old.ID] = append(v.edits[old.ID], messageEdit{
+		Content:   old.Content,
+		Timestamp: timestamp,
+	})
+}
+
+var editedCSS = cssutil.Applier("message-edited", `
+	.message-edited {
+		font-size: 0.8em;
+		color: alpha(@theme_fg_color, 0.55);
+	}
+	.message-edited a {
+		color: inherit;
+		text-decoration: none;
+	}
+`)
+
+// newEditedMarker creates the "(edited)" marker shown under edited messages.
+// Activating it shows the message's edit history.
+func newEditedMarker(m *discord.Message, showHistory func()) *gtk.Label {
+	l := gtk.NewLabel("")
+	l.SetMarkup(`<a href="#edits">` + html.EscapeString(locale.Get("(edited)")) + `</a>`)
+	l.SetXAlign(0)
+	l.SetHAlign(gtk.AlignStart)
+	l.SetTooltipText(locale.Get("Edited %s", gtkcord.FormatTime(m.EditedTimestamp.Time(), true)))
+	l.ConnectActivateLink(func(uri string) bool {
+		if uri == "#edits" {
+			showHistory()
+			return true
+		}
+		return false
+	})
+	editedCSS(l)
+	return l
+}
+
+var editHistoryCSS = cssutil.Applier("message-edit-history", `
+	.message-edit-history {
+		padding: 6px;
+	}
+	.message-edit-history-version {
+		margin-bottom: 12px;
+	}
+	.message-edit-history-time {
+		font-size: 0.85em;
+		font-weight: bold;
+		color: alpha(@theme_fg_color, 0.75);
+	}
+`)
+
+// ShowEditHistory opens a dialog showing the changes made to the message with
+// the given ID while the view was open.
+func (v *View) ShowEditHistory(id discord.MessageID) {
+	row, ok := v.msgs[messageKeyID(id)]
+	if !ok || row.message.Message() == nil {
+		return
+	}
+	msg := row.message.Message()
+
+	d := gtk.NewDialog()
+	d.SetTitle(locale.Get("Edit History"))
+	d.SetTransientFor(app.GTKWindowFromContext(v.ctx))
+	d.SetModal(true)
+	d.SetDefaultSize(400, 300)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 0)
+	editHistoryCSS(box)
+
+	edits := v.edits[id]
+	if len(edits) == 0 {
+		l := gtk.NewLabel(locale.Get("No edits were seen since this channel was opened."))
+		l.SetWrap(true)
+		l.SetWrapMode(pango.WrapWordChar)
+		box.Append(l)
+	}
+
+	// Show the newest version first. Each version is diffed against the one
+	// before it.
+	for i := len(edits) - 1; i >= 0; i-- {
+		newContent := msg.Content
+		timestamp := msg.EditedTimestamp
+		if i+1 < len(edits) {
+			newContent = edits[i+1].Content
+			timestamp = edits[i+1].Timestamp
+		}
+
+		box.Append(newEditVersion(timestamp, diffMarkup(edits[i].Content, newContent)))
+	}
+
+	if len(edits) > 0 {
+		original := edits[0]
+		box.Append(newEditVersion(original.Timestamp, html.EscapeString(original.Content)))
+	}
+
+	s := gtk.NewScrolledWindow()
+	s.SetVExpand(true)
+	s.SetHExpand(true)
+	s.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	s.SetChild(box)
+
+	d.ContentArea().Append(s)
+	d.Show()
+}
+
+func newEditVersion(timestamp discord.Timestamp, markup string) *gtk.Box {
+	header := gtk.NewLabel(gtkcord.FormatTime(timestamp.Time(), true))
+	header.AddCSSClass("message-edit-history-time")
+	header.SetXAlign(0)
+
+	content := gtk.NewLabel("")
+	content.SetMarkup(markup)
+	content.SetXAlign(0)
+	content.SetWrap(true)
+	content.SetWrapMode(pango.WrapWordChar)
+	content.SetSelectable(true)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 2)
+	box.AddCSSClass("message-edit-history-version")
+	box.Append(header)
+	box.Append(content)
+	return box
+}
+
+// maxDiffCells is the maximum size of the table used to diff two messages.
+// Larger messages are shown as a complete replacement.
+const maxDiffCells = 1 << 20
+
+// diffMarkup returns the Pango markup showing the word-level changes needed
+// to turn old into new. Removed words are struck through and added words are
+// highlighted.
+func diffMarkup(old, new string) string {
+	a := splitWords(old)
+	b := splitWords(new)
+
+	if len(a)*len(b) > maxDiffCells {
+		return deletedMarkup(old) + insertedMarkup(new)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] > lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var markup strings.Builder
+	var deleted, inserted strings.Builder
+
+	flush := func() {
+		markup.WriteString(deletedMarkup(deleted.String()))
+		markup.WriteString(insertedMarkup(inserted.String()))
+		deleted.Reset()
+		inserted.Reset()
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			markup.WriteString(html.EscapeString(a[i]))
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			inserted.WriteString(b[j])
+			j++
+		default:
+			deleted.WriteString(a[i])
+			i++
+		}
+	}
+	flush()
+
+	return markup.String()
+}
+
+func deletedMarkup(s string) string {
+	if s == "" {
+		return ""
+	}
+	return `<span strikethrough="true" background="#e01b24" bgalpha="35%">` + html.EscapeString(s) + `</span>`
+}
+
+func insertedMarkup(s string) string {
+	if s == "" {
+		return ""
+	}
+	return `<span background="#2ec27e" bgalpha="35%">` + html.EscapeString(s) + `</span>`
+}
+
+// splitWords splits s into words and the whitespace between them, such that
+// joining the returned slice gives back s.
+func splitWords(s string) []string {
+	var words []string
+	var start int
+	var space bool
+
+	for i, r := range s {
+		isSpace := unicode.IsSpace(r)
+		if i > 0 && isSpace != space {
+			words = append(words, s[start:i])
+			start = i
+		}
+		space = isSpace
+	}
+
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+
+	return words
+}
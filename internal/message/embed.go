@@ -20,7 +20,9 @@ import (
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/downloads"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/sticker"
 	"github.com/diamondburned/ningen/v3/discordmd"
 	"github.com/dustin/go-humanize"
 )
@@ -81,31 +83,32 @@ func resizeURL(directURL, proxyURL string, w, h int) string {
 
 var stickerCSS = cssutil.Applier("message-sticker", `
 	.message-sticker {
-		border-radius: 0;
-	}
-	.message-sticker picture.thumbnail-embed-image {
-		background-color: transparent;
+		margin-top: 4px;
 	}
 `)
 
-func newSticker(ctx context.Context, sticker *discord.StickerItem) gtk.Widgetter {
-	switch sticker.FormatType {
-	case discord.StickerFormatAPNG, discord.StickerFormatPNG:
-		url := sticker.StickerURLWithType(discord.PNGImage)
-
-		// TODO: this is always round because we're using a GtkFrame. What the
-		// heck? How does this shit even work?!
-		image := embed.New(ctx, gtkcord.StickerSize, gtkcord.StickerSize, embed.Opts{})
-		image.SetName(sticker.Name)
-		image.SetHAlign(gtk.AlignStart)
-		image.SetSizeRequest(gtkcord.StickerSize, gtkcord.StickerSize)
-		image.SetFromURL(url)
-		image.SetOpenURL(func() { app.OpenURI(ctx, url) })
-		stickerCSS(image)
-		return image
+func newSticker(ctx context.Context, stickerItem *discord.StickerItem) gtk.Widgetter {
+	switch stickerItem.FormatType {
+	case discord.StickerFormatAPNG, discord.StickerFormatPNG, discord.StickerFormatLottie:
+		url := sticker.URL(*stickerItem)
+
+		picture := sticker.NewPicture(ctx, gtkcord.StickerSize)
+		picture.SetName(stickerItem.Name)
+		picture.SetTooltipText(stickerItem.Name)
+		picture.SetHAlign(gtk.AlignStart)
+		picture.SetCursorFromName("pointer")
+		picture.SetSticker(*stickerItem)
+		picture.EnableAnimation().OnHover()
+		stickerCSS(picture)
+
+		click := gtk.NewGestureClick()
+		click.SetButton(1)
+		click.ConnectReleased(func(n int, x, y float64) { app.OpenURI(ctx, url) })
+		picture.AddController(click)
+
+		return picture
 	default:
-		// Fuck Lottie, whatever the fuck that is.
-		msg := gtk.NewLabel(fmt.Sprintf("[Lottie sticker: %s]", sticker.Name))
+		msg := gtk.NewLabel(fmt.Sprintf("[Sticker: %s]", stickerItem.Name))
 		msg.SetXAlign(0)
 		systemContentCSS(msg)
 		fixNatWrap(msg)
@@ -127,9 +130,71 @@ var messageAttachmentCSS = cssutil.Applier("message-attachment", `
 	.message-attachment-filesize {
 		color: alpha(@theme_fg_color, 0.75);
 	}
+	.message-attachment-download {
+		margin-left: 0.35em;
+		min-height: 0;
+		padding: 2px;
+	}
 `)
 
-func newAttachment(ctx context.Context, attachment *discord.Attachment) gtk.Widgetter {
+var spoilerOverlayCSS = cssutil.Applier("message-spoiler-overlay", `
+	.message-spoiler-overlay {
+		border-radius: 4px;
+	}
+	.message-spoiler-hidden {
+		filter: blur(24px);
+	}
+	.message-spoiler-overlay > button {
+		background-color: rgba(0, 0, 0, 0.75);
+		color: white;
+		font-weight: bold;
+		border-radius: 999px;
+		padding: 4px 12px;
+	}
+`)
+
+// attachmentIsSpoiler returns true if the attachment is marked as a spoiler.
+func attachmentIsSpoiler(attachment *discord.Attachment) bool {
+	return strings.HasPrefix(attachment.Filename, "SPOILER_")
+}
+
+// newSpoilerOverlay blurs the given widget behind a "Spoiler" button. The
+// widget is revealed once the button is clicked, and then reveal is called.
+func newSpoilerOverlay(child gtk.Widgetter, reveal func()) *gtk.Overlay {
+	base := gtk.BaseWidget(child)
+	base.AddCSSClass("message-spoiler-hidden")
+	base.SetCanTarget(false)
+
+	button := gtk.NewButtonWithLabel(locale.Get("Spoiler"))
+	button.SetHAlign(gtk.AlignCenter)
+	button.SetVAlign(gtk.AlignCenter)
+
+	overlay := gtk.NewOverlay()
+	overlay.SetHAlign(base.HAlign())
+	overlay.SetOverflow(gtk.OverflowHidden)
+	overlay.SetChild(child)
+	overlay.AddOverlay(button)
+
+	// Keep the frame spacing on the outermost widget.
+	if base.HasCSSClass("message-richframe") {
+		base.RemoveCSSClass("message-richframe")
+		overlay.AddCSSClass("message-richframe")
+	}
+
+	button.ConnectClicked(func() {
+		overlay.RemoveOverlay(button)
+		base.RemoveCSSClass("message-spoiler-hidden")
+		base.SetCanTarget(true)
+		reveal()
+	})
+
+	spoilerOverlayCSS(overlay)
+	return overlay
+}
+
+// newAttachment creates a widget for the given attachment. Images are opened in
+// the view's media viewer.
+func newAttachment(ctx context.Context, view *View, attachment *discord.Attachment) gtk.Widgetter {
 	var mimeType string
 	if attachment.ContentType != "" {
 		mimeType, _, _ = strings.Cut(attachment.ContentType, "/")
@@ -168,7 +233,7 @@ func newAttachment(ctx context.Context, attachment *discord.Attachment) gtk.Widg
 			case embed.EmbedTypeVideo:
 				image.ActivateDefault()
 			default:
-				app.OpenURI(ctx, attachment.URL)
+				view.OpenMedia(attachment.URL)
 			}
 		})
 
@@ -202,37 +267,64 @@ func newAttachment(ctx context.Context, attachment *discord.Attachment) gtk.Widg
 			image.SetFromURL(attachment.Proxy)
 		}
 
+		if attachmentIsSpoiler(attachment) && !view.revealed[attachment.URL] {
+			url := attachment.URL
+			return newSpoilerOverlay(image, func() { view.revealSpoiler(url) })
+		}
+
 		return image
+	case "audio":
+		return newAudioPlayer(ctx, view, attachment)
 	default:
-		icon := gtk.NewImageFromIconName(mimeIcon(mimeType))
-		icon.AddCSSClass("message-attachment-icon")
-		icon.SetIconSize(gtk.IconSizeNormal)
-
-		filename := gtk.NewLabel("")
-		filename.AddCSSClass("message-attachment-filename")
-		filename.SetMarkup(fmt.Sprintf(
-			`<a href="%s">%s</a>`,
-			attachment.URL,
-			html.EscapeString(attachment.Filename),
-		))
-		filename.SetEllipsize(pango.EllipsizeEnd)
-		filename.SetXAlign(0)
-
-		filesize := gtk.NewLabel(humanize.Bytes(attachment.Size))
-		filesize.AddCSSClass("message-attachment-filesize")
-		filesize.SetXAlign(0)
-
-		box := gtk.NewBox(gtk.OrientationHorizontal, 0)
-		box.SetTooltipText(attachment.Filename)
-		box.Append(icon)
-		box.Append(filename)
-		box.Append(filesize)
-		messageAttachmentCSS(box)
-
-		return box
+		if attachmentIsText(attachment) {
+			return newTextPreview(ctx, attachment)
+		}
+		return newFileAttachment(ctx, attachment)
 	}
 }
 
+// newFileAttachment creates a row showing the attachment's file name and size
+// with a download button.
+func newFileAttachment(ctx context.Context, attachment *discord.Attachment) *gtk.Box {
+	mimeType, _, _ := strings.Cut(attachment.ContentType, "/")
+
+	icon := gtk.NewImageFromIconName(mimeIcon(mimeType))
+	icon.AddCSSClass("message-attachment-icon")
+	icon.SetIconSize(gtk.IconSizeNormal)
+
+	filename := gtk.NewLabel("")
+	filename.AddCSSClass("message-attachment-filename")
+	filename.SetMarkup(fmt.Sprintf(
+		`<a href="%s">%s</a>`,
+		attachment.URL,
+		html.EscapeString(attachment.Filename),
+	))
+	filename.SetEllipsize(pango.EllipsizeEnd)
+	filename.SetXAlign(0)
+
+	filesize := gtk.NewLabel(humanize.Bytes(attachment.Size))
+	filesize.AddCSSClass("message-attachment-filesize")
+	filesize.SetXAlign(0)
+
+	download := gtk.NewButtonFromIconName("folder-download-symbolic")
+	download.AddCSSClass("flat")
+	download.AddCSSClass("message-attachment-download")
+	download.SetTooltipText(locale.Get("Download"))
+	download.ConnectClicked(func() {
+		downloads.FromContext(ctx).Download(attachment.URL, attachment.Filename)
+	})
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	box.SetTooltipText(attachment.Filename)
+	box.Append(icon)
+	box.Append(filename)
+	box.Append(filesize)
+	box.Append(download)
+	messageAttachmentCSS(box)
+
+	return box
+}
+
 func mimeIcon(mimePrefix string) string {
 	switch mimePrefix {
 	case "audio":
@@ -269,11 +361,11 @@ const embedColorCSSf = `
 	}
 `
 
-func newEmbed(ctx context.Context, msg *discord.Message, embed *discord.Embed) gtk.Widgetter {
-	return newNormalEmbed(ctx, msg, embed)
+func newEmbed(ctx context.Context, msg *discord.Message, embed *discord.Embed, openMedia func(url string)) gtk.Widgetter {
+	return newNormalEmbed(ctx, msg, embed, openMedia)
 }
 
-func newNormalEmbed(ctx context.Context, msg *discord.Message, msgEmbed *discord.Embed) gtk.Widgetter {
+func newNormalEmbed(ctx context.Context, msg *discord.Message, msgEmbed *discord.Embed, openMedia func(url string)) gtk.Widgetter {
 	bodyBox := gtk.NewBox(gtk.OrientationVertical, 0)
 	bodyBox.SetHAlign(gtk.AlignStart)
 	bodyBox.SetHExpand(false)
@@ -418,7 +510,7 @@ func newNormalEmbed(ctx context.Context, msg *discord.Message, msgEmbed *discord
 		}
 
 		if msgEmbed.Timestamp.IsValid() {
-			time := locale.TimeAgo(msgEmbed.Timestamp.Time())
+			time := gtkcord.FormatTimeAgo(msgEmbed.Timestamp.Time())
 
 			text := gtk.NewLabel(time)
 			text.AddCSSClass("message-embed-timestamp")
@@ -500,10 +592,10 @@ func newNormalEmbed(ctx context.Context, msg *discord.Message, msgEmbed *discord
 
 		switch {
 		case msgEmbed.Image != nil:
-			// Open the Image proxy instead of the Thumbnail proxy. Honestly
-			// have no idea what the difference is.
+			// Open the Image instead of the Thumbnail. Honestly have no idea
+			// what the difference is.
 			image.SetOpenURL(func() {
-				app.OpenURI(ctx, msgEmbed.Image.Proxy)
+				openMedia(msgEmbed.Image.URL)
 			})
 		case msgEmbed.Video != nil:
 			image.SetOpenURL(func() {
@@ -515,6 +607,10 @@ func newNormalEmbed(ctx context.Context, msg *discord.Message, msgEmbed *discord
 					image.ActivateDefault()
 				}
 			})
+		case msgEmbed.Type == discord.ImageEmbed:
+			image.SetOpenURL(func() {
+				openMedia(msgEmbed.Thumbnail.URL)
+			})
 		default:
 			image.SetOpenURL(func() {
 				app.OpenURI(ctx, msgEmbed.Thumbnail.Proxy)
@@ -547,7 +643,11 @@ func newNormalEmbed(ctx context.Context, msg *discord.Message, msgEmbed *discord
 
 		image := embed.New(ctx, gtkcord.EmbedMaxWidth, gtkcord.EmbedImgHeight, opts)
 		image.SetSizeRequest(int(img.Width), int(img.Height))
-		image.SetOpenURL(func() { app.OpenURI(ctx, msgEmbed.URL) })
+		if msgEmbed.Image != nil {
+			image.SetOpenURL(func() { openMedia(msgEmbed.Image.URL) })
+		} else {
+			image.SetOpenURL(func() { app.OpenURI(ctx, msgEmbed.URL) })
+		}
 
 		if msgEmbed.Image != nil {
 			// The server can only resize images.
@@ -0,0 +1,137 @@
+package message
+
+import (
+	"context"
+
+	"github.com/diamondburned/chatkit/md"
+	"github.com/diamondburned/chatkit/md/block"
+	"github.com/diamondburned/chatkit/md/mdrender"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/yuin/goldmark/ast"
+)
+
+// openLink opens links to channels and messages in gtkcord4 and every other
+// link in the browser.
+func openLink(ctx context.Context, url string) {
+	if cmd, ok := gtkcord.ParseMessageLink(url); ok {
+		app.FromContext(ctx).ActivateAction("open-channel", gtkutil.NewJSONVariant(cmd))
+		return
+	}
+	app.OpenURI(ctx, url)
+}
+
+// bindLinkHandler binds openLink to the links in the text block. It must be
+// called before mdrender binds its own handler, since only the first one is
+// used.
+func bindLinkHandler(r *mdrender.Renderer, text *block.TextBlock) {
+	ctx := r.State.Context()
+	md.BindLinkHandler(text.TextView, func(url string) { openLink(ctx, url) })
+}
+
+func renderLink(r *mdrender.Renderer, n ast.Node) ast.WalkStatus {
+	link := n.(*ast.Link)
+
+	text := r.State.TextBlock()
+	bindLinkHandler(r, text)
+
+	if string(link.Title) != "" {
+		text.Insert(string(link.Title))
+	}
+
+	startIx := text.Iter.Offset()
+	status := r.RenderChildren(link)
+
+	start := text.Buffer.IterAtOffset(startIx)
+	text.ApplyLink(string(link.Destination), start, text.Iter)
+
+	return status
+}
+
+func renderAutoLink(r *mdrender.Renderer, n ast.Node) ast.WalkStatus {
+	link := n.(*ast.AutoLink)
+	url := string(link.URL(r.Source()))
+
+	text := r.State.TextBlock()
+	bindLinkHandler(r, text)
+
+	if cmd, ok := gtkcord.ParseMessageLink(url); ok {
+		if chip := newChannelChip(r.State.Context(), cmd); chip != nil {
+			text.Buffer.Insert(text.Iter, "\u200b")
+			anchor := text.Buffer.CreateChildAnchor(text.Iter)
+			text.TextView.AddChildAtAnchor(chip, anchor)
+			text.TextView.QueueResize()
+			return ast.WalkContinue
+		}
+	}
+
+	startIx := text.Iter.Offset()
+	text.Insert(url)
+
+	start := text.Buffer.IterAtOffset(startIx)
+	text.ApplyLink(url, start, text.Iter)
+
+	return ast.WalkContinue
+}
+
+var channelChipCSS = cssutil.Applier("message-channel-chip", `
+	.message-channel-chip {
+		min-height: 0;
+		padding: 0 6px;
+		margin-bottom: -0.4em;
+		border-radius: 999px;
+		background-color: alpha(`+defaultMentionColor+`, 0.46);
+	}
+	.message-channel-chip:hover {
+		background-color: alpha(`+defaultMentionColor+`, 0.65);
+	}
+	.message-channel-chip-separator {
+		opacity: 0.75;
+	}
+`)
+
+// newChannelChip creates a button that opens the channel or message in the
+// link. It returns nil if the channel isn't known, in which case the link is
+// shown as is.
+func newChannelChip(ctx context.Context, cmd gtkcord.OpenChannelCommand) *gtk.Button {
+	state := gtkcord.FromContext(ctx)
+
+	ch, err := state.Cabinet.Channel(cmd.ChannelID)
+	if err != nil {
+		return nil
+	}
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 4)
+
+	name := gtk.NewLabel(gtkcord.ChannelName(ch))
+	name.SetEllipsize(pango.EllipsizeEnd)
+	name.SetMaxWidthChars(24)
+	box.Append(name)
+
+	if cmd.MessageID.IsValid() {
+		sep := gtk.NewLabel("›")
+		sep.AddCSSClass("message-channel-chip-separator")
+		box.Append(sep)
+		box.Append(gtk.NewLabel(locale.Get("Message")))
+	}
+
+	button := gtk.NewButton()
+	button.SetChild(box)
+	button.SetHasFrame(false)
+	button.SetCanFocus(false)
+	button.ConnectClicked(func() {
+		app.FromContext(ctx).ActivateAction("open-channel", gtkutil.NewJSONVariant(cmd))
+	})
+	channelChipCSS(button)
+
+	if guild, err := state.Cabinet.Guild(ch.GuildID); err == nil {
+		button.SetTooltipText(guild.Name)
+	}
+
+	return button
+}
@@ -0,0 +1,264 @@
+package message
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/diamondburned/chatkit/md"
+	"github.com/diamondburned/chatkit/md/mdrender"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/ningen/v3/discordmd"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Timestamp is a Discord timestamp tag, such as <t:1700000000:R>.
+type Timestamp struct {
+	ast.BaseInline
+	Time  time.Time
+	Style byte
+}
+
+// KindTimestamp is the node kind for Timestamp.
+var KindTimestamp = ast.NewNodeKind("Timestamp")
+
+// Kind implements ast.Node.
+func (t *Timestamp) Kind() ast.NodeKind { return KindTimestamp }
+
+// Dump implements ast.Node.
+func (t *Timestamp) Dump(source []byte, level int) {
+	ast.DumpHelper(t, source, level, map[string]string{
+		"Time":  t.Time.String(),
+		"Style": string(t.Style),
+	}, nil)
+}
+
+var timestampRegex = regexp.MustCompile(`<t:(-?\d{1,13})(?::([tTdDfFR]))?>`)
+
+// minTimestamp and maxTimestamp are the range of timestamp tags that are
+// rendered, in Unix seconds. It's the range of GDateTime (years 1 to 9999)
+// with a day to spare for timezones. Tags outside of it are shown as is.
+const (
+	minTimestamp = -62135596800 + 86400 // 0001-01-02
+	maxTimestamp = 253402300799 - 86400 // 9999-12-30
+)
+
+// maxHeadingLevel is the deepest heading that Discord renders. Anything deeper
+// is shown as plain text.
+const maxHeadingLevel = 3
+
+// parseContent parses the message content into a Markdown tree. On top of
+// what discordmd parses, it handles timestamp tags, masked links and fixes up
+// headings and lists to render the way Discord does.
+func parseContent(src []byte, state *gtkcord.State, m *discord.Message) ast.Node {
+	// Parsing without the message flag makes discordmd also parse masked
+	// links.
+	node := discordmd.ParseWithMessage(src, *state.Cabinet, m, false)
+
+	var texts []*ast.Text
+	var headings []*ast.Heading
+	var items []*ast.ListItem
+
+	// Collect first, since we can't modify the tree while walking it.
+	ast.Walk(node, func(n ast.Node, enter bool) (ast.WalkStatus, error) {
+		if !enter {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *discordmd.Inline:
+			// Timestamp tags inside inline code are shown as is.
+			if n.Attr.Has(discordmd.AttrMonospace) {
+				return ast.WalkSkipChildren, nil
+			}
+		case *ast.CodeSpan:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			texts = append(texts, n)
+		case *ast.Heading:
+			if n.Level > maxHeadingLevel {
+				headings = append(headings, n)
+			}
+		case *ast.ListItem:
+			items = append(items, n)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, t := range texts {
+		splitTimestamps(t, src)
+	}
+	for _, h := range headings {
+		flattenHeading(h)
+	}
+	for _, item := range items {
+		endListItem(item)
+	}
+
+	return node
+}
+
+// splitTimestamps replaces all timestamp tags inside the text node with
+// Timestamp nodes.
+func splitTimestamps(t *ast.Text, src []byte) {
+	parent := t.Parent()
+	if parent == nil {
+		return
+	}
+
+	value := t.Segment.Value(src)
+	matches := timestampRegex.FindAllSubmatchIndex(value, -1)
+	if matches == nil {
+		return
+	}
+
+	start := t.Segment.Start
+	last := 0
+
+	for _, match := range matches {
+		unix, err := strconv.ParseInt(string(value[match[2]:match[3]]), 10, 64)
+		if err != nil || unix < minTimestamp || unix > maxTimestamp {
+			continue
+		}
+
+		if match[0] > last {
+			before := ast.NewTextSegment(text.NewSegment(start+last, start+match[0]))
+			parent.InsertBefore(parent, t, before)
+		}
+
+		style := byte('f')
+		if match[4] != -1 {
+			style = value[match[4]]
+		}
+
+		parent.InsertBefore(parent, t, &Timestamp{
+			Time:  time.Unix(unix, 0),
+			Style: style,
+		})
+
+		last = match[1]
+	}
+
+	// Keep the original node for the remaining text, since it holds the line
+	// break flags.
+	t.Segment = text.NewSegment(start+last, t.Segment.Stop)
+}
+
+// flattenHeading turns a heading that Discord doesn't render into a paragraph
+// that keeps the leading hashes.
+func flattenHeading(h *ast.Heading) {
+	parent := h.Parent()
+	if parent == nil {
+		return
+	}
+
+	p := ast.NewParagraph()
+	p.AppendChild(p, ast.NewString([]byte(hashes(h.Level)+" ")))
+	for child := h.FirstChild(); child != nil; {
+		next := child.NextSibling()
+		p.AppendChild(p, child)
+		child = next
+	}
+
+	parent.ReplaceChild(parent, h, p)
+}
+
+func hashes(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = '#'
+	}
+	return string(b)
+}
+
+// endListItem ends the list at the first blank line inside the list item. The
+// discordmd paragraph parser keeps blank lines, so without this, everything
+// after a list would be swallowed into its last item.
+func endListItem(item *ast.ListItem) {
+	list, ok := item.Parent().(*ast.List)
+	if !ok || list.Parent() == nil {
+		return
+	}
+
+	block := item.LastChild()
+	if block == nil {
+		return
+	}
+
+	var blank ast.Node
+	for child := block.FirstChild(); child != nil; child = child.NextSibling() {
+		text, ok := child.(*ast.Text)
+		if ok && text.Segment.Len() == 0 && text.SoftLineBreak() {
+			blank = child
+			break
+		}
+	}
+	if blank == nil {
+		return
+	}
+
+	rest := ast.NewParagraph()
+	for child := blank.NextSibling(); child != nil; {
+		next := child.NextSibling()
+		rest.AppendChild(rest, child)
+		child = next
+	}
+	block.RemoveChild(block, blank)
+
+	// The blank line's preceding text shouldn't break the line anymore.
+	if last, ok := block.LastChild().(*ast.Text); ok {
+		last.SetSoftLineBreak(false)
+	}
+
+	// Move the items after this one into a new list after the paragraph.
+	var tail *ast.List
+	for sibling := item.NextSibling(); sibling != nil; {
+		next := sibling.NextSibling()
+		if tail == nil {
+			tail = ast.NewList(list.Marker)
+			tail.IsTight = list.IsTight
+			tail.Start = list.Start
+		}
+		tail.AppendChild(tail, sibling)
+		sibling = next
+	}
+
+	parent := list.Parent()
+	if tail != nil {
+		parent.InsertAfter(parent, list, tail)
+	}
+	if rest.HasChildren() {
+		parent.InsertAfter(parent, list, rest)
+	}
+}
+
+var timestampTagCSS = cssutil.Applier("message-timestamp-tag", `
+	.message-timestamp-tag {
+		padding: 0 2px;
+		border-radius: 3px;
+		background-color: alpha(@theme_fg_color, 0.1);
+	}
+`)
+
+func renderTimestamp(r *mdrender.Renderer, n ast.Node) ast.WalkStatus {
+	timestamp := n.(*Timestamp)
+	text := r.State.TextBlock()
+
+	label := gtk.NewLabel("")
+	timestampTagCSS(label)
+
+	update := func() {
+		label.SetText(gtkcord.FormatTimestampTag(timestamp.Time, timestamp.Style))
+		label.SetTooltipText(gtkcord.FormatTimestampTag(timestamp.Time, 'F'))
+	}
+	update()
+	gtkcord.SubscribeTimestamps(label, update)
+
+	text.Buffer.Insert(text.Iter, "​")
+	md.AddWidgetAt(text.TextView, text.Iter, label)
+
+	return ast.WalkContinue
+}
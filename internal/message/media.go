@@ -0,0 +1,122 @@
+package message
+
+import (
+	"path"
+	"strings"
+
+	"github.com/diamondburned/chatkit/components/embed"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/message/mediaviewer"
+)
+
+// OpenMedia opens the media viewer on the image or video with the given URL.
+// The viewer can navigate through all media loaded in the channel. If the URL
+// isn't known, then it's opened externally.
+func (v *View) OpenMedia(url string) {
+	media := v.channelMedia()
+	for i, m := range media {
+		if m.URL == url {
+			mediaviewer.Show(v.ctx, media, i)
+			return
+		}
+	}
+
+	app.OpenURI(v.ctx, url)
+}
+
+// channelMedia returns the media of all loaded messages from oldest to newest.
+func (v *View) channelMedia() []mediaviewer.Media {
+	var perMessage [][]mediaviewer.Media
+	v.eachMessage(func(row messageRow) bool {
+		if msg := row.message.Message(); msg != nil {
+			perMessage = append(perMessage, v.messageMedia(msg))
+		}
+		return false
+	})
+
+	// eachMessage goes from the newest message.
+	var media []mediaviewer.Media
+	for i := len(perMessage) - 1; i >= 0; i-- {
+		media = append(media, perMessage[i]...)
+	}
+	return media
+}
+
+// revealSpoiler marks the spoiler attachment with the given URL as revealed, so
+// that it's shown in the media viewer.
+func (v *View) revealSpoiler(url string) {
+	if v.revealed == nil {
+		v.revealed = make(map[string]bool)
+	}
+	v.revealed[url] = true
+}
+
+// messageMedia returns the images and videos inside the message in the order
+// that they're shown. Spoilers are skipped until they're revealed.
+func (v *View) messageMedia(msg *discord.Message) []mediaviewer.Media {
+	var media []mediaviewer.Media
+
+	for i, attachment := range msg.Attachments {
+		if attachmentIsSpoiler(&msg.Attachments[i]) && !v.revealed[attachment.URL] {
+			continue
+		}
+
+		m := mediaviewer.Media{
+			URL:    attachment.URL,
+			Proxy:  attachment.Proxy,
+			Name:   attachment.Filename,
+			Width:  int(attachment.Width),
+			Height: int(attachment.Height),
+		}
+
+		switch {
+		case attachment.ContentType == "image/gif":
+			m.Type = mediaviewer.Animation
+		case strings.HasPrefix(attachment.ContentType, "image/"):
+			m.Type = mediaviewer.Image
+		case strings.HasPrefix(attachment.ContentType, "video/"):
+			m.Type = mediaviewer.Video
+		default:
+			continue
+		}
+
+		media = append(media, m)
+	}
+
+	for _, e := range msg.Embeds {
+		switch {
+		case e.Image != nil:
+			media = append(media, embedImageMedia(*e.Image))
+		case e.Video != nil && e.Video.Proxy != "" &&
+			(e.Type == discord.VideoEmbed || e.Type == discord.GIFVEmbed):
+			media = append(media, mediaviewer.Media{
+				URL:    e.Video.URL,
+				Proxy:  e.Video.Proxy,
+				Name:   path.Base(e.Video.URL),
+				Type:   mediaviewer.Video,
+				Width:  int(e.Video.Width),
+				Height: int(e.Video.Height),
+			})
+		case e.Thumbnail != nil && e.Type == discord.ImageEmbed:
+			media = append(media, embedImageMedia(discord.EmbedImage(*e.Thumbnail)))
+		}
+	}
+
+	return media
+}
+
+func embedImageMedia(img discord.EmbedImage) mediaviewer.Media {
+	m := mediaviewer.Media{
+		URL:    img.URL,
+		Proxy:  img.Proxy,
+		Name:   path.Base(img.URL),
+		Type:   mediaviewer.Image,
+		Width:  int(img.Width),
+		Height: int(img.Height),
+	}
+	if embed.TypeFromURL(img.URL) == embed.EmbedTypeGIF {
+		m.Type = mediaviewer.Animation
+	}
+	return m
+}
@@ -0,0 +1,513 @@
+// Package mediaviewer contains a window for viewing the images and videos sent
+// in a channel.
+package mediaviewer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/diamondburned/adaptive"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/components/onlineimage"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/downloads"
+)
+
+// Type is the type of a Media.
+type Type uint8
+
+const (
+	// Image is a still image.
+	Image Type = iota
+	// Animation is an animated image, such as a GIF.
+	Animation
+	// Video is a video that's played back using GStreamer.
+	Video
+)
+
+// Media is a single image or video that the viewer can show.
+type Media struct {
+	// URL is the original URL of the media. It's used for saving and opening
+	// the media.
+	URL string
+	// Proxy is the URL that the media is loaded from. If it's empty, then URL
+	// is used.
+	Proxy string
+	// Name is the file name of the media.
+	Name string
+	Type Type
+	// Width and Height are the original dimensions of the media, or 0 if
+	// they're unknown.
+	Width  int
+	Height int
+}
+
+func (m Media) source() string {
+	if m.Proxy != "" {
+		return m.Proxy
+	}
+	return m.URL
+}
+
+const (
+	minZoom  = 0.1
+	maxZoom  = 10
+	zoomStep = 1.25
+)
+
+// Viewer is a window that shows a list of media one at a time.
+type Viewer struct {
+	*gtk.Window
+	title   *adw.WindowTitle
+	prev    *gtk.Button
+	next    *gtk.Button
+	page    *adaptive.LoadablePage
+	overlay *gtk.Overlay
+	scroll  *gtk.ScrolledWindow
+	spinner *gtk.Spinner
+
+	picture *onlineimage.Picture
+	stream  *gtk.MediaFile
+
+	ctx   context.Context
+	media []Media
+	index int
+	// zoom is the current zoom level, where 1 is the media's original size. A
+	// zoom of 0 fits the media inside the window.
+	zoom float64
+}
+
+var viewerCSS = cssutil.Applier("mediaviewer", `
+	.mediaviewer-scroll {
+		background-color: black;
+	}
+	.mediaviewer-scroll.mediaviewer-dragging {
+		cursor: grabbing;
+	}
+`)
+
+// Show shows a new media viewer window starting at the media with the given
+// index. The user can navigate to the rest of the media using the arrow keys.
+func Show(ctx context.Context, media []Media, index int) *Viewer {
+	v := NewViewer(ctx, media, index)
+	v.Show()
+	return v
+}
+
+// NewViewer creates a new media viewer window.
+func NewViewer(ctx context.Context, media []Media, index int) *Viewer {
+	v := Viewer{
+		ctx:   ctx,
+		media: media,
+	}
+
+	v.prev = gtk.NewButtonFromIconName("go-previous-symbolic")
+	v.prev.SetTooltipText(locale.Get("Previous"))
+	v.prev.ConnectClicked(func() { v.SetIndex(v.index - 1) })
+
+	v.next = gtk.NewButtonFromIconName("go-next-symbolic")
+	v.next.SetTooltipText(locale.Get("Next"))
+	v.next.ConnectClicked(func() { v.SetIndex(v.index + 1) })
+
+	nav := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	nav.AddCSSClass("linked")
+	nav.Append(v.prev)
+	nav.Append(v.next)
+
+	zoomOut := gtk.NewButtonFromIconName("zoom-out-symbolic")
+	zoomOut.SetTooltipText(locale.Get("Zoom Out"))
+	zoomOut.ConnectClicked(func() { v.ZoomBy(1 / zoomStep) })
+
+	zoomFit := gtk.NewButtonFromIconName("zoom-fit-best-symbolic")
+	zoomFit.SetTooltipText(locale.Get("Best Fit"))
+	zoomFit.ConnectClicked(func() { v.SetZoom(0) })
+
+	zoomIn := gtk.NewButtonFromIconName("zoom-in-symbolic")
+	zoomIn.SetTooltipText(locale.Get("Zoom In"))
+	zoomIn.ConnectClicked(func() { v.ZoomBy(zoomStep) })
+
+	zoom := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	zoom.AddCSSClass("linked")
+	zoom.Append(zoomOut)
+	zoom.Append(zoomFit)
+	zoom.Append(zoomIn)
+
+	menu := gtk.NewMenuButton()
+	menu.SetIconName("view-more-symbolic")
+	menu.SetTooltipText(locale.Get("More"))
+	menu.SetMenuModel(gtkutil.CustomMenu([]gtkutil.PopoverMenuItem{
+		gtkutil.MenuItem("_Save As…", "mediaviewer.save"),
+		gtkutil.MenuItem("_Copy Image", "mediaviewer.copy"),
+		gtkutil.MenuItem("_Open Original", "mediaviewer.open"),
+	}))
+
+	v.title = adw.NewWindowTitle("", "")
+
+	header := gtk.NewHeaderBar()
+	header.SetTitleWidget(v.title)
+	header.PackStart(nav)
+	header.PackEnd(menu)
+	header.PackEnd(zoom)
+
+	v.scroll = gtk.NewScrolledWindow()
+	v.scroll.AddCSSClass("mediaviewer-scroll")
+	v.scroll.SetVExpand(true)
+	v.scroll.SetHExpand(true)
+	v.scroll.SetPolicy(gtk.PolicyAutomatic, gtk.PolicyAutomatic)
+
+	v.spinner = gtk.NewSpinner()
+	v.spinner.SetSizeRequest(32, 32)
+	v.spinner.SetHAlign(gtk.AlignCenter)
+	v.spinner.SetVAlign(gtk.AlignCenter)
+	v.spinner.SetCanTarget(false)
+
+	v.overlay = gtk.NewOverlay()
+	v.overlay.SetChild(v.scroll)
+	v.overlay.AddOverlay(v.spinner)
+
+	v.page = adaptive.NewLoadablePage()
+
+	v.Window = gtk.NewWindow()
+	v.Window.SetTransientFor(app.GTKWindowFromContext(ctx))
+	v.Window.SetDestroyWithParent(true)
+	v.Window.SetDefaultSize(900, 650)
+	v.Window.SetTitlebar(header)
+	v.Window.SetChild(v.page)
+	v.Window.ConnectCloseRequest(func() bool {
+		v.stopStream()
+		return false
+	})
+
+	if app.IsDevel() {
+		v.Window.AddCSSClass("devel")
+	}
+
+	v.bindPanning()
+	v.bindScrollZoom()
+	v.bindKeys()
+
+	gtkutil.BindActionMap(v, map[string]func(){
+		"mediaviewer.save": v.SaveAs,
+		"mediaviewer.copy": v.CopyImage,
+		"mediaviewer.open": v.OpenOriginal,
+	})
+
+	viewerCSS(v)
+	v.SetIndex(index)
+	return &v
+}
+
+// Current returns the media that's currently shown.
+func (v *Viewer) Current() Media {
+	return v.media[v.index]
+}
+
+// SetIndex shows the media at the given index. Indices out of bounds are
+// ignored.
+func (v *Viewer) SetIndex(index int) {
+	if index < 0 || index >= len(v.media) {
+		return
+	}
+
+	v.index = index
+	v.zoom = 0
+	v.stopStream()
+	v.picture = nil
+
+	media := v.Current()
+
+	v.SetTitle(app.FromContext(v.ctx).SuffixedTitle(media.Name))
+	v.title.SetTitle(media.Name)
+	if len(v.media) > 1 {
+		v.title.SetSubtitle(fmt.Sprintf("%d / %d", index+1, len(v.media)))
+	} else {
+		v.title.SetSubtitle("")
+	}
+
+	v.prev.SetSensitive(index > 0)
+	v.next.SetSensitive(index < len(v.media)-1)
+	v.prev.SetVisible(len(v.media) > 1)
+	v.next.SetVisible(len(v.media) > 1)
+
+	v.ActionSetEnabled("mediaviewer.copy", media.Type != Video)
+
+	switch media.Type {
+	case Video:
+		v.showVideo(media)
+	default:
+		v.showImage(media)
+	}
+}
+
+func (v *Viewer) showImage(media Media) {
+	var anim *onlineimage.AnimationController
+	var picture *onlineimage.Picture
+
+	ctx := imgutil.WithOpts(v.ctx, imgutil.WithDoneFn(func(err error) {
+		if v.picture != picture {
+			// The user already moved on to another image.
+			return
+		}
+
+		v.spinner.Stop()
+		v.spinner.Hide()
+
+		if err != nil {
+			v.page.SetError(err)
+			return
+		}
+		if anim != nil {
+			anim.Start()
+		}
+	}))
+
+	picture = onlineimage.NewPicture(ctx, imgutil.HTTPProvider)
+	picture.SetKeepAspectRatio(true)
+	picture.SetCanShrink(true)
+	picture.SetHExpand(true)
+	picture.SetVExpand(true)
+
+	if media.Type == Animation {
+		anim = picture.EnableAnimation()
+	}
+
+	v.picture = picture
+	v.scroll.SetChild(picture)
+	v.page.SetChild(v.overlay)
+
+	// The picture only fetches once it's mapped, so the loading spinner is
+	// shown on top of it instead of replacing it.
+	v.spinner.Show()
+	v.spinner.Start()
+
+	picture.SetURL(media.source())
+}
+
+func (v *Viewer) showVideo(media Media) {
+	v.stream = gtk.NewMediaFileForFile(gio.NewFileForURI(media.source()))
+	v.stream.SetLoop(true)
+
+	video := gtk.NewVideoForMediaStream(v.stream)
+	video.SetHExpand(true)
+	video.SetVExpand(true)
+
+	v.spinner.Stop()
+	v.spinner.Hide()
+
+	v.scroll.SetChild(video)
+	v.page.SetChild(v.overlay)
+
+	v.stream.Play()
+}
+
+func (v *Viewer) stopStream() {
+	if v.stream != nil {
+		v.stream.Pause()
+		v.stream.Clear()
+		v.stream = nil
+	}
+}
+
+// SetZoom sets the zoom level of the current image. A zoom of 1 shows the
+// image at its original size, and a zoom of 0 fits it inside the window.
+func (v *Viewer) SetZoom(zoom float64) {
+	if v.picture == nil {
+		return
+	}
+
+	w, h := v.mediaSize()
+	if w == 0 || h == 0 {
+		return
+	}
+
+	switch {
+	case zoom <= 0:
+		v.zoom = 0
+		v.picture.SetSizeRequest(-1, -1)
+		return
+	case zoom < minZoom:
+		zoom = minZoom
+	case zoom > maxZoom:
+		zoom = maxZoom
+	}
+
+	v.zoom = zoom
+	v.picture.SetSizeRequest(int(float64(w)*zoom), int(float64(h)*zoom))
+}
+
+// ZoomBy multiplies the current zoom level by the given factor.
+func (v *Viewer) ZoomBy(factor float64) {
+	zoom := v.zoom
+	if zoom == 0 {
+		zoom = v.fitZoom()
+	}
+	v.SetZoom(zoom * factor)
+}
+
+// fitZoom returns the zoom level at which the image fits the window.
+func (v *Viewer) fitZoom() float64 {
+	w, h := v.mediaSize()
+	if w == 0 || h == 0 {
+		return 1
+	}
+
+	zoomW := float64(v.scroll.AllocatedWidth()) / float64(w)
+	zoomH := float64(v.scroll.AllocatedHeight()) / float64(h)
+	if zoomW < zoomH {
+		return zoomW
+	}
+	return zoomH
+}
+
+func (v *Viewer) mediaSize() (w, h int) {
+	media := v.Current()
+	if media.Width > 0 && media.Height > 0 {
+		return media.Width, media.Height
+	}
+
+	if v.picture != nil {
+		if paintable := v.picture.Paintable(); paintable != nil {
+			return paintable.IntrinsicWidth(), paintable.IntrinsicHeight()
+		}
+	}
+
+	return 0, 0
+}
+
+func (v *Viewer) bindPanning() {
+	var startX, startY float64
+
+	drag := gtk.NewGestureDrag()
+	drag.SetButton(gdk.BUTTON_PRIMARY)
+	drag.ConnectDragBegin(func(x, y float64) {
+		startX = v.scroll.HAdjustment().Value()
+		startY = v.scroll.VAdjustment().Value()
+		v.scroll.AddCSSClass("mediaviewer-dragging")
+	})
+	drag.ConnectDragUpdate(func(x, y float64) {
+		v.scroll.HAdjustment().SetValue(startX - x)
+		v.scroll.VAdjustment().SetValue(startY - y)
+	})
+	drag.ConnectDragEnd(func(x, y float64) {
+		v.scroll.RemoveCSSClass("mediaviewer-dragging")
+	})
+
+	v.scroll.AddController(drag)
+}
+
+func (v *Viewer) bindScrollZoom() {
+	scroll := gtk.NewEventControllerScroll(gtk.EventControllerScrollVertical)
+	scroll.SetPropagationPhase(gtk.PhaseCapture)
+	scroll.ConnectScroll(func(dx, dy float64) bool {
+		if scroll.CurrentEventState()&gdk.ControlMask == 0 {
+			return false
+		}
+		switch {
+		case dy < 0:
+			v.ZoomBy(zoomStep)
+		case dy > 0:
+			v.ZoomBy(1 / zoomStep)
+		}
+		return true
+	})
+
+	v.scroll.AddController(scroll)
+}
+
+func (v *Viewer) bindKeys() {
+	keys := gtk.NewEventControllerKey()
+	keys.ConnectKeyPressed(func(val, _ uint, state gdk.ModifierType) bool {
+		if state&gdk.ControlMask != 0 {
+			switch val {
+			case gdk.KEY_s:
+				v.SaveAs()
+				return true
+			case gdk.KEY_c:
+				v.CopyImage()
+				return true
+			}
+			return false
+		}
+
+		switch val {
+		case gdk.KEY_Escape:
+			v.Close()
+		case gdk.KEY_Left, gdk.KEY_Page_Up:
+			v.SetIndex(v.index - 1)
+		case gdk.KEY_Right, gdk.KEY_Page_Down:
+			v.SetIndex(v.index + 1)
+		case gdk.KEY_Home:
+			v.SetIndex(0)
+		case gdk.KEY_End:
+			v.SetIndex(len(v.media) - 1)
+		case gdk.KEY_plus, gdk.KEY_equal, gdk.KEY_KP_Add:
+			v.ZoomBy(zoomStep)
+		case gdk.KEY_minus, gdk.KEY_KP_Subtract:
+			v.ZoomBy(1 / zoomStep)
+		case gdk.KEY_0, gdk.KEY_KP_0:
+			v.SetZoom(0)
+		case gdk.KEY_1, gdk.KEY_KP_1:
+			v.SetZoom(1)
+		default:
+			return false
+		}
+		return true
+	})
+
+	v.AddController(keys)
+}
+
+// SaveAs prompts the user for a location and saves the current media there.
+func (v *Viewer) SaveAs() {
+	media := v.Current()
+
+	chooser := gtk.NewFileChooserNative(
+		locale.Get("Save As"),
+		v.Window,
+		gtk.FileChooserActionSave,
+		locale.Get("Save"), locale.Get("Cancel"),
+	)
+	chooser.SetCurrentName(media.Name)
+	chooser.SetCurrentFolder(gio.NewFileForPath(downloads.Directory()))
+	chooser.ConnectResponse(func(resp int) {
+		if resp != int(gtk.ResponseAccept) {
+			return
+		}
+
+		downloads.FromContext(v.ctx).DownloadTo(media.URL, chooser.File().Path())
+	})
+	chooser.Show()
+}
+
+// CopyImage copies the current image into the clipboard.
+func (v *Viewer) CopyImage() {
+	media := v.Current()
+	if media.Type == Video {
+		return
+	}
+
+	ctx := imgutil.WithOpts(v.ctx, imgutil.WithErrorFn(func(err error) {
+		app.Error(v.ctx, errors.Wrap(err, "cannot copy image"))
+	}))
+
+	imgutil.AsyncGET(ctx, media.source(), imgutil.ImageSetter{
+		SetFromPixbuf: func(p *gdkpixbuf.Pixbuf) {
+			clipboard := gdk.DisplayGetDefault().Clipboard()
+			clipboard.SetTexture(gdk.NewTextureForPixbuf(p))
+		},
+	})
+}
+
+// OpenOriginal opens the current media's original URL externally.
+func (v *Viewer) OpenOriginal() {
+	app.OpenURI(v.ctx, v.Current().URL)
+}
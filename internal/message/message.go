@@ -20,6 +20,7 @@ import (
 	"github.com/diamondburned/gotkit/gtkutil/imgutil"
 	"github.com/diamondburned/gotkit/gtkutil/textutil"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/profile"
 )
 
 // ExtraMenuSetter is an interface for types that implement SetExtraMenu.
@@ -106,6 +107,7 @@ func (m *message) bind(parent gtk.Widgetter) *gio.Menu {
 
 	actions := map[string]func(){
 		"message.show-source": func() { m.ShowSource() },
+		"message.show-edits":  func() { m.view().ShowEditHistory(m.message.ID) },
 		"message.reply":       func() { m.view().ReplyTo(m.message.ID) },
 	}
 
@@ -125,6 +127,7 @@ func (m *message) bind(parent gtk.Widgetter) *gio.Menu {
 		menuItemIfOK(actions, "_Reply", "message.reply"),
 		menuItemIfOK(actions, "_Edit", "message.edit"),
 		menuItemIfOK(actions, "_Delete", "message.delete"),
+		menuItemIfOK(actions, "Show Edit _History", "message.show-edits"),
 		menuItemIfOK(actions, "Show _Source", "message.show-source"),
 	}
 
@@ -193,6 +196,7 @@ type cozyMessage struct {
 
 	message
 	tooltip string // markup
+	author  string // markup
 }
 
 var _ MessageWithUser = (*cozyMessage)(nil)
@@ -233,10 +237,20 @@ func NewCozyMessage(ctx context.Context, v *View) Message {
 	m.Avatar.SetVAlign(gtk.AlignStart)
 	m.Avatar.EnableAnimation().OnHover()
 
+	profile.Bind(ctx, m.Avatar, func() (discord.GuildID, *discord.User) {
+		msg := m.message.message
+		if msg == nil {
+			return 0, nil
+		}
+		return msg.GuildID, &msg.Author
+	})
+
 	m.Box = gtk.NewBox(gtk.OrientationHorizontal, 0)
 	m.Box.Append(m.Avatar)
 	m.Box.Append(m.RightBox)
 
+	gtkcord.SubscribeTimestamps(m, m.updateTopLabel)
+
 	cozyCSS(m)
 	return &m
 }
@@ -244,16 +258,6 @@ func NewCozyMessage(ctx context.Context, v *View) Message {
 func (m *cozyMessage) Update(message *gateway.MessageCreateEvent) {
 	m.message.update(m, &message.Message)
 	m.updateAuthor(message)
-
-	tooltip := fmt.Sprintf(
-		"<b>%s</b> (%s)\n%s",
-		html.EscapeString(message.Author.Tag()), message.Author.ID,
-		html.EscapeString(locale.Time(message.Timestamp.Time(), true)),
-	)
-
-	// TODO: query tooltip
-	m.Avatar.SetTooltipMarkup(tooltip)
-	m.TopLabel.SetTooltipMarkup(tooltip)
 }
 
 func (m *cozyMessage) UpdateMember(member *discord.Member) {
@@ -278,12 +282,34 @@ func (m *cozyMessage) updateAuthor(message *gateway.MessageCreateEvent) {
 
 	state := gtkcord.FromContext(m.ctx())
 
-	markup := "<b>" + state.AuthorMarkup(message) + "</b>"
+	m.author = "<b>" + state.AuthorMarkup(message) + "</b>"
+	m.updateTopLabel()
+}
+
+func (m *cozyMessage) updateTopLabel() {
+	if m.message.message == nil {
+		return
+	}
+
+	markup := m.author
 	markup += ` <span alpha="75%" size="small">` +
-		locale.TimeAgo(message.Timestamp.Time()) +
+		html.EscapeString(gtkcord.FormatTimeAgo(m.message.message.Timestamp.Time())) +
 		"</span>"
 
 	m.TopLabel.SetMarkup(markup)
+
+	// The tooltip is also updated here, since the timestamp preferences
+	// change it.
+	msg := m.message.message
+	tooltip := fmt.Sprintf(
+		"<b>%s</b> (%s)\n%s",
+		html.EscapeString(msg.Author.Tag()), msg.Author.ID,
+		html.EscapeString(gtkcord.FormatTime(msg.Timestamp.Time(), true)),
+	)
+
+	// TODO: query tooltip
+	m.Avatar.SetTooltipMarkup(tooltip)
+	m.TopLabel.SetTooltipMarkup(tooltip)
 }
 
 // collapsedMessage is a collapsed cozy message.
@@ -326,12 +352,23 @@ func NewCollapsedMessage(ctx context.Context, v *View) Message {
 	m.Box.Append(m.Timestamp)
 	m.Box.Append(m.message.content)
 
+	gtkcord.SubscribeTimestamps(m, m.updateTimestamp)
+
 	collapsedCSS(m)
 	return &m
 }
 
 func (m *collapsedMessage) Update(message *gateway.MessageCreateEvent) {
 	m.message.update(m, &message.Message)
-	m.Timestamp.SetLabel(locale.Time(message.Timestamp.Time(), false))
-	m.Timestamp.SetTooltipText(locale.Time(message.Timestamp.Time(), true))
+	m.updateTimestamp()
+}
+
+func (m *collapsedMessage) updateTimestamp() {
+	if m.message.message == nil {
+		return
+	}
+
+	t := m.message.message.Timestamp.Time()
+	m.Timestamp.SetLabel(gtkcord.FormatTime(t, false))
+	m.Timestamp.SetTooltipText(gtkcord.FormatTime(t, true))
 }
@@ -0,0 +1,264 @@
+package message
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/diamondburned/chatkit/md/hl"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/textutil"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// maxTextPreviewSize is the size of the largest text attachment that's fetched
+// to be previewed. Larger files are shown as a plain file.
+const maxTextPreviewSize = 256 * 1024
+
+// textPreviewLines is the number of lines shown while a preview is collapsed.
+const textPreviewLines = 10
+
+// attachmentIsText returns true if the attachment is a text file that's small
+// enough to be previewed.
+func attachmentIsText(attachment *discord.Attachment) bool {
+	if attachment.Size == 0 || attachment.Size > maxTextPreviewSize {
+		return false
+	}
+	return gtkcord.FileIsText(attachment.ContentType, attachment.Filename)
+}
+
+type textPreview struct {
+	*gtk.Box
+	text   *gtk.TextView
+	scroll *gtk.ScrolledWindow
+	expand *gtk.ToggleButton
+	copy   *gtk.Button
+	full   *gtk.Button
+
+	ctx        context.Context
+	attachment *discord.Attachment
+	fetched    bool
+	content    string
+	head       string // first textPreviewLines lines of content
+}
+
+var textPreviewCSS = cssutil.Applier("message-textpreview", `
+	.message-textpreview {
+		border: 1px solid alpha(@theme_fg_color, 0.15);
+		border-radius: 4px;
+		padding: 2px 4px;
+	}
+	.message-textpreview-text {
+		background: none;
+		font-size: 0.9em;
+	}
+	.message-textpreview-actions button {
+		min-height: 0;
+		padding: 2px 6px;
+	}
+`)
+
+// newTextPreview creates a file attachment that also shows the beginning of the
+// file's content.
+func newTextPreview(ctx context.Context, attachment *discord.Attachment) gtk.Widgetter {
+	p := textPreview{
+		ctx:        ctx,
+		attachment: attachment,
+	}
+
+	p.text = gtk.NewTextView()
+	p.text.AddCSSClass("message-textpreview-text")
+	p.text.SetEditable(false)
+	p.text.SetCursorVisible(false)
+	p.text.SetWrapMode(gtk.WrapWordChar)
+	p.text.Buffer().SetText(locale.Get("Loading…"))
+	sourceCSS(p.text)
+	textutil.SetTabSize(p.text)
+
+	p.scroll = gtk.NewScrolledWindow()
+	p.scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	p.scroll.SetPropagateNaturalHeight(true)
+	p.scroll.SetMaxContentHeight(400)
+	p.scroll.SetChild(p.text)
+
+	// The file is only fetched once the preview is opened.
+	expander := gtk.NewExpander(locale.Get("Preview"))
+	expander.SetChild(p.scroll)
+	expander.NotifyProperty("expanded", func() {
+		if expander.Expanded() {
+			p.fetch()
+		}
+	})
+
+	p.expand = gtk.NewToggleButtonWithLabel(locale.Get("Show More"))
+	p.expand.AddCSSClass("flat")
+	p.expand.SetVisible(false)
+	p.expand.ConnectToggled(p.updateExpanded)
+
+	p.copy = gtk.NewButtonFromIconName("edit-copy-symbolic")
+	p.copy.AddCSSClass("flat")
+	p.copy.SetTooltipText(locale.Get("Copy"))
+	p.copy.SetSensitive(false)
+	p.copy.ConnectClicked(p.Copy)
+
+	p.full = gtk.NewButtonFromIconName("view-fullscreen-symbolic")
+	p.full.AddCSSClass("flat")
+	p.full.SetTooltipText(locale.Get("Expand Full"))
+	p.full.SetSensitive(false)
+	p.full.ConnectClicked(p.ShowFull)
+
+	actions := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	actions.AddCSSClass("message-textpreview-actions")
+	actions.Append(p.expand)
+	actions.Append(p.copy)
+	actions.Append(p.full)
+
+	header := newFileAttachment(ctx, attachment)
+	header.Append(actions)
+
+	p.Box = gtk.NewBox(gtk.OrientationVertical, 2)
+	p.Box.SetHAlign(gtk.AlignStart)
+	p.Box.SetSizeRequest(400, -1)
+	p.Box.Append(header)
+	p.Box.Append(expander)
+	textPreviewCSS(p)
+
+	return &p
+}
+
+// fetch fetches the file into the preview, unless it was already fetched.
+func (p *textPreview) fetch() {
+	if p.fetched {
+		return
+	}
+	p.fetched = true
+
+	ctx := p.ctx
+	url := p.attachment.URL
+
+	gtkutil.Async(ctx, func() func() {
+		content, err := fetchText(ctx, url)
+		if err != nil {
+			return func() {
+				p.text.Buffer().SetText(locale.Get("Cannot load preview: ") + err.Error())
+			}
+		}
+
+		return func() { p.setContent(content) }
+	})
+}
+
+// fetchText fetches the text file at the given URL. It's called in a
+// goroutine.
+func fetchText(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid URL")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxTextPreviewSize))
+	if err != nil {
+		return "", err
+	}
+
+	if !utf8.Valid(b) {
+		return "", errors.New("file is not valid UTF-8")
+	}
+
+	return string(b), nil
+}
+
+func (p *textPreview) setContent(content string) {
+	p.content = strings.TrimRight(content, "\n")
+	p.head = p.content
+
+	// Cut the text after textPreviewLines lines.
+	var lines, i int
+	for lines < textPreviewLines {
+		j := strings.IndexByte(p.content[i:], '\n')
+		if j == -1 {
+			break
+		}
+		i += j + 1
+		lines++
+	}
+	if lines == textPreviewLines {
+		p.head = p.content[:i-1]
+	}
+
+	p.expand.SetVisible(p.head != p.content)
+	p.copy.SetSensitive(true)
+	p.full.SetSensitive(true)
+	p.updateExpanded()
+}
+
+func (p *textPreview) updateExpanded() {
+	text := p.head
+	if p.expand.Active() {
+		text = p.content
+		p.expand.SetLabel(locale.Get("Show Less"))
+	} else {
+		p.expand.SetLabel(locale.Get("Show More"))
+	}
+
+	buf := p.text.Buffer()
+	buf.SetText(text)
+	hl.Highlight(p.ctx, buf.StartIter(), buf.EndIter(), p.attachment.Filename)
+}
+
+// Copy copies the whole file into the clipboard.
+func (p *textPreview) Copy() {
+	clipboard := gdk.DisplayGetDefault().Clipboard()
+	clipboard.SetText(p.content)
+}
+
+// ShowFull opens a dialog showing the whole file.
+func (p *textPreview) ShowFull() {
+	d := gtk.NewDialog()
+	d.SetTitle(app.FromContext(p.ctx).SuffixedTitle(p.attachment.Filename))
+	d.SetTransientFor(app.GTKWindowFromContext(p.ctx))
+	d.SetModal(true)
+	d.SetDefaultSize(600, 500)
+
+	buf := gtk.NewTextBuffer(nil)
+	buf.SetText(p.content)
+	hl.Highlight(p.ctx, buf.StartIter(), buf.EndIter(), p.attachment.Filename)
+
+	t := gtk.NewTextViewWithBuffer(buf)
+	t.SetEditable(false)
+	t.SetCursorVisible(false)
+	t.SetWrapMode(gtk.WrapWordChar)
+	sourceCSS(t)
+	textutil.SetTabSize(t)
+
+	s := gtk.NewScrolledWindow()
+	s.SetVExpand(true)
+	s.SetHExpand(true)
+	s.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	s.SetChild(t)
+
+	box := d.ContentArea()
+	box.Append(s)
+
+	d.Show()
+}
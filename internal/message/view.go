@@ -62,6 +62,8 @@ type View struct {
 	List     *gtk.ListBox
 	Composer *composer.View
 
+	dropOverlay *gtk.Overlay
+
 	msgs    map[messageKey]messageRow
 	chName  string
 	guildID discord.GuildID
@@ -72,6 +74,19 @@ type View struct {
 		replying bool
 	}
 
+	// highlightID is the message to highlight once the view is loaded.
+	highlightID discord.MessageID
+	// loading is true while the messages are being fetched.
+	loading bool
+	// edits keeps the previous versions of messages edited while the view
+	// is open.
+	edits map[discord.MessageID][]messageEdit
+	// audio is the audio player that was last played.
+	audio *audioPlayer
+	// revealed are the URLs of the spoiler attachments that were revealed.
+	// Other spoilers are left out of the media viewer.
+	revealed map[string]bool
+
 	ctx  context.Context
 	chID discord.ChannelID
 }
@@ -107,6 +122,9 @@ var viewCSS = cssutil.Applier("message-view", `
 	.message-list > row.message-sending {
 		opacity: 0.65;
 	}
+	.message-list > row.message-highlighted {
+		background-color: alpha(@theme_selected_bg_color, 0.25);
+	}
 `)
 
 // NewView creates a new View widget associated with the given channel ID. All
@@ -150,6 +168,8 @@ func NewView(ctx context.Context, chID discord.ChannelID) *View {
 	v.Box.Append(v.Composer)
 	v.Box.SetFocusChild(v.Composer)
 
+	v.bindDrop()
+
 	v.LoadablePage = adaptive.NewLoadablePage()
 	v.LoadablePage.SetTransitionDuration(125)
 	v.setPageToMain()
@@ -201,6 +221,12 @@ func NewView(ctx context.Context, chID discord.ChannelID) *View {
 
 			m, err := state.Cabinet.Message(ev.ChannelID, ev.ID)
 			if err == nil && !v.ignoreMessage(&ev.Message) {
+				if row, ok := v.msgs[messageKeyID(ev.ID)]; ok {
+					if old := row.message.Message(); old != nil {
+						v.recordEdit(old, m)
+					}
+				}
+
 				msg := v.upsertMessage(ev.ID, newMessageInfo(m))
 				msg.Update(&gateway.MessageCreateEvent{
 					Message: *m,
@@ -306,6 +332,7 @@ func (v *View) load() {
 
 	v.LoadablePage.SetLoading()
 	v.unload()
+	v.loading = true
 
 	state := gtkcord.FromContext(v.ctx)
 
@@ -313,6 +340,8 @@ func (v *View) load() {
 		msgs, err := state.Messages(v.chID, 45)
 		if err != nil {
 			return func() {
+				v.loading = false
+				v.highlightID = 0
 				v.LoadablePage.SetError(err)
 			}
 		}
@@ -322,6 +351,7 @@ func (v *View) load() {
 		})
 
 		return func() {
+			v.loading = false
 			v.setPageToMain()
 			v.Scroll.ScrollToBottom()
 
@@ -368,12 +398,17 @@ func (v *View) load() {
 
 				n += batch
 			}
+
+			if id := v.highlightID; id.IsValid() {
+				v.highlightID = 0
+				v.HighlightMessage(id)
+			}
 		}
 	})
 }
 
 func (v *View) setPageToMain() {
-	v.LoadablePage.SetChild(v.Box)
+	v.LoadablePage.SetChild(v.dropOverlay)
 }
 
 func (v *View) unload() {
@@ -551,6 +586,7 @@ func (v *View) SendMessage(msg composer.SendingMessage) {
 		Content:   msg.Content,
 		Timestamp: discord.NowTimestamp(),
 		Author:    *me,
+		Stickers:  msg.Stickers,
 	}
 
 	if msg.ReplyingTo.IsValid() {
@@ -573,23 +609,40 @@ func (v *View) SendMessage(msg composer.SendingMessage) {
 	// Use the Background context so things keep getting updated when we switch
 	// away.
 	gtkutil.Async(context.Background(), func() func() {
-		sendData := api.SendMessageData{
-			Content:   m.Content,
-			Reference: m.Reference,
-			Nonce:     key.Nonce(),
-			AllowedMentions: &api.AllowedMentions{
-				RepliedUser: &msg.ReplyMention,
-				Parse: []api.AllowedMentionType{
-					api.AllowUserMention,
-					api.AllowRoleMention,
-					api.AllowEveryoneMention,
+		sendData := gtkcord.SendMessageData{
+			SendMessageData: api.SendMessageData{
+				Content:   m.Content,
+				Reference: m.Reference,
+				Nonce:     key.Nonce(),
+				AllowedMentions: &api.AllowedMentions{
+					RepliedUser: &msg.ReplyMention,
+					Parse: []api.AllowedMentionType{
+						api.AllowUserMention,
+						api.AllowRoleMention,
+						api.AllowEveryoneMention,
+					},
 				},
 			},
 		}
 
+		for _, sticker := range msg.Stickers {
+			sendData.StickerIDs = append(sendData.StickerIDs, sticker.ID)
+		}
+
 		// Ensure that we open ALL files and defer-close them. Otherwise, we'll
 		// leak files.
 		for _, file := range msg.Files {
+			if file.Cleanup != nil {
+				defer file.Cleanup()
+			}
+
+			if file.Prepare != nil {
+				if err := file.Prepare(&file); err != nil {
+					glib.IdleAdd(func() { uploading.AppendError(err) })
+					continue
+				}
+			}
+
 			f, err := file.Open()
 			if err != nil {
 				glib.IdleAdd(func() { uploading.AppendError(err) })
@@ -605,7 +658,7 @@ func (v *View) SendMessage(msg composer.SendingMessage) {
 			})
 		}
 
-		_, err := state.SendMessageComplex(m.ChannelID, sendData)
+		_, err := state.SendMessageWithStickers(m.ChannelID, sendData)
 
 		return func() {
 			gtk.BaseWidget(row).RemoveCSSClass("message-sending")
@@ -637,6 +690,33 @@ func (v *View) ScrollToMessage(id discord.MessageID) bool {
 	return true
 }
 
+// HighlightMessage scrolls to and briefly highlights the message with the given
+// ID. If the view is still loading, then the message is highlighted once it's
+// done. Nothing happens if the message isn't among the loaded messages.
+func (v *View) HighlightMessage(id discord.MessageID) {
+	msg, ok := v.msgs[messageKeyID(id)]
+	if !ok {
+		if v.loading {
+			// Try again once the messages are loaded.
+			v.highlightID = id
+		} else {
+			log.Println("message to highlight is not loaded:", id)
+		}
+		return
+	}
+
+	v.highlightID = 0
+
+	if !v.ScrollToMessage(id) {
+		return
+	}
+
+	msg.AddCSSClass("message-highlighted")
+	glib.TimeoutSecondsAdd(2, func() {
+		msg.RemoveCSSClass("message-highlighted")
+	})
+}
+
 // ReplyTo starts replying to the message with the given ID.
 func (v *View) ReplyTo(id discord.MessageID) {
 	v.stopEditingOrReplying()
@@ -0,0 +1,244 @@
+package notifications
+
+import (
+	"log"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+const (
+	notificationsName  = "org.freedesktop.Notifications"
+	notificationsPath  = "/org/freedesktop/Notifications"
+	notificationsIface = "org.freedesktop.Notifications"
+)
+
+// Action keys of notifications sent through the inline reply server.
+const (
+	inlineActionOpen     = "default"
+	inlineActionMarkRead = "mark-read"
+	inlineActionReply    = "inline-reply"
+)
+
+// inlineServer sends notifications directly to a notification server that
+// supports inline replies, which GNotification can't do.
+type inlineServer struct {
+	conn    *dbus.Conn
+	obj     dbus.BusObject
+	app     *app.Application
+	signals chan *dbus.Signal
+
+	mu       sync.Mutex
+	handlers map[uint32]inlineHandler
+}
+
+// inlineHandler handles the actions of a notification. The functions are
+// called in the main thread.
+type inlineHandler struct {
+	action func(key string)
+	reply  func(text string)
+}
+
+// newInlineServer connects to the notification server. An error is returned
+// if the server doesn't support inline replies. It must be called in a
+// goroutine.
+func newInlineServer(app *app.Application) (*inlineServer, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot connect to session bus")
+	}
+
+	obj := conn.Object(notificationsName, notificationsPath)
+
+	var caps []string
+	if err := obj.Call(notificationsIface+".GetCapabilities", 0).Store(&caps); err != nil {
+		return nil, errors.Wrap(err, "cannot get notification server capabilities")
+	}
+
+	if !containsString(caps, "inline-reply") || !containsString(caps, "actions") {
+		return nil, errors.New("notification server doesn't support inline replies")
+	}
+
+	err = conn.AddMatchSignal(inlineMatch...)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot listen to notification signals")
+	}
+
+	s := inlineServer{
+		conn:     conn,
+		obj:      obj,
+		app:      app,
+		signals:  make(chan *dbus.Signal, 16),
+		handlers: make(map[uint32]inlineHandler),
+	}
+
+	conn.Signal(s.signals)
+	go s.listen(s.signals)
+
+	return &s, nil
+}
+
+// inlineMatch matches the signals of the notification server.
+var inlineMatch = []dbus.MatchOption{
+	dbus.WithMatchObjectPath(notificationsPath),
+	dbus.WithMatchInterface(notificationsIface),
+}
+
+// stop stops listening to the notification server. The session bus is shared,
+// so it's left open. It must be called in a goroutine.
+func (s *inlineServer) stop() {
+	s.conn.RemoveSignal(s.signals)
+	close(s.signals)
+
+	if err := s.conn.RemoveMatchSignal(inlineMatch...); err != nil {
+		log.Println("cannot stop listening to notification signals:", err)
+	}
+
+	s.mu.Lock()
+	s.handlers = make(map[uint32]inlineHandler)
+	s.mu.Unlock()
+}
+
+func (s *inlineServer) listen(signals <-chan *dbus.Signal) {
+	for sig := range signals {
+		if len(sig.Body) < 2 {
+			continue
+		}
+
+		id, ok := sig.Body[0].(uint32)
+		if !ok {
+			continue
+		}
+
+		s.mu.Lock()
+		h, ok := s.handlers[id]
+		s.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		switch sig.Name {
+		case notificationsIface + ".ActionInvoked":
+			key, _ := sig.Body[1].(string)
+			glib.IdleAdd(func() { h.action(key) })
+		case notificationsIface + ".NotificationReplied":
+			text, _ := sig.Body[1].(string)
+			glib.IdleAdd(func() { h.reply(text) })
+		case notificationsIface + ".NotificationClosed":
+			s.mu.Lock()
+			delete(s.handlers, id)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Notify sends a notification, replacing the one with the given ID if it's
+// not 0. The ID of the new notification is returned.
+func (s *inlineServer) Notify(replaces uint32, title, body, placeholder string, h inlineHandler) (uint32, error) {
+	actions := []string{
+		inlineActionOpen, locale.Get("Open"),
+		inlineActionMarkRead, locale.Get("Mark as Read"),
+		inlineActionReply, locale.Get("Reply"),
+	}
+
+	hints := map[string]dbus.Variant{
+		"desktop-entry":                dbus.MakeVariant(s.app.ID()),
+		"x-kde-reply-placeholder-text": dbus.MakeVariant(placeholder),
+	}
+
+	var id uint32
+	err := s.obj.Call(
+		notificationsIface+".Notify", 0,
+		s.app.Name(), replaces, s.app.ID(), title, body, actions, hints, int32(-1),
+	).Store(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	delete(s.handlers, replaces)
+	s.handlers[id] = h
+	s.mu.Unlock()
+
+	return id, nil
+}
+
+// Close closes the notification with the given ID.
+func (s *inlineServer) Close(id uint32) {
+	s.mu.Lock()
+	delete(s.handlers, id)
+	s.mu.Unlock()
+
+	s.obj.Call(notificationsIface+".CloseNotification", 0, id)
+}
+
+// sendInline sends the group's notification through the inline reply server.
+func (n *Notifier) sendInline(chID discord.ChannelID, g *group) {
+	state := gtkcord.FromContext(n.ctx)
+
+	title := n.title(g)
+	body := n.body(g)
+	placeholder := locale.Sprintf("Reply to %s", state.AuthorDisplayName(g.last))
+	msgID := g.last.ID
+	inline := n.inline
+	replaces := g.inlineID
+
+	h := inlineHandler{
+		action: func(key string) {
+			switch key {
+			case inlineActionOpen:
+				n.Clear(chID)
+				app.FromContext(n.ctx).ActivateAction("open-channel", gtkutil.NewJSONVariant(gtkcord.OpenChannelCommand{
+					ChannelID: chID,
+					MessageID: msgID,
+				}))
+			case inlineActionMarkRead:
+				n.markRead(gtkcord.MarkReadCommand{ChannelID: chID, MessageID: msgID})
+			case inlineActionReply:
+				// Servers that support inline replies send NotificationReplied
+				// instead, but fall back to the window just in case.
+				n.quickReply(gtkcord.ReplyCommand{ChannelID: chID, MessageID: msgID})
+			}
+		},
+		reply: func(text string) {
+			n.sendReply(chID, msgID, text, func(err error) {
+				if err != nil {
+					app.Error(n.ctx, errors.Wrap(err, "cannot send reply"))
+				}
+			})
+		},
+	}
+
+	gtkutil.Async(n.ctx, func() func() {
+		id, err := inline.Notify(replaces, title, body, placeholder, h)
+		if err != nil {
+			log.Println("cannot send inline notification:", err)
+			return nil
+		}
+
+		return func() {
+			// Only remember the ID if the channel still has a notification.
+			if current, ok := n.groups[chID]; ok && current == g {
+				g.inlineID = id
+			} else {
+				go inline.Close(id)
+			}
+		}
+	})
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
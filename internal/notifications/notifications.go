@@ -0,0 +1,294 @@
+// Package notifications sends desktop notifications for incoming messages.
+// Notifications are grouped per channel and come with buttons to mark the
+// channel as read or to reply to the message.
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gdkpixbuf/v2"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/app/notify"
+	"github.com/diamondburned/gotkit/app/sounds"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/thekrafter/arikawa-spacebar/v3/api"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// Notifier sends message notifications. Messages from the same channel are
+// grouped into one notification until the channel is read.
+type Notifier struct {
+	ctx    context.Context
+	groups map[discord.ChannelID]*group
+	inline *inlineServer // nil if inline replies aren't supported
+	quiet  quietHours
+}
+
+// group is the notification of a channel.
+type group struct {
+	count int
+	last  *gateway.MessageCreateEvent
+	// inlineID is the ID of the notification if it was sent through the
+	// inline reply server.
+	inlineID uint32
+}
+
+// NewNotifier creates a new Notifier. The notification actions are added
+// into the application. The Notifier stops once ctx is done, which should be
+// when the session ends.
+func NewNotifier(ctx context.Context) *Notifier {
+	n := Notifier{
+		ctx:    ctx,
+		groups: make(map[discord.ChannelID]*group),
+		quiet:  quietHours{state: gtkcord.FromContext(ctx)},
+	}
+
+	app.FromContext(ctx).AddJSONActions(map[string]interface{}{
+		"app.mark-read":   n.markRead,
+		"app.quick-reply": n.quickReply,
+	})
+
+	go func() {
+		inline, err := newInlineServer(app.FromContext(ctx))
+		if err != nil {
+			log.Println("inline notification replies unavailable:", err)
+			return
+		}
+
+		glib.IdleAdd(func() {
+			// Don't use gtkutil.Async, which would drop the server without
+			// stopping it if the session ended in the meantime.
+			if ctx.Err() != nil {
+				go inline.stop()
+				return
+			}
+			n.inline = inline
+		})
+	}()
+
+	n.quiet.start(ctx)
+
+	go func() {
+		<-ctx.Done()
+		glib.IdleAdd(n.stop)
+	}()
+
+	return &n
+}
+
+func (n *Notifier) stop() {
+	n.quiet.stop()
+
+	if n.inline != nil {
+		go n.inline.stop()
+		n.inline = nil
+	}
+}
+
+func notificationID(chID discord.ChannelID) notify.ID {
+	return notify.HashID(chID)
+}
+
+// Notify sends a notification for the message, replacing the channel's
+// previous notification.
+func (n *Notifier) Notify(ev *gateway.MessageCreateEvent) {
+	if n.ctx.Err() != nil || !notify.ShowNotification.Value() {
+		return
+	}
+
+	state := gtkcord.FromContext(n.ctx)
+
+	// Messages that are exempt from quiet hours also get through the Do Not
+	// Disturb status that quiet hours may have set.
+	if InQuietHours(time.Now()) {
+		if !quietHoursExempt(state, &ev.Message) {
+			return
+		}
+	} else if state.Status() == discord.DoNotDisturbStatus {
+		return
+	}
+
+	g, ok := n.groups[ev.ChannelID]
+	if !ok {
+		g = &group{}
+		n.groups[ev.ChannelID] = g
+	}
+	g.count++
+	g.last = ev
+
+	application := app.FromContext(n.ctx)
+	if notify.PlayNotificationSound.Value() {
+		sounds.Play(application, sounds.Message)
+	}
+
+	if n.inline != nil {
+		n.sendInline(ev.ChannelID, g)
+		return
+	}
+
+	notification := gio.NewNotification(n.title(g))
+	notification.SetBody(n.body(g))
+	notification.SetDefaultActionAndTarget("app.open-channel", gtkutil.NewJSONVariant(gtkcord.OpenChannelCommand{
+		ChannelID: ev.ChannelID,
+		MessageID: ev.ID,
+	}))
+	notification.AddButtonWithTarget(locale.Get("Mark as Read"), "app.mark-read", gtkutil.NewJSONVariant(gtkcord.MarkReadCommand{
+		ChannelID: ev.ChannelID,
+		MessageID: ev.ID,
+	}))
+	notification.AddButtonWithTarget(locale.Get("Reply"), "app.quick-reply", gtkutil.NewJSONVariant(gtkcord.ReplyCommand{
+		ChannelID: ev.ChannelID,
+		MessageID: ev.ID,
+	}))
+
+	id := string(notificationID(ev.ChannelID))
+	avatarURL := gtkcord.InjectAvatarSize(ev.Author.AvatarURL())
+
+	// The avatar is fetched in the background, so the notification has to be
+	// sent from there as well.
+	loading := fetchIcon(n.ctx, avatarURL)
+	go func() {
+		if icon, ok := <-loading; ok {
+			notification.SetIcon(icon)
+		} else {
+			notification.SetIcon(gio.NewThemedIcon("avatar-default-symbolic"))
+		}
+		application.SendNotification(id, notification)
+	}()
+}
+
+// Clear withdraws the channel's notification. It's called once the channel is
+// read.
+func (n *Notifier) Clear(chID discord.ChannelID) {
+	g, ok := n.groups[chID]
+	if !ok {
+		return
+	}
+	delete(n.groups, chID)
+
+	if g.inlineID != 0 && n.inline != nil {
+		inline := n.inline
+		go inline.Close(g.inlineID)
+		return
+	}
+
+	app.FromContext(n.ctx).WithdrawNotification(string(notificationID(chID)))
+}
+
+// title returns the title of the group's notification.
+func (n *Notifier) title(g *group) string {
+	channel := gtkcord.ChannelNameFromID(n.ctx, g.last.ChannelID)
+	if g.count == 1 {
+		state := gtkcord.FromContext(n.ctx)
+		return fmt.Sprintf("%s (%s)", state.AuthorDisplayName(g.last), channel)
+	}
+	return locale.Sprintf("%s (%d new messages)", channel, g.count)
+}
+
+// body returns the body of the group's notification.
+func (n *Notifier) body(g *group) string {
+	state := gtkcord.FromContext(n.ctx)
+	preview := state.MessagePreview(&g.last.Message)
+	if g.count == 1 {
+		return preview
+	}
+	return state.AuthorDisplayName(g.last) + ": " + preview
+}
+
+func (n *Notifier) markRead(cmd gtkcord.MarkReadCommand) {
+	msgID := cmd.MessageID
+	if g, ok := n.groups[cmd.ChannelID]; ok {
+		msgID = g.last.ID
+	}
+
+	state := gtkcord.FromContext(n.ctx)
+	state.ReadState.MarkRead(cmd.ChannelID, msgID)
+
+	n.Clear(cmd.ChannelID)
+}
+
+func (n *Notifier) quickReply(cmd gtkcord.ReplyCommand) {
+	showQuickReply(n.ctx, n, cmd.ChannelID, cmd.MessageID)
+}
+
+// sendReply sends the content as a reply to the message and clears the
+// channel's notification once it's sent. done is called with the error, if
+// any.
+func (n *Notifier) sendReply(chID discord.ChannelID, msgID discord.MessageID, content string, done func(error)) {
+	state := gtkcord.FromContext(n.ctx)
+
+	var guildID discord.GuildID
+	if ch, err := state.Cabinet.Channel(chID); err == nil {
+		guildID = ch.GuildID
+	}
+
+	// Use the Background context so that the message is still sent if the
+	// window is closed.
+	gtkutil.Async(context.Background(), func() func() {
+		mention := true
+		_, err := state.SendMessageWithStickers(chID, gtkcord.SendMessageData{
+			SendMessageData: api.SendMessageData{
+				Content: content,
+				Reference: &discord.MessageReference{
+					ChannelID: chID,
+					GuildID:   guildID,
+					MessageID: msgID,
+				},
+				AllowedMentions: &api.AllowedMentions{
+					RepliedUser: &mention,
+					Parse: []api.AllowedMentionType{
+						api.AllowUserMention,
+						api.AllowRoleMention,
+						api.AllowEveryoneMention,
+					},
+				},
+			},
+		})
+
+		return func() {
+			if err == nil {
+				n.Clear(chID)
+			}
+			done(err)
+		}
+	})
+}
+
+// fetchIcon fetches the image at the given URL as a notification icon. The
+// channel is closed without a value if the image can't be fetched.
+func fetchIcon(ctx context.Context, url string) <-chan gio.Iconner {
+	ch := make(chan gio.Iconner, 1)
+	if url == "" {
+		close(ch)
+		return ch
+	}
+
+	go func() {
+		ctx := imgutil.WithOpts(ctx,
+			imgutil.WithRescale(notify.MaxIconSize, notify.MaxIconSize),
+			imgutil.WithDoneFn(func(error) { close(ch) }),
+		)
+
+		imgutil.GET(ctx, url, imgutil.ImageSetter{
+			SetFromPixbuf: func(p *gdkpixbuf.Pixbuf) {
+				b, err := p.SaveToBufferv("png", []string{"compression"}, []string{"0"})
+				if err != nil {
+					log.Println("cannot save notification icon as PNG:", err)
+					return
+				}
+				ch <- gio.NewBytesIcon(glib.NewBytesWithGo(b))
+			},
+		})
+	}()
+
+	return ch
+}
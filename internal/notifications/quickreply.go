@@ -0,0 +1,119 @@
+package notifications
+
+import (
+	"context"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+var quickReplyCSS = cssutil.Applier("notifications-quick-reply", `
+	.notifications-quick-reply-box {
+		padding: 12px;
+	}
+	.notifications-quick-reply-preview {
+		color: alpha(@theme_fg_color, 0.75);
+	}
+`)
+
+// showQuickReply shows a small window to reply to the message with. It's
+// used when the notification server can't do inline replies. The main window
+// is left alone.
+func showQuickReply(ctx context.Context, n *Notifier, chID discord.ChannelID, msgID discord.MessageID) {
+	state := gtkcord.FromContext(ctx)
+	application := app.FromContext(ctx)
+
+	title := locale.Sprintf("Reply in %s", gtkcord.ChannelNameFromID(ctx, chID))
+
+	preview := gtk.NewLabel("")
+	preview.AddCSSClass("notifications-quick-reply-preview")
+	preview.SetXAlign(0)
+	preview.SetWrap(true)
+	preview.SetWrapMode(pango.WrapWordChar)
+	preview.SetLines(3)
+	preview.SetEllipsize(pango.EllipsizeEnd)
+
+	if msg, err := state.Cabinet.Message(chID, msgID); err == nil {
+		author := state.AuthorDisplayName(&gateway.MessageCreateEvent{Message: *msg})
+		title = locale.Sprintf("Reply to %s", author)
+		preview.SetText(state.MessagePreview(msg))
+	} else {
+		preview.Hide()
+	}
+
+	entry := gtk.NewEntry()
+	entry.SetHExpand(true)
+	entry.SetPlaceholderText(locale.Get("Message"))
+
+	send := gtk.NewButtonWithLabel(locale.Get("Send"))
+	send.AddCSSClass("suggested-action")
+	send.SetSensitive(false)
+
+	header := gtk.NewHeaderBar()
+	header.PackEnd(send)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 8)
+	box.AddCSSClass("notifications-quick-reply-box")
+	box.Append(preview)
+	box.Append(entry)
+
+	win := gtk.NewWindow()
+	win.SetApplication(application.Application)
+	win.SetTitle(application.SuffixedTitle(title))
+	win.SetTitlebar(header)
+	win.SetDefaultSize(360, -1)
+	win.SetHideOnClose(false)
+	win.SetChild(box)
+	quickReplyCSS(box)
+
+	if app.IsDevel() {
+		win.AddCSSClass("devel")
+	}
+
+	submit := func() {
+		content := entry.Text()
+		if content == "" {
+			return
+		}
+
+		entry.SetSensitive(false)
+		send.SetSensitive(false)
+
+		n.sendReply(chID, msgID, content, func(err error) {
+			if err != nil {
+				entry.SetSensitive(true)
+				send.SetSensitive(true)
+				app.Error(ctx, errors.Wrap(err, "cannot send reply"))
+				return
+			}
+			win.Close()
+		})
+	}
+
+	entry.ConnectChanged(func() { send.SetSensitive(entry.Text() != "") })
+	entry.ConnectActivate(submit)
+	send.ConnectClicked(submit)
+
+	esc := gtk.NewEventControllerKey()
+	esc.SetName("quick-reply-escape")
+	esc.ConnectKeyPressed(func(val, _ uint, state gdk.ModifierType) bool {
+		switch val {
+		case gdk.KEY_Escape:
+			win.Close()
+			return true
+		}
+		return false
+	})
+	win.AddController(esc)
+
+	win.Present()
+	entry.GrabFocus()
+}
@@ -0,0 +1,427 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+var quietHoursEnabled = prefs.NewBool(false, prefs.PropMeta{
+	Name:        "Enable Quiet Hours",
+	Section:     "Quiet Hours",
+	Description: "Suppress notifications and their sounds during the scheduled hours.",
+})
+
+var quietHoursSchedule = prefs.NewString("", prefs.StringMeta{
+	Name:    "Schedule",
+	Section: "Quiet Hours",
+	Description: "When quiet hours are in effect, one range per line, such as " +
+		"\"Mon-Fri 22:00-07:00\" or \"Sat,Sun 00:00-10:00\". " +
+		"Ranges that end before they start wrap past midnight.",
+	Placeholder: "Mon-Fri 22:00-07:00",
+	Multiline:   true,
+	Validate: func(str string) error {
+		_, err := parseQuietSchedule(str)
+		return err
+	},
+})
+
+var quietHoursAllowDMs = prefs.NewBool(false, prefs.PropMeta{
+	Name:        "Allow Direct Messages",
+	Section:     "Quiet Hours",
+	Description: "Still notify for direct messages during quiet hours.",
+})
+
+var quietHoursAllowUsers = prefs.NewString("", prefs.StringMeta{
+	Name:        "Allowed Users",
+	Section:     "Quiet Hours",
+	Description: "Comma-separated usernames or user IDs that still notify during quiet hours.",
+	Placeholder: "username, 123456789012345678",
+})
+
+var quietHoursKeywords = prefs.NewString("", prefs.StringMeta{
+	Name:        "Allowed Keywords",
+	Section:     "Quiet Hours",
+	Description: "Comma-separated keywords. Messages containing any of them still notify during quiet hours.",
+	Placeholder: "urgent, outage",
+})
+
+const (
+	quietStatusUnchanged = "Unchanged"
+	quietStatusIdle      = "Idle"
+	quietStatusDND       = "Do Not Disturb"
+	quietStatusInvisible = "Invisible"
+)
+
+var quietHoursStatus = prefs.NewEnumList(quietStatusUnchanged, prefs.EnumListMeta{
+	PropMeta: prefs.PropMeta{
+		Name:        "Status",
+		Section:     "Quiet Hours",
+		Description: "The status to switch to during quiet hours. The previous status is restored afterwards.",
+	},
+	Options: []string{
+		quietStatusUnchanged,
+		quietStatusIdle,
+		quietStatusDND,
+		quietStatusInvisible,
+	},
+})
+
+func init() {
+	prefs.Order(
+		quietHoursEnabled, quietHoursSchedule, quietHoursAllowDMs,
+		quietHoursAllowUsers, quietHoursKeywords, quietHoursStatus,
+	)
+}
+
+// quietRange is a range of time on some days of the week.
+type quietRange struct {
+	days [7]bool
+	// start and end are minutes since midnight. If end is before start, then
+	// the range ends on the next day.
+	start, end int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseQuietSchedule parses the schedule preference.
+func parseQuietSchedule(str string) ([]quietRange, error) {
+	var ranges []quietRange
+
+	for _, line := range strings.Split(str, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		r, err := parseQuietRange(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid range %q", line)
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+func parseQuietRange(line string) (quietRange, error) {
+	var r quietRange
+
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return r, errors.New("expected days and a time range, such as \"Mon-Fri 22:00-07:00\"")
+	}
+
+	for _, part := range strings.Split(fields[0], ",") {
+		from, to, isRange := strings.Cut(part, "-")
+
+		start, ok := weekdays[strings.ToLower(from)]
+		if !ok {
+			return r, fmt.Errorf("unknown day %q", from)
+		}
+
+		end := start
+		if isRange {
+			if end, ok = weekdays[strings.ToLower(to)]; !ok {
+				return r, fmt.Errorf("unknown day %q", to)
+			}
+		}
+
+		for day := start; ; day = (day + 1) % 7 {
+			r.days[day] = true
+			if day == end {
+				break
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return r, errors.New("expected a time range, such as \"22:00-07:00\"")
+	}
+
+	var err error
+	if r.start, err = parseClock(from); err != nil {
+		return r, err
+	}
+	if r.end, err = parseClock(to); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// parseClock parses a "15:04" time into minutes since midnight.
+func parseClock(str string) (int, error) {
+	h, m, ok := strings.Cut(str, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", str)
+	}
+
+	hour, err1 := strconv.Atoi(h)
+	min, err2 := strconv.Atoi(m)
+	if err1 != nil || err2 != nil || hour < 0 || hour > 24 || min < 0 || min > 59 || (hour == 24 && min != 0) {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", str)
+	}
+
+	return hour*60 + min, nil
+}
+
+// contains returns true if t is within the range.
+func (r quietRange) contains(t time.Time) bool {
+	now := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	switch {
+	case r.start == r.end:
+		// The whole day.
+		return r.days[today]
+	case r.start < r.end:
+		return r.days[today] && now >= r.start && now < r.end
+	default:
+		// Started on one of the days and ends on the next day.
+		return (r.days[today] && now >= r.start) || (r.days[yesterday] && now < r.end)
+	}
+}
+
+// quietRules are the parsed quiet hours preferences.
+type quietRules struct {
+	ranges   []quietRange
+	allowDMs bool
+	users    []string
+	keywords []string // lowercase
+}
+
+// quietRulesSource is the preferences that quietRules are parsed from.
+type quietRulesSource struct {
+	schedule string
+	allowDMs bool
+	users    string
+	keywords string
+}
+
+// quietRulesCache holds the quietRules until the preferences change, so that
+// they're not parsed again for every message. Notifications use it outside of
+// the main thread.
+var quietRulesCache struct {
+	sync.Mutex
+	source quietRulesSource
+	rules  *quietRules
+}
+
+// loadQuietRules returns the quietRules for the current preferences.
+func loadQuietRules() *quietRules {
+	source := quietRulesSource{
+		schedule: quietHoursSchedule.Value(),
+		allowDMs: quietHoursAllowDMs.Value(),
+		users:    quietHoursAllowUsers.Value(),
+		keywords: quietHoursKeywords.Value(),
+	}
+
+	quietRulesCache.Lock()
+	defer quietRulesCache.Unlock()
+
+	if quietRulesCache.rules == nil || quietRulesCache.source != source {
+		quietRulesCache.source = source
+		quietRulesCache.rules = parseQuietRules(source)
+	}
+
+	return quietRulesCache.rules
+}
+
+func parseQuietRules(source quietRulesSource) *quietRules {
+	// The schedule is validated before it's saved, so an error only happens
+	// with a broken config file, in which case quiet hours are never active.
+	ranges, err := parseQuietSchedule(source.schedule)
+	if err != nil {
+		log.Println("quiet hours: invalid schedule:", err)
+	}
+
+	keywords := splitList(source.keywords)
+	for i, keyword := range keywords {
+		keywords[i] = strings.ToLower(keyword)
+	}
+
+	return &quietRules{
+		ranges:   ranges,
+		allowDMs: source.allowDMs,
+		users:    splitList(source.users),
+		keywords: keywords,
+	}
+}
+
+// InQuietHours returns true if quiet hours are in effect at the given time.
+func InQuietHours(t time.Time) bool {
+	if !quietHoursEnabled.Value() {
+		return false
+	}
+	return loadQuietRules().active(t.In(gtkcord.TimeLocation()))
+}
+
+// active returns true if t is within any of the ranges.
+func (r *quietRules) active(t time.Time) bool {
+	for _, qr := range r.ranges {
+		if qr.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// quietHoursExempt returns true if the message should notify even during
+// quiet hours.
+func quietHoursExempt(state *gtkcord.State, msg *discord.Message) bool {
+	var isDM bool
+	if !msg.GuildID.IsValid() {
+		ch, err := state.Cabinet.Channel(msg.ChannelID)
+		isDM = err == nil && !ch.GuildID.IsValid()
+	}
+
+	return loadQuietRules().exempt(msg, isDM)
+}
+
+// exempt returns true if the message should notify even during quiet hours.
+// isDM is true if the message was sent in a direct message channel.
+func (r *quietRules) exempt(msg *discord.Message, isDM bool) bool {
+	if r.allowDMs && isDM {
+		return true
+	}
+
+	for _, user := range r.users {
+		if user == msg.Author.ID.String() ||
+			strings.EqualFold(user, msg.Author.Username) ||
+			strings.EqualFold(user, msg.Author.Tag()) {
+			return true
+		}
+	}
+
+	content := strings.ToLower(msg.Content)
+	for _, keyword := range r.keywords {
+		if strings.Contains(content, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitList(str string) []string {
+	var list []string
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// quietStatus returns the status to switch to during quiet hours, or an empty
+// status if it shouldn't be changed.
+func quietStatus() discord.Status {
+	switch quietHoursStatus.Value() {
+	case quietStatusIdle:
+		return discord.IdleStatus
+	case quietStatusDND:
+		return discord.DoNotDisturbStatus
+	case quietStatusInvisible:
+		return discord.InvisibleStatus
+	default:
+		return ""
+	}
+}
+
+// quietHours switches the user's status when quiet hours start and restores
+// it once they end. The status is changed as an automatic status of the State,
+// which restores the user's own status.
+type quietHours struct {
+	state *gtkcord.State
+	// status is the status that quiet hours currently switch to. It's empty
+	// outside of quiet hours or if the status isn't changed.
+	status discord.Status
+	unsubs []func()
+}
+
+// quietCheckInterval is how often quiet hours are checked, in seconds.
+const quietCheckInterval = 30
+
+// start starts checking quiet hours until stop is called. ctx should be done
+// by then.
+func (q *quietHours) start(ctx context.Context) {
+	q.update()
+	glib.TimeoutSecondsAdd(quietCheckInterval, func() bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		q.update()
+		return true
+	})
+
+	for _, prop := range []interface{ Subscribe(func()) func() }{
+		quietHoursEnabled, quietHoursSchedule, quietHoursStatus,
+	} {
+		q.unsubs = append(q.unsubs, prop.Subscribe(q.update))
+	}
+}
+
+// stop stops following changes to the quiet hours settings.
+func (q *quietHours) stop() {
+	for _, unsub := range q.unsubs {
+		unsub()
+	}
+	q.unsubs = nil
+}
+
+// update checks whether quiet hours started or ended and changes the status
+// accordingly.
+func (q *quietHours) update() {
+	var status discord.Status
+	if InQuietHours(time.Now()) {
+		status = quietStatus()
+	}
+
+	if status == q.status {
+		return
+	}
+
+	if q.state.Status() == discord.OfflineStatus {
+		// We don't know the user's status yet.
+		return
+	}
+
+	q.status = status
+
+	state := q.state
+	gtkutil.Async(context.Background(), func() func() {
+		var err error
+		if status != "" {
+			err = state.SetAutoStatus(gtkcord.QuietHoursAutoStatus, status, false)
+		} else {
+			err = state.ClearAutoStatus(gtkcord.QuietHoursAutoStatus)
+		}
+		if err != nil {
+			log.Println("quiet hours: cannot set status:", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,150 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+)
+
+func TestParseQuietSchedule(t *testing.T) {
+	ranges, err := parseQuietSchedule("Mon-Fri 22:00-07:00\n\n  sat,SUN 00:00-10:30  \nFri-Mon 12:00-13:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ranges) != 3 {
+		t.Fatalf("expected 3 ranges, got %d", len(ranges))
+	}
+
+	weekdays := [7]bool{false, true, true, true, true, true, false}
+	if ranges[0].days != weekdays {
+		t.Errorf("unexpected days %v", ranges[0].days)
+	}
+	if ranges[0].start != 22*60 || ranges[0].end != 7*60 {
+		t.Errorf("unexpected time range %d-%d", ranges[0].start, ranges[0].end)
+	}
+
+	weekend := [7]bool{true, false, false, false, false, false, true}
+	if ranges[1].days != weekend {
+		t.Errorf("unexpected days %v", ranges[1].days)
+	}
+	if ranges[1].start != 0 || ranges[1].end != 10*60+30 {
+		t.Errorf("unexpected time range %d-%d", ranges[1].start, ranges[1].end)
+	}
+
+	// Day ranges also wrap around the end of the week.
+	friToMon := [7]bool{true, true, false, false, false, true, true}
+	if ranges[2].days != friToMon {
+		t.Errorf("unexpected days %v", ranges[2].days)
+	}
+}
+
+func TestParseQuietScheduleInvalid(t *testing.T) {
+	tests := []string{
+		"Mon-Fri",
+		"Mon-Fri 22:00",
+		"Mon-Fri 22:00-07:00 extra",
+		"Someday 22:00-07:00",
+		"Mon-Someday 22:00-07:00",
+		"Mon 25:00-07:00",
+		"Mon 22:60-07:00",
+		"Mon 24:30-07:00",
+		"Mon 22-07",
+	}
+
+	for _, test := range tests {
+		if _, err := parseQuietSchedule(test); err == nil {
+			t.Errorf("%q: expected an error", test)
+		}
+	}
+}
+
+// at returns the given time in the week of Monday, 2 January 2023.
+func at(day time.Weekday, hour, min int) time.Time {
+	return time.Date(2023, 1, 1+int(day), hour, min, 0, 0, time.UTC)
+}
+
+func TestQuietRangeContains(t *testing.T) {
+	overnight, err := parseQuietRange("Mon-Fri 22:00-07:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	daytime, err := parseQuietRange("Sat 09:00-17:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allDay, err := parseQuietRange("Sun 00:00-00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		r    quietRange
+		t    time.Time
+		in   bool
+	}{
+		{"overnight start", overnight, at(time.Monday, 22, 0), true},
+		{"overnight before start", overnight, at(time.Monday, 21, 59), false},
+		{"overnight after midnight", overnight, at(time.Tuesday, 3, 0), true},
+		{"overnight end", overnight, at(time.Tuesday, 7, 0), false},
+		{"overnight from last day", overnight, at(time.Saturday, 6, 59), true},
+		{"overnight not started on the day before", overnight, at(time.Monday, 3, 0), false},
+		{"overnight other day", overnight, at(time.Saturday, 22, 0), false},
+		{"daytime", daytime, at(time.Saturday, 12, 0), true},
+		{"daytime end", daytime, at(time.Saturday, 17, 0), false},
+		{"daytime other day", daytime, at(time.Sunday, 12, 0), false},
+		{"all day", allDay, at(time.Sunday, 23, 59), true},
+		{"all day other day", allDay, at(time.Monday, 0, 0), false},
+	}
+
+	for _, test := range tests {
+		if in := test.r.contains(test.t); in != test.in {
+			t.Errorf("%s: expected %v, got %v", test.name, test.in, in)
+		}
+	}
+}
+
+func TestQuietHoursExempt(t *testing.T) {
+	rules := parseQuietRules(quietRulesSource{
+		allowDMs: true,
+		users:    "Alice, 1234",
+		keywords: "Outage, urgent",
+	})
+
+	message := func(userID discord.UserID, username, content string) *discord.Message {
+		return &discord.Message{
+			Author:  discord.User{ID: userID, Username: username},
+			Content: content,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		msg    *discord.Message
+		isDM   bool
+		exempt bool
+	}{
+		{"direct message", message(1, "bob", "hi"), true, true},
+		{"guild message", message(1, "bob", "hi"), false, false},
+		{"allowed username", message(1, "alice", "hi"), false, true},
+		{"allowed user ID", message(1234, "carol", "hi"), false, true},
+		{"keyword", message(1, "bob", "There's an OUTAGE!"), false, true},
+		{"other keyword", message(1, "bob", "this is urgent"), false, true},
+		{"no keyword", message(1, "bob", "all good"), false, false},
+	}
+
+	for _, test := range tests {
+		if exempt := rules.exempt(test.msg, test.isDM); exempt != test.exempt {
+			t.Errorf("%s: expected %v, got %v", test.name, test.exempt, exempt)
+		}
+	}
+
+	noDMs := parseQuietRules(quietRulesSource{})
+	if noDMs.exempt(message(1, "bob", "hi"), true) {
+		t.Error("direct message is exempt without allowing direct messages")
+	}
+}
@@ -0,0 +1,342 @@
+// Package notifsettings provides dialogs for changing the user's notification
+// settings of guilds, categories and channels.
+package notifsettings
+
+import (
+	"context"
+	"time"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// Dialog is a dialog for changing the notification settings of a guild, a
+// category or a channel.
+type Dialog struct {
+	*gtk.Dialog
+	ctx     context.Context
+	guildID discord.GuildID
+	chID    discord.ChannelID // zero for guilds
+
+	muteRow  *adw.ActionRow
+	mute     *gtk.Switch
+	duration *adw.ComboRow
+	notify   *adw.ComboRow
+	levels   []gateway.UserNotification
+
+	suppressEveryone *gtk.Switch
+	suppressRoles    *gtk.Switch
+
+	// updating is true while invalidate is updating the widgets, so that
+	// their signals don't send the settings back.
+	updating bool
+}
+
+const dialogFlags = 0 |
+	gtk.DialogDestroyWithParent |
+	gtk.DialogModal |
+	gtk.DialogUseHeaderBar
+
+// ShowGuildDialog shows a dialog for the notification settings of the guild.
+func ShowGuildDialog(ctx context.Context, guildID discord.GuildID) {
+	state := gtkcord.FromContext(ctx)
+
+	name := locale.Get("Server")
+	if guild, err := state.Cabinet.Guild(guildID); err == nil {
+		name = guild.Name
+	}
+
+	d := newDialog(ctx, guildID, 0, name)
+	d.Show()
+}
+
+// ShowChannelDialog shows a dialog for the notification settings of the
+// channel or category.
+func ShowChannelDialog(ctx context.Context, chID discord.ChannelID) {
+	state := gtkcord.FromContext(ctx)
+
+	ch, err := state.Cabinet.Channel(chID)
+	if err != nil {
+		app.Error(ctx, errors.Wrap(err, "cannot get channel"))
+		return
+	}
+
+	name := ch.Name
+	if ch.Type != discord.GuildCategory {
+		name = "#" + name
+	}
+
+	d := newDialog(ctx, ch.GuildID, ch.ID, name)
+	d.Show()
+}
+
+func newDialog(ctx context.Context, guildID discord.GuildID, chID discord.ChannelID, name string) *Dialog {
+	d := Dialog{
+		ctx:     ctx,
+		guildID: guildID,
+		chID:    chID,
+	}
+
+	d.mute = gtk.NewSwitch()
+	d.mute.SetVAlign(gtk.AlignCenter)
+	d.mute.NotifyProperty("active", func() {
+		if d.updating {
+			return
+		}
+		if d.mute.Active() {
+			d.muteFor(gtkcord.MuteDurations[d.duration.Selected()])
+		} else {
+			d.unmute()
+		}
+	})
+
+	d.muteRow = adw.NewActionRow()
+	d.muteRow.SetTitle(locale.Sprintf("Mute %s", name))
+	d.muteRow.AddSuffix(d.mute)
+	d.muteRow.SetActivatableWidget(d.mute)
+
+	durations := make([]string, len(gtkcord.MuteDurations))
+	for i, duration := range gtkcord.MuteDurations {
+		durations[i] = durationLabel(duration)
+	}
+
+	d.duration = adw.NewComboRow()
+	d.duration.SetTitle(locale.Get("Duration"))
+	d.duration.SetModel(gtk.NewStringList(durations))
+	d.duration.NotifyProperty("selected", func() {
+		if d.updating || !d.mute.Active() {
+			return
+		}
+		d.muteFor(gtkcord.MuteDurations[d.duration.Selected()])
+	})
+
+	muteGroup := adw.NewPreferencesGroup()
+	muteGroup.SetTitle(locale.Get("Mute"))
+	muteGroup.SetDescription(locale.Get("Muting hides unread indicators and stops notifications, except for mentions."))
+	muteGroup.Add(d.muteRow)
+	muteGroup.Add(d.duration)
+
+	var levels []string
+	if chID.IsValid() {
+		state := gtkcord.FromContext(ctx)
+		if ch, err := state.Cabinet.Channel(chID); err == nil && ch.ParentID.IsValid() {
+			levels = append(levels, locale.Get("Use Category Default"))
+		} else {
+			levels = append(levels, locale.Get("Use Server Default"))
+		}
+		d.levels = append(d.levels, gateway.GuildDefaults)
+	}
+	levels = append(levels,
+		locale.Get("All Messages"),
+		locale.Get("Only @mentions"),
+		locale.Get("Nothing"),
+	)
+	d.levels = append(d.levels,
+		gateway.AllNotifications,
+		gateway.OnlyMentions,
+		gateway.NoNotifications,
+	)
+
+	d.notify = adw.NewComboRow()
+	d.notify.SetTitle(locale.Get("Notify Me About"))
+	d.notify.SetModel(gtk.NewStringList(levels))
+	d.notify.NotifyProperty("selected", func() {
+		if d.updating {
+			return
+		}
+		level := d.levels[d.notify.Selected()]
+		d.modify(gtkcord.ChannelOverrideData{Notifications: &level})
+	})
+
+	notifyGroup := adw.NewPreferencesGroup()
+	notifyGroup.SetTitle(locale.Get("Notifications"))
+	notifyGroup.Add(d.notify)
+
+	page := adw.NewPreferencesPage()
+	page.Add(muteGroup)
+	page.Add(notifyGroup)
+
+	if !chID.IsValid() {
+		d.suppressEveryone = gtk.NewSwitch()
+		d.suppressEveryone.SetVAlign(gtk.AlignCenter)
+		d.suppressEveryone.NotifyProperty("active", func() {
+			if d.updating {
+				return
+			}
+			suppress := d.suppressEveryone.Active()
+			d.send(gtkcord.GuildSettingsData{SuppressEveryone: &suppress})
+		})
+
+		everyoneRow := adw.NewActionRow()
+		everyoneRow.SetTitle(locale.Get("Suppress @everyone and @here"))
+		everyoneRow.AddSuffix(d.suppressEveryone)
+		everyoneRow.SetActivatableWidget(d.suppressEveryone)
+
+		d.suppressRoles = gtk.NewSwitch()
+		d.suppressRoles.SetVAlign(gtk.AlignCenter)
+		d.suppressRoles.NotifyProperty("active", func() {
+			if d.updating {
+				return
+			}
+			suppress := d.suppressRoles.Active()
+			d.send(gtkcord.GuildSettingsData{SuppressRoles: &suppress})
+		})
+
+		rolesRow := adw.NewActionRow()
+		rolesRow.SetTitle(locale.Get("Suppress All Role @mentions"))
+		rolesRow.AddSuffix(d.suppressRoles)
+		rolesRow.SetActivatableWidget(d.suppressRoles)
+
+		notifyGroup.Add(everyoneRow)
+		notifyGroup.Add(rolesRow)
+	}
+
+	d.Dialog = gtk.NewDialogWithFlags(
+		app.FromContext(ctx).SuffixedTitle(locale.Get("Notification Settings")),
+		app.GTKWindowFromContext(ctx),
+		dialogFlags,
+	)
+	d.Dialog.SetHideOnClose(false)
+	d.Dialog.SetDefaultSize(450, 500)
+	d.Dialog.SetChild(page)
+
+	esc := gtk.NewEventControllerKey()
+	esc.SetName("dialog-escape")
+	esc.ConnectKeyPressed(func(val, _ uint, state gdk.ModifierType) bool {
+		switch val {
+		case gdk.KEY_Escape:
+			d.Dialog.Close()
+			return true
+		}
+		return false
+	})
+	d.Dialog.AddController(esc)
+
+	if app.IsDevel() {
+		d.Dialog.AddCSSClass("devel")
+	}
+
+	d.invalidate()
+	return &d
+}
+
+// invalidate updates the widgets to match the current settings.
+func (d *Dialog) invalidate() {
+	state := gtkcord.FromContext(d.ctx)
+
+	var muted bool
+	var muteConfig *gateway.UserMuteConfig
+	var level gateway.UserNotification
+
+	if d.chID.IsValid() {
+		override, _ := state.ChannelOverride(d.guildID, d.chID)
+		muted = override.Muted
+		muteConfig = override.MuteConfig
+		level = override.Notifications
+	} else {
+		settings := state.MutedState.GuildSettings(d.guildID)
+		muted = settings.Muted
+		muteConfig = settings.MuteConfig
+		level = settings.Notifications
+		if level == gateway.GuildDefaults {
+			level = state.GuildDefaultNotifications(d.guildID)
+		}
+
+		d.updating = true
+		d.suppressEveryone.SetActive(settings.SuppressEveryone)
+		d.suppressRoles.SetActive(settings.SuppressRoles)
+		d.updating = false
+	}
+
+	d.updating = true
+	defer func() { d.updating = false }()
+
+	active := gtkcord.MuteIsActive(muted, muteConfig)
+	d.mute.SetActive(active)
+
+	d.muteRow.SetSubtitle("")
+	if active && muteConfig != nil && muteConfig.SelectedTimeWindow != -1 && muteConfig.EndTime.IsValid() {
+		d.muteRow.SetSubtitle(locale.Sprintf(
+			"Muted until %s", gtkcord.FormatTime(muteConfig.EndTime.Time(), true),
+		))
+
+		window := time.Duration(muteConfig.SelectedTimeWindow) * time.Second
+		for i, duration := range gtkcord.MuteDurations {
+			if duration == window {
+				d.duration.SetSelected(uint(i))
+			}
+		}
+	} else if active {
+		d.duration.SetSelected(uint(len(gtkcord.MuteDurations) - 1))
+	}
+
+	for i, l := range d.levels {
+		if l == level {
+			d.notify.SetSelected(uint(i))
+		}
+	}
+}
+
+func (d *Dialog) muteFor(duration time.Duration) {
+	muted := true
+	d.modify(gtkcord.ChannelOverrideData{
+		Muted:      &muted,
+		MuteConfig: gtkcord.NewMuteConfig(duration),
+	})
+}
+
+func (d *Dialog) unmute() {
+	muted := false
+	d.modify(gtkcord.ChannelOverrideData{Muted: &muted})
+}
+
+// modify modifies the settings of the guild or, if the dialog is for a
+// channel, the channel's override.
+func (d *Dialog) modify(data gtkcord.ChannelOverrideData) {
+	var settings gtkcord.GuildSettingsData
+	if d.chID.IsValid() {
+		settings.ChannelOverrides = map[discord.ChannelID]gtkcord.ChannelOverrideData{
+			d.chID: data,
+		}
+	} else {
+		settings.Muted = data.Muted
+		settings.MuteConfig = data.MuteConfig
+		settings.Notifications = data.Notifications
+	}
+	d.send(settings)
+}
+
+func (d *Dialog) send(data gtkcord.GuildSettingsData) {
+	state := gtkcord.FromContext(d.ctx)
+
+	gtkutil.Async(d.ctx, func() func() {
+		err := state.ModifyGuildSettings(d.guildID, data)
+		return func() {
+			if err != nil {
+				app.Error(d.ctx, errors.Wrap(err, "cannot change notification settings"))
+			}
+			d.invalidate()
+		}
+	})
+}
+
+func durationLabel(d time.Duration) string {
+	switch {
+	case d == 0:
+		return locale.Get("Until I Turn It Back On")
+	case d < time.Hour:
+		return locale.Sprintf("For %d Minutes", int(d/time.Minute))
+	case d == time.Hour:
+		return locale.Get("For 1 Hour")
+	default:
+		return locale.Sprintf("For %d Hours", int(d/time.Hour))
+	}
+}
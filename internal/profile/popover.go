@@ -0,0 +1,139 @@
+// Package profile provides a popover that shows a user's profile.
+package profile
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/components/onlineimage"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/customstatus"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// avatarSize is the size of the avatar in the popover.
+const avatarSize = 64 // px
+
+var popoverCSS = cssutil.Applier("profile-popover", `
+	.profile-popover-box {
+		padding: 6px;
+	}
+	.profile-popover-avatar {
+		margin-bottom: 6px;
+	}
+	.profile-popover-tag {
+		font-size: 0.9em;
+		opacity: 0.75;
+	}
+	.profile-popover-status {
+		margin-top: 6px;
+	}
+	.profile-popover-customstatus {
+		margin-top: 2px;
+	}
+`)
+
+// NewPopover creates a popover that shows the profile of the user in the
+// guild, which may be 0. If the user is the current user, the popover has a
+// button to set their custom status.
+func NewPopover(ctx context.Context, parent gtk.Widgetter, guildID discord.GuildID, user *discord.User) *gtk.Popover {
+	state := gtkcord.FromContext(ctx)
+
+	avatarURL := user.AvatarURL()
+	if guildID.IsValid() {
+		if member, _ := state.Cabinet.Member(guildID, user.ID); member != nil && member.Avatar != "" {
+			avatarURL = member.AvatarURL(guildID)
+		}
+	}
+
+	avatar := onlineimage.NewAvatar(ctx, imgutil.HTTPProvider, avatarSize)
+	avatar.AddCSSClass("profile-popover-avatar")
+	avatar.SetHAlign(gtk.AlignStart)
+	avatar.SetInitials(user.DisplayOrUsername())
+	avatar.SetFromURL(gtkcord.InjectSize(avatarURL, avatarSize))
+
+	name := gtk.NewLabel("")
+	name.AddCSSClass("profile-popover-name")
+	name.SetXAlign(0)
+	name.SetEllipsize(pango.EllipsizeEnd)
+	name.SetMarkup("<b>" + state.UserMarkup(guildID, user) + "</b>")
+
+	tag := user.Username
+	if v, _ := strconv.Atoi(user.Discriminator); v != 0 {
+		tag += "#" + user.Discriminator
+	}
+
+	tagLabel := gtk.NewLabel(tag)
+	tagLabel.AddCSSClass("profile-popover-tag")
+	tagLabel.SetXAlign(0)
+	tagLabel.SetSelectable(true)
+
+	status := discord.OfflineStatus
+	if p := state.UserPresence(guildID, user.ID); p != nil {
+		status = p.Status
+	}
+
+	statusIcon := gtk.NewImageFromIconName(gtkcord.StatusIcon(status))
+	statusLabel := gtk.NewLabel(locale.Get(gtkcord.StatusText(status)))
+	statusLabel.SetXAlign(0)
+
+	statusBox := gtk.NewBox(gtk.OrientationHorizontal, 4)
+	statusBox.AddCSSClass("profile-popover-status")
+	statusBox.Append(statusIcon)
+	statusBox.Append(statusLabel)
+
+	custom := customstatus.NewLabel(ctx)
+	custom.AddCSSClass("profile-popover-customstatus")
+	custom.SetActivity(state.UserCustomStatus(guildID, user.ID))
+
+	box := gtk.NewBox(gtk.OrientationVertical, 0)
+	box.AddCSSClass("profile-popover-box")
+	box.SetSizeRequest(gtkutil.PopoverWidth, -1)
+	box.Append(avatar)
+	box.Append(name)
+	box.Append(tagLabel)
+	box.Append(statusBox)
+	box.Append(custom)
+
+	popover := gtk.NewPopover()
+	popover.SetParent(parent)
+	popover.SetChild(box)
+	popoverCSS(popover)
+
+	if me, _ := state.Me(); me != nil && me.ID == user.ID {
+		set := gtk.NewButtonWithLabel(locale.Get("Set Custom Status…"))
+		set.AddCSSClass("profile-popover-setstatus")
+		set.SetMarginTop(6)
+		set.SetActionName("discord.set-custom-status")
+		set.ConnectClicked(popover.Popdown)
+		box.Append(set)
+	}
+
+	return popover
+}
+
+// Bind shows the profile popover of the user returned by f when the widget is
+// clicked. f may return nil if there's no user.
+func Bind(ctx context.Context, w gtk.Widgetter, f func() (discord.GuildID, *discord.User)) {
+	click := gtk.NewGestureClick()
+	click.SetButton(1)
+	click.ConnectPressed(func(int, float64, float64) {
+		guildID, user := f()
+		if user == nil {
+			return
+		}
+
+		p := NewPopover(ctx, w, guildID, user)
+		gtkutil.PopupFinally(p)
+	})
+
+	base := gtk.BaseWidget(w)
+	base.SetCursorFromName("pointer")
+	base.AddController(click)
+}
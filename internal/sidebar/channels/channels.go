@@ -7,12 +7,14 @@ import (
 	"github.com/diamondburned/adaptive"
 	"github.com/thekrafter/arikawa-spacebar/v3/discord"
 	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotk4/pkg/pango"
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/notifsettings"
 	"github.com/diamondburned/ningen/v3/states/read"
 	"github.com/pkg/errors"
 )
@@ -221,6 +223,10 @@ func NewView(ctx context.Context, ctrl Opener, guildID discord.GuildID) *View {
 		}
 	})
 
+	gtkutil.BindRightClickAt(v.Child.Tree, func(x, y float64) {
+		v.showChannelMenu(x, y)
+	})
+
 	selection := v.Child.Tree.Selection()
 	selection.SetMode(gtk.SelectionBrowse)
 
@@ -329,6 +335,10 @@ func NewView(ctx context.Context, ctrl Opener, guildID discord.GuildID) *View {
 			if ev.GuildID == v.guildID {
 				v.tree.UpdateChannel(ev.ChannelID)
 			}
+		case *gateway.UserGuildSettingsUpdateEvent:
+			if ev.GuildID == v.guildID {
+				v.InvalidateChannels()
+			}
 		}
 	},
 		(*read.UpdateEvent)(nil),
@@ -341,6 +351,7 @@ func NewView(ctx context.Context, ctrl Opener, guildID discord.GuildID) *View {
 		(*gateway.ThreadUpdateEvent)(nil),
 		(*gateway.ThreadDeleteEvent)(nil),
 		(*gateway.VoiceStateUpdateEvent)(nil),
+		(*gateway.UserGuildSettingsUpdateEvent)(nil),
 	)
 
 	viewCSS(v)
@@ -423,6 +434,43 @@ func (v *View) InvalidateChannels() {
 	}
 }
 
+// showChannelMenu shows the context menu of the channel or category at the
+// given point on the tree.
+func (v *View) showChannelMenu(x, y float64) {
+	if v.tree == nil {
+		return
+	}
+
+	bx, by := v.Child.Tree.ConvertWidgetToBinWindowCoords(int(x), int(y))
+	path, _, _, _, ok := v.Child.Tree.PathAtPos(bx, by)
+	if !ok {
+		return
+	}
+
+	node := v.tree.NodeFromPath(path)
+	if node == nil {
+		return
+	}
+
+	chID := node.ID()
+	ctx := v.ctx.Take()
+
+	gtkutil.BindActionMap(v.Child.Tree, map[string]func(){
+		"channel.notification-settings": func() { notifsettings.ShowChannelDialog(ctx, chID) },
+	})
+
+	popover := gtkutil.NewPopoverMenuCustom(v.Child.Tree, gtk.PosBottom, []gtkutil.PopoverMenuItem{
+		gtkutil.MenuItem("_Notification Settings", "channel.notification-settings"),
+	})
+	if popover == nil {
+		return
+	}
+
+	rect := gdk.NewRectangle(int(x), int(y), 1, 1)
+	popover.SetPointingTo(&rect)
+	gtkutil.PopupFinally(popover)
+}
+
 func (v *View) invalidateBanner() {
 	v.Child.Banner.Invalidate()
 
@@ -62,7 +62,11 @@ func (n *baseChannelNode) EachChildren(f func(Node) bool) {
 
 func (n *baseChannelNode) UpdateUnread() {
 	// Update self's unread indicator.
-	n.unread = n.head.state().ChannelIsUnread(n.id)
+	n.unread = ningen.ChannelRead
+	state := n.head.state()
+	if ch, _ := state.Cabinet.Channel(n.id); ch != nil {
+		n.unread = state.ChannelUnread(ch)
+	}
 
 	var fromChild bool
 	n.EachChildren(func(child Node) bool {
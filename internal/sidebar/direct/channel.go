@@ -11,6 +11,7 @@ import (
 	"github.com/diamondburned/gotkit/components/onlineimage"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/customstatus"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
 )
 
@@ -20,10 +21,14 @@ type Channel struct {
 	box           *gtk.Box
 	avatar        *onlineimage.Avatar
 	name          *gtk.Label
+	custom        *customstatus.Label
 	readIndicator *gtk.Label
 
 	ctx context.Context
 	id  discord.ChannelID
+	// recipient is the other user of a direct message channel. It's 0 for
+	// group channels.
+	recipient discord.UserID
 }
 
 var channelCSS = cssutil.Applier("direct-channel", `
@@ -49,6 +54,15 @@ func NewChannel(ctx context.Context, id discord.ChannelID) *Channel {
 	ch.name.SetEllipsize(pango.EllipsizeEnd)
 	ch.name.SetSingleLineMode(true)
 
+	ch.custom = customstatus.NewLabel(ctx)
+	ch.custom.AddCSSClass("direct-channel-customstatus")
+
+	nameBox := gtk.NewBox(gtk.OrientationVertical, 0)
+	nameBox.SetHExpand(true)
+	nameBox.SetVAlign(gtk.AlignCenter)
+	nameBox.Append(ch.name)
+	nameBox.Append(ch.custom)
+
 	ch.avatar = onlineimage.NewAvatar(ctx, imgutil.HTTPProvider, gtkcord.ChannelIconSize)
 	ch.avatar.AddCSSClass("direct-channel-avatar")
 
@@ -57,7 +71,7 @@ func NewChannel(ctx context.Context, id discord.ChannelID) *Channel {
 
 	ch.box = gtk.NewBox(gtk.OrientationHorizontal, 0)
 	ch.box.Append(ch.avatar)
-	ch.box.Append(ch.name)
+	ch.box.Append(nameBox)
 	ch.box.Append(ch.readIndicator)
 
 	ch.ListBoxRow = gtk.NewListBoxRow()
@@ -89,16 +103,37 @@ func (ch *Channel) Update(channel *discord.Channel) {
 
 	if channel.Type == discord.DirectMessage && len(channel.DMRecipients) > 0 {
 		u := channel.DMRecipients[0]
+		ch.recipient = u.ID
 		ch.avatar.SetInitials(name)
 		ch.avatar.SetFromURL(gtkcord.InjectAvatarSize(u.AvatarURL()))
 	} else {
+		ch.recipient = 0
 		ch.avatar.SetFromIconName("avatar-default-symbolic")
 		ch.avatar.SetFromURL(gtkcord.InjectAvatarSize(channel.IconURL()))
 	}
 
+	ch.UpdateCustomStatus()
 	ch.updateReadIndicator(channel)
 }
 
+// UpdateCustomStatus updates the custom status shown for the recipient of a
+// direct message channel.
+func (ch *Channel) UpdateCustomStatus() {
+	if !ch.recipient.IsValid() {
+		ch.custom.SetActivity(nil)
+		return
+	}
+
+	state := gtkcord.FromContext(ch.ctx)
+	ch.custom.SetActivity(state.UserCustomStatus(0, ch.recipient))
+}
+
+// Recipient returns the other user of a direct message channel, or 0 for
+// group channels.
+func (ch *Channel) Recipient() discord.UserID {
+	return ch.recipient
+}
+
 func (ch *Channel) updateReadIndicator(channel *discord.Channel) {
 	state := gtkcord.FromContext(ch.ctx)
 	unread := state.ChannelCountUnreads(channel.ID)
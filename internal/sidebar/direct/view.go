@@ -125,12 +125,25 @@ func NewChannelView(ctx context.Context, ctrl Opener) *ChannelView {
 			if ch, ok := v.channels[ev.ChannelID]; ok {
 				ch.Invalidate()
 			}
+
+		case *gateway.PresenceUpdateEvent:
+			for _, ch := range v.channels {
+				if ch.Recipient() == ev.User.ID {
+					ch.UpdateCustomStatus()
+				}
+			}
+		case *gateway.PresencesReplaceEvent:
+			for _, ch := range v.channels {
+				ch.UpdateCustomStatus()
+			}
 		}
 	},
 		(*gateway.ChannelCreateEvent)(nil),
 		(*gateway.ChannelDeleteEvent)(nil),
 		(*gateway.MessageCreateEvent)(nil),
 		(*read.UpdateEvent)(nil),
+		(*gateway.PresenceUpdateEvent)(nil),
+		(*gateway.PresencesReplaceEvent)(nil),
 	)
 
 	// TODO: search
@@ -30,15 +30,24 @@ var dmButtonCSS = cssutil.Applier("sidebar-dm-button-overlay", `
 `)
 
 func NewButton(ctx context.Context, open func()) *Button {
+	return newButton(ctx, "chat-bubbles-empty-symbolic", "Direct Messages", open)
+}
+
+// NewUnreadsButton creates a new button that opens the unreads overview.
+func NewUnreadsButton(ctx context.Context, open func()) *Button {
+	return newButton(ctx, "mail-unread-symbolic", "Unreads", open)
+}
+
+func newButton(ctx context.Context, iconName, tooltip string, open func()) *Button {
 	b := Button{ctx: ctx}
 
-	icon := gtk.NewImageFromIconName("chat-bubbles-empty-symbolic")
+	icon := gtk.NewImageFromIconName(iconName)
 	icon.SetIconSize(gtk.IconSizeLarge)
 	icon.SetPixelSize(int(math.Round(gtkcord.GuildIconSize * 0.85)))
 
 	b.Button = gtk.NewButton()
 	b.Button.AddCSSClass("sidebar-dm-button")
-	b.Button.SetTooltipText("Direct Messages")
+	b.Button.SetTooltipText(tooltip)
 	b.Button.SetChild(icon)
 	b.Button.SetHasFrame(false)
 	b.Button.ConnectClicked(func() {
@@ -19,13 +19,16 @@ import (
 type Opener interface {
 	// OpenDMs opens the DMs view.
 	OpenDMs()
+	// OpenUnreads opens the unreads overview.
+	OpenUnreads()
 	// OpenChannel opens the channel with the given ID.
 	OpenChannel(discord.ChannelID)
 }
 
 type View struct {
 	*gtk.Box
-	DM *Button
+	DM      *Button
+	Unreads *Button
 
 	mentioned struct {
 		IDs     []discord.ChannelID
@@ -42,16 +45,28 @@ var viewCSS = cssutil.Applier("dmbutton-view", `
 func NewView(ctx context.Context, opener Opener) *View {
 	v := View{
 		Box: gtk.NewBox(gtk.OrientationVertical, 0),
-		DM:  NewButton(ctx, opener.OpenDMs),
 
 		ctx:    ctx,
 		opener: opener,
 	}
 
+	v.DM = NewButton(ctx, func() {
+		v.Unreads.Pill.State = 0
+		v.Unreads.Pill.Invalidate()
+		opener.OpenDMs()
+	})
+
+	v.Unreads = NewUnreadsButton(ctx, func() {
+		v.DM.Pill.State = 0
+		v.DM.Pill.Invalidate()
+		opener.OpenUnreads()
+	})
+
 	v.mentioned.IDs = make([]discord.ChannelID, 0, 4)
 	v.mentioned.Buttons = make(map[discord.ChannelID]*ChannelButton, 4)
 
 	v.Append(v.DM)
+	v.Append(v.Unreads)
 	viewCSS(v)
 
 	vis := gtkutil.WithVisibility(ctx, v)
@@ -161,4 +176,6 @@ func (v *View) update(unreads map[discord.ChannelID]channelUnreadStatus) {
 func (v *View) Unselect() {
 	v.DM.Pill.State = 0
 	v.DM.Pill.Invalidate()
+	v.Unreads.Pill.State = 0
+	v.Unreads.Pill.Invalidate()
 }
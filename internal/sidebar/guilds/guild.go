@@ -4,8 +4,11 @@ import (
 	"context"
 
 	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/notifsettings"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/sidebar/sidebutton"
 )
 
@@ -31,6 +34,14 @@ func NewGuild(ctx context.Context, ctrl GuildController, id discord.GuildID) *Gu
 		ctrl.OpenGuild(id)
 	})
 	g.SetUnavailable()
+
+	gtkutil.BindActionMap(g, map[string]func(){
+		"guild.notification-settings": func() { notifsettings.ShowGuildDialog(ctx, id) },
+	})
+	gtkutil.BindPopoverMenuCustom(g, gtk.PosRight, []gtkutil.PopoverMenuItem{
+		gtkutil.MenuItem("_Notification Settings", "guild.notification-settings"),
+	})
+
 	guildCSS(g)
 	return &g
 }
@@ -104,14 +115,11 @@ func (g *Guild) InvalidateUnread() {
 	var mentions int
 
 	chs, _ := state.Cabinet.Channels(g.id)
-	for _, ch := range chs {
-		read := state.ReadState.ReadState(ch.ID)
-		if read != nil {
-			mentions += read.MentionCount
-		}
+	for i := range chs {
+		mentions += state.ChannelMentionCount(&chs[i])
 	}
 
-	g.SetIndicator(state.GuildIsUnread(g.id, gtkcord.AllowedChannelTypes))
+	g.SetIndicator(state.GuildUnread(g.id, gtkcord.AllowedChannelTypes))
 	g.Mentions.SetCount(mentions)
 
 	if g.parent != nil {
@@ -91,6 +91,10 @@ func NewView(ctx context.Context, ctrl Controller) *View {
 					guild.InvalidateUnread()
 				}
 			}
+		case *gateway.UserGuildSettingsUpdateEvent:
+			if guild := v.Guild(ev.GuildID); guild != nil {
+				guild.InvalidateUnread()
+			}
 		case *gateway.GuildCreateEvent:
 			if guild := v.Guild(ev.ID); guild != nil {
 				guild.Update(&ev.Guild)
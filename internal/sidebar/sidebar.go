@@ -14,6 +14,7 @@ import (
 	"github.com/thekrafter/gtkcord4-spacebar/internal/sidebar/direct"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/sidebar/directbutton"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/sidebar/guilds"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/sidebar/unreads"
 )
 
 // Controller is the parent controller that Sidebar controls.
@@ -110,6 +111,7 @@ func NewSidebar(ctx context.Context, ctrl Controller, opener Opener) *Sidebar {
 
 	userBar := newUserBar(ctx, []gtkutil.PopoverMenuItem{
 		gtkutil.MenuItem("Quick Switcher", "discord.show-qs"),
+		gtkutil.MenuItem("Inbox", "discord.show-inbox"),
 		gtkutil.MenuSeparator("User Settings"),
 		gtkutil.Submenu("Set _Status", []gtkutil.PopoverMenuItem{
 			gtkutil.MenuItem("_Online", "discord.set-online"),
@@ -117,6 +119,7 @@ func NewSidebar(ctx context.Context, ctrl Controller, opener Opener) *Sidebar {
 			gtkutil.MenuItem("_Do Not Disturb", "discord.set-dnd"),
 			gtkutil.MenuItem("In_visible", "discord.set-invisible"),
 		}),
+		gtkutil.MenuItem("Set _Custom Status…", "discord.set-custom-status"),
 		gtkutil.MenuSeparator(""),
 		gtkutil.MenuItem("_Preferences", "app.preferences"),
 		gtkutil.MenuItem("_About", "app.about"),
@@ -192,6 +195,29 @@ func (s *Sidebar) OpenDMs() *direct.ChannelView {
 	return direct
 }
 
+// OpenUnreads opens the overview of unread channels across all guilds.
+func (s *Sidebar) OpenUnreads() *unreads.View {
+	if unreads, ok := s.current.w.(*unreads.View); ok {
+		// we're already there
+		return unreads
+	}
+
+	s.ctrl.CloseGuild(true)
+	s.Guilds.Unselect()
+
+	unreads := unreads.NewView(s.ctx, s.opener)
+	unreads.SetVExpand(true)
+	unreads.Invalidate()
+
+	s.Right.AddChild(unreads)
+	s.Right.SetVisibleChild(unreads)
+
+	s.removeCurrent()
+	s.current.w = unreads
+
+	return unreads
+}
+
 func (s *Sidebar) openGuild(guildID discord.GuildID) *channels.View {
 	s.DMView.Unselect()
 
@@ -0,0 +1,472 @@
+// Package unreads contains the overview page listing unread channels across
+// all guilds.
+package unreads
+
+import (
+	"context"
+	"log"
+
+	"github.com/diamondburned/adaptive"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/components/onlineimage"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/diamondburned/ningen/v3"
+	"github.com/diamondburned/ningen/v3/states/read"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/sidebar/sidebutton"
+)
+
+// Opener is the parent controller that View controls.
+type Opener interface {
+	OpenChannel(discord.ChannelID)
+}
+
+// View displays every unread channel grouped by guild.
+type View struct {
+	*adaptive.LoadablePage
+	box    *gtk.Box // direct child
+	scroll *gtk.ScrolledWindow
+	groups *gtk.Box
+
+	ctx         context.Context
+	opener      Opener
+	invalidated bool
+
+	rows     map[discord.ChannelID]*channelRow
+	previews map[discord.ChannelID]messagePreview // only of shown channels
+	fetching map[discord.MessageID]bool
+}
+
+// messagePreview is the preview of the last message of a channel.
+type messagePreview struct {
+	msgID discord.MessageID
+	text  string
+}
+
+// channelRow is the row of an unread channel.
+type channelRow struct {
+	*gtk.ListBoxRow
+	mentions *sidebutton.MentionsIndicator
+	preview  *gtk.Label
+	msgID    discord.MessageID
+}
+
+var viewCSS = cssutil.Applier("unreads-view", `
+	.unreads-header {
+		padding: 0 12px;
+		min-height: 46px;
+	}
+	.unreads-header-label {
+		font-weight: bold;
+	}
+	.unreads-group {
+		margin-bottom: 8px;
+	}
+	.unreads-group-header {
+		padding: 4px 6px;
+	}
+	.unreads-group-icon {
+		margin-right: 6px;
+	}
+	.unreads-group-name {
+		font-weight: bold;
+	}
+	.unreads-list {
+		background: none;
+	}
+	.unreads-channel {
+		padding: 4px 6px;
+		padding-left: 12px;
+	}
+	.unreads-channel-name {
+		font-weight: bold;
+	}
+	.unreads-channel-preview {
+		font-size: 0.9em;
+		color: alpha(@theme_fg_color, 0.75);
+	}
+`)
+
+// NewView creates a new unreads view.
+func NewView(ctx context.Context, opener Opener) *View {
+	v := View{
+		ctx:      ctx,
+		opener:   opener,
+		rows:     make(map[discord.ChannelID]*channelRow),
+		previews: make(map[discord.ChannelID]messagePreview),
+		fetching: make(map[discord.MessageID]bool),
+	}
+
+	title := gtk.NewLabel("Unreads")
+	title.AddCSSClass("unreads-header-label")
+	title.SetXAlign(0)
+	title.SetHExpand(true)
+
+	header := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	header.AddCSSClass("unreads-header")
+	header.Append(title)
+
+	headerHandle := gtk.NewWindowHandle()
+	headerHandle.AddCSSClass("titlebar")
+	headerHandle.SetChild(header)
+
+	v.groups = gtk.NewBox(gtk.OrientationVertical, 0)
+
+	v.scroll = gtk.NewScrolledWindow()
+	v.scroll.SetVExpand(true)
+	v.scroll.SetHExpand(true)
+	v.scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	v.scroll.SetChild(v.groups)
+
+	v.box = gtk.NewBox(gtk.OrientationVertical, 0)
+	v.box.Append(headerHandle)
+	v.box.Append(v.scroll)
+
+	v.LoadablePage = adaptive.NewLoadablePage()
+	v.LoadablePage.SetLoading()
+
+	vis := gtkutil.WithVisibility(ctx, v)
+
+	state := gtkcord.FromContext(ctx)
+	state.BindHandler(vis, func(ev gateway.Event) {
+		switch ev := ev.(type) {
+		case *gateway.MessageCreateEvent:
+			if _, ok := v.rows[ev.ChannelID]; ok {
+				v.previews[ev.ChannelID] = messagePreview{
+					msgID: ev.ID,
+					text:  state.MessagePreview(&ev.Message),
+				}
+			}
+			v.updateChannel(ev.ChannelID)
+		case *gateway.MessageDeleteEvent:
+			if p, ok := v.previews[ev.ChannelID]; ok && p.msgID == ev.ID {
+				delete(v.previews, ev.ChannelID)
+			}
+			v.updateChannel(ev.ChannelID)
+		case *read.UpdateEvent:
+			v.updateChannel(ev.ChannelID)
+		default:
+			v.queueInvalidate()
+		}
+	},
+		(*read.UpdateEvent)(nil),
+		(*gateway.MessageCreateEvent)(nil),
+		(*gateway.MessageDeleteEvent)(nil),
+		(*gateway.GuildCreateEvent)(nil),
+		(*gateway.GuildDeleteEvent)(nil),
+		(*gateway.UserGuildSettingsUpdateEvent)(nil),
+	)
+
+	viewCSS(v)
+	return &v
+}
+
+// queueInvalidate invalidates the view once the main loop is idle. Busy guilds
+// can send many events at once, so this avoids rebuilding the list for each.
+func (v *View) queueInvalidate() {
+	if v.invalidated {
+		return
+	}
+
+	v.invalidated = true
+	glib.IdleAdd(func() {
+		v.invalidated = false
+		v.Invalidate()
+	})
+}
+
+// updateChannel updates the row of the given channel in place. The list is
+// only rebuilt if the channel has to be added or removed.
+func (v *View) updateChannel(chID discord.ChannelID) {
+	state := gtkcord.FromContext(v.ctx)
+
+	ch, err := state.Cabinet.Channel(chID)
+	if err != nil {
+		return
+	}
+
+	unread := len(unreadChannels(state, []discord.Channel{*ch})) > 0
+
+	row, ok := v.rows[chID]
+	if !ok {
+		if unread {
+			v.queueInvalidate()
+		}
+		return
+	}
+
+	if !unread {
+		v.queueInvalidate()
+		return
+	}
+
+	v.updateRow(chID, row)
+}
+
+// Invalidate rebuilds the list of unread channels.
+func (v *View) Invalidate() {
+	state := gtkcord.FromContext(v.ctx)
+
+	guilds, err := state.Cabinet.Guilds()
+	if err != nil {
+		v.SetError(err)
+		return
+	}
+
+	gtkutil.RemoveChildren(v.groups)
+	v.rows = make(map[discord.ChannelID]*channelRow)
+
+	var n int
+
+	if dms, err := state.PrivateChannels(); err == nil {
+		if chs := unreadChannels(state, dms); len(chs) > 0 {
+			v.groups.Append(v.newGroup(nil, chs))
+			n += len(chs)
+		}
+	} else {
+		log.Println("unreads: cannot get private channels:", err)
+	}
+
+	for i := range guilds {
+		chs, err := state.Channels(guilds[i].ID, gtkcord.AllowedChannelTypes)
+		if err != nil {
+			log.Println("unreads: cannot get channels for guild", guilds[i].ID, ":", err)
+			continue
+		}
+
+		chs = unreadChannels(state, chs)
+		if len(chs) == 0 {
+			continue
+		}
+
+		v.groups.Append(v.newGroup(&guilds[i], chs))
+		n += len(chs)
+	}
+
+	if n == 0 {
+		status := adaptive.NewStatusPage()
+		status.SetIconName("checkbox-checked-symbolic")
+		status.SetTitle("All Caught Up")
+		status.SetDescriptionText("There are no unread messages.")
+		status.Icon.SetOpacity(0.45)
+		status.SetVExpand(true)
+		v.groups.Append(status)
+	}
+
+	// Forget the previews of channels that are no longer shown.
+	for chID := range v.previews {
+		if _, ok := v.rows[chID]; !ok {
+			delete(v.previews, chID)
+		}
+	}
+
+	v.SetChild(v.box)
+}
+
+// unreadChannels filters the given channels down to only those with unread
+// messages. Channels inside muted guilds are only included if they mention the
+// user.
+func unreadChannels(state *gtkcord.State, chs []discord.Channel) []discord.Channel {
+	unread := chs[:0:0]
+	for i, ch := range chs {
+		if ch.Type == discord.GuildCategory {
+			continue
+		}
+
+		ind := state.ChannelUnread(&chs[i])
+		if ind == ningen.ChannelRead {
+			continue
+		}
+
+		if ch.GuildID.IsValid() && ind != ningen.ChannelMentioned &&
+			state.MutedState.Guild(ch.GuildID, false) {
+			continue
+		}
+
+		unread = append(unread, ch)
+	}
+	return unread
+}
+
+// newGroup creates a new group of unread channels. If guild is nil, then the
+// group is for direct messages.
+func (v *View) newGroup(guild *discord.Guild, chs []discord.Channel) *gtk.Box {
+	icon := onlineimage.NewAvatar(v.ctx, imgutil.HTTPProvider, gtkcord.InlineEmojiSize)
+	icon.AddCSSClass("unreads-group-icon")
+
+	name := gtk.NewLabel("")
+	name.AddCSSClass("unreads-group-name")
+	name.SetXAlign(0)
+	name.SetHExpand(true)
+	name.SetEllipsize(pango.EllipsizeEnd)
+
+	if guild != nil {
+		name.SetText(guild.Name)
+		icon.SetInitials(guild.Name)
+		icon.SetFromURL(gtkcord.InjectAvatarSize(guild.IconURL()))
+	} else {
+		name.SetText("Direct Messages")
+		icon.SetFromIconName("chat-bubbles-empty-symbolic")
+	}
+
+	markRead := gtk.NewButtonFromIconName("mail-read-symbolic")
+	markRead.AddCSSClass("flat")
+	markRead.SetTooltipText("Mark as Read")
+	markRead.ConnectClicked(func() { v.markRead(chs) })
+
+	header := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	header.AddCSSClass("unreads-group-header")
+	header.Append(icon)
+	header.Append(name)
+	header.Append(markRead)
+
+	list := gtk.NewListBox()
+	list.AddCSSClass("unreads-list")
+	list.SetSelectionMode(gtk.SelectionNone)
+	list.SetActivateOnSingleClick(true)
+	list.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		if i := row.Index(); i >= 0 && i < len(chs) {
+			v.opener.OpenChannel(chs[i].ID)
+		}
+	})
+
+	for i := range chs {
+		list.Append(v.newChannelRow(&chs[i]))
+	}
+
+	box := gtk.NewBox(gtk.OrientationVertical, 0)
+	box.AddCSSClass("unreads-group")
+	box.Append(header)
+	box.Append(list)
+
+	return box
+}
+
+func (v *View) newChannelRow(ch *discord.Channel) *channelRow {
+	name := gtk.NewLabel(gtkcord.ChannelName(ch))
+	name.AddCSSClass("unreads-channel-name")
+	name.SetXAlign(0)
+	name.SetHExpand(true)
+	name.SetEllipsize(pango.EllipsizeEnd)
+
+	mentions := sidebutton.NewMentionsIndicator()
+	mentions.SetVAlign(gtk.AlignCenter)
+
+	top := gtk.NewBox(gtk.OrientationHorizontal, 4)
+	top.Append(name)
+	top.Append(mentions)
+
+	markRead := gtk.NewButtonFromIconName("object-select-symbolic")
+	markRead.AddCSSClass("flat")
+	markRead.SetTooltipText("Mark as Read")
+	markRead.ConnectClicked(func() { v.markRead([]discord.Channel{*ch}) })
+	top.Append(markRead)
+
+	preview := gtk.NewLabel("")
+	preview.AddCSSClass("unreads-channel-preview")
+	preview.SetXAlign(0)
+	preview.SetEllipsize(pango.EllipsizeEnd)
+	preview.SetSingleLineMode(true)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 0)
+	box.AddCSSClass("unreads-channel")
+	box.Append(top)
+	box.Append(preview)
+
+	row := &channelRow{
+		ListBoxRow: gtk.NewListBoxRow(),
+		mentions:   mentions,
+		preview:    preview,
+	}
+	row.SetChild(box)
+
+	v.rows[ch.ID] = row
+	v.updateRow(ch.ID, row)
+
+	return row
+}
+
+// updateRow updates the mention count and the last message preview of the
+// row.
+func (v *View) updateRow(chID discord.ChannelID, row *channelRow) {
+	state := gtkcord.FromContext(v.ctx)
+
+	var mentions int
+	if ch, _ := state.Cabinet.Channel(chID); ch != nil {
+		mentions = state.ChannelMentionCount(ch)
+	}
+	row.mentions.SetCount(mentions)
+
+	row.msgID = state.LastMessage(chID)
+	if !row.msgID.IsValid() {
+		row.setPreview("")
+		return
+	}
+
+	if p, ok := v.previews[chID]; ok && p.msgID == row.msgID {
+		row.setPreview(p.text)
+		return
+	}
+
+	if msg, err := state.Cabinet.Message(chID, row.msgID); err == nil {
+		v.setPreview(chID, row, state.MessagePreview(msg))
+		return
+	}
+
+	row.setPreview("")
+	v.fetchPreview(chID, row.msgID)
+}
+
+// fetchPreview fetches the preview of a message that isn't in the cabinet,
+// which is usually the case for channels that weren't opened yet.
+func (v *View) fetchPreview(chID discord.ChannelID, msgID discord.MessageID) {
+	if v.fetching[msgID] {
+		return
+	}
+	v.fetching[msgID] = true
+
+	state := gtkcord.FromContext(v.ctx)
+	gtkutil.Async(v.ctx, func() func() {
+		msg, err := state.Message(chID, msgID)
+		return func() {
+			delete(v.fetching, msgID)
+			if err != nil {
+				log.Println("unreads: cannot fetch last message:", err)
+				return
+			}
+
+			// The list may have been rebuilt in the meantime, so look up the
+			// current row.
+			if row, ok := v.rows[chID]; ok && row.msgID == msgID {
+				v.setPreview(chID, row, state.MessagePreview(msg))
+			}
+		}
+	})
+}
+
+// setPreview shows the preview of the row's last message and remembers it
+// until the channel leaves the list.
+func (v *View) setPreview(chID discord.ChannelID, row *channelRow, text string) {
+	v.previews[chID] = messagePreview{msgID: row.msgID, text: text}
+	row.setPreview(text)
+}
+
+func (r *channelRow) setPreview(preview string) {
+	r.preview.SetText(preview)
+	r.preview.SetTooltipText(preview)
+}
+
+func (v *View) markRead(chs []discord.Channel) {
+	state := gtkcord.FromContext(v.ctx)
+	for _, ch := range chs {
+		if msgID := state.LastMessage(ch.ID); msgID.IsValid() {
+			state.ReadState.MarkRead(ch.ID, msgID)
+		}
+	}
+}
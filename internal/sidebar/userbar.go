@@ -13,7 +13,9 @@ import (
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/customstatus"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/profile"
 )
 
 type userBar struct {
@@ -21,6 +23,7 @@ type userBar struct {
 	*gtk.Box
 	avatar *onlineimage.Avatar
 	name   *gtk.Label
+	custom *customstatus.Label
 	status *gtk.Image
 	menu   *gtk.ToggleButton
 
@@ -52,6 +55,15 @@ func newUserBar(ctx context.Context, menuActions []gtkutil.PopoverMenuItem) *use
 	b.name.SetWrap(false)
 	b.name.SetEllipsize(pango.EllipsizeEnd)
 
+	b.custom = customstatus.NewLabel(ctx)
+	b.custom.AddCSSClass("user-bar-customstatus")
+
+	nameBox := gtk.NewBox(gtk.OrientationVertical, 0)
+	nameBox.SetHExpand(true)
+	nameBox.SetVAlign(gtk.AlignCenter)
+	nameBox.Append(b.name)
+	nameBox.Append(b.custom)
+
 	b.status = gtk.NewImage()
 	b.status.AddCSSClass("user-bar-status")
 	b.updatePresence(nil)
@@ -70,7 +82,7 @@ func newUserBar(ctx context.Context, menuActions []gtkutil.PopoverMenuItem) *use
 
 	b.Box = gtk.NewBox(gtk.OrientationHorizontal, 0)
 	b.Box.Append(b.avatar)
-	b.Box.Append(b.name)
+	b.Box.Append(nameBox)
 	b.Box.Append(b.status)
 	b.Box.Append(b.menu)
 	userBarCSS(b)
@@ -78,6 +90,11 @@ func newUserBar(ctx context.Context, menuActions []gtkutil.PopoverMenuItem) *use
 	anim := b.avatar.EnableAnimation()
 	anim.ConnectMotion(b)
 
+	profile.Bind(ctx, b.avatar, func() (discord.GuildID, *discord.User) {
+		me, _ := gtkcord.FromContext(ctx).Me()
+		return 0, me
+	})
+
 	vis := gtkutil.WithVisibility(ctx, b)
 
 	client := gtkcord.FromContext(ctx)
@@ -140,8 +157,9 @@ func (b *userBar) updateUser(me *discord.User) {
 
 func (b *userBar) updatePresence(presence *discord.Presence) {
 	if presence == nil {
-		b.status.SetTooltipText(statusText(discord.UnknownStatus))
-		b.status.SetFromIconName(statusIcon(discord.UnknownStatus))
+		b.status.SetTooltipText(gtkcord.StatusText(discord.UnknownStatus))
+		b.status.SetFromIconName(gtkcord.StatusIcon(discord.UnknownStatus))
+		b.custom.SetActivity(nil)
 		return
 	}
 
@@ -149,8 +167,11 @@ func (b *userBar) updatePresence(presence *discord.Presence) {
 		b.updateUser(&presence.User)
 	}
 
-	b.status.SetTooltipText(statusText(presence.Status))
-	b.status.SetFromIconName(statusIcon(presence.Status))
+	b.status.SetTooltipText(gtkcord.StatusText(presence.Status))
+	b.status.SetFromIconName(gtkcord.StatusIcon(presence.Status))
+
+	state := gtkcord.FromContext(b.ctx)
+	b.custom.SetActivity(state.CustomStatus())
 }
 
 func (b *userBar) invalidatePresence() {
@@ -162,41 +183,3 @@ func (b *userBar) invalidatePresence() {
 		b.updatePresence(presence)
 	}
 }
-
-func statusIcon(status discord.Status) string {
-	switch status {
-	case discord.OnlineStatus:
-		return "user-available"
-	case discord.DoNotDisturbStatus:
-		return "user-busy"
-	case discord.IdleStatus:
-		return "user-idle"
-	case discord.InvisibleStatus:
-		return "user-invisible"
-	case discord.OfflineStatus:
-		return "user-offline"
-	case discord.UnknownStatus:
-		fallthrough
-	default:
-		return "user-status-pending"
-	}
-}
-
-func statusText(status discord.Status) string {
-	switch status {
-	case discord.OnlineStatus:
-		return "Online"
-	case discord.DoNotDisturbStatus:
-		return "Busy"
-	case discord.IdleStatus:
-		return "Idle"
-	case discord.InvisibleStatus:
-		return "Invisible"
-	case discord.OfflineStatus:
-		return "Offline"
-	case discord.UnknownStatus:
-		fallthrough
-	default:
-		return "Unknown"
-	}
-}
@@ -0,0 +1,304 @@
+package sticker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// Limits for decoding APNGs, which come from remote servers. Stickers are
+// usually 320x320 with less than a hundred frames.
+const (
+	// maxAPNGSize is the maximum width and height of an APNG.
+	maxAPNGSize = 2048
+	// maxAPNGFrames is the maximum number of frames decoded from an APNG.
+	// Frames past it are dropped.
+	maxAPNGFrames = 300
+	// maxAPNGPixels is the maximum number of pixels of all decoded frames
+	// together. Frames past it are dropped.
+	maxAPNGPixels = 32 << 20
+)
+
+// APNG dispose and blend operations.
+const (
+	apngDisposeNone       = 0
+	apngDisposeBackground = 1
+	apngDisposePrevious   = 2
+
+	apngBlendSource = 0
+	apngBlendOver   = 1
+)
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+type apngFrame struct {
+	width, height int
+	x, y          int
+	delay         time.Duration
+	dispose       byte
+	blend         byte
+	data          [][]byte // IDAT or fdAT data without the sequence number
+}
+
+// DecodeAPNG decodes an animated PNG into its fully composited frames. A PNG
+// that isn't animated is decoded into a single frame.
+func DecodeAPNG(r io.Reader) (*Animation, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(b, pngSignature) {
+		return nil, errors.New("not a PNG file")
+	}
+
+	chunks, err := readPNGChunks(b[len(pngSignature):])
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ihdr     []byte
+		header   []pngChunk // chunks copied into every frame, such as PLTE
+		frames   []*apngFrame
+		current  *apngFrame
+		animated bool
+		seenIDAT bool
+	)
+
+	for _, chunk := range chunks {
+		switch chunk.typ {
+		case "IHDR":
+			ihdr = chunk.data
+		case "acTL":
+			animated = true
+		case "fcTL":
+			if len(chunk.data) < 26 {
+				return nil, errors.New("invalid fcTL chunk")
+			}
+			current = &apngFrame{
+				width:   int(binary.BigEndian.Uint32(chunk.data[4:])),
+				height:  int(binary.BigEndian.Uint32(chunk.data[8:])),
+				x:       int(binary.BigEndian.Uint32(chunk.data[12:])),
+				y:       int(binary.BigEndian.Uint32(chunk.data[16:])),
+				delay:   apngDelay(chunk.data[20:24]),
+				dispose: chunk.data[24],
+				blend:   chunk.data[25],
+			}
+			frames = append(frames, current)
+		case "IDAT":
+			seenIDAT = true
+			// The default image is only part of the animation if it has a
+			// frame control chunk before it.
+			if current != nil {
+				current.data = append(current.data, chunk.data)
+			}
+		case "fdAT":
+			if current == nil || len(chunk.data) < 4 {
+				return nil, errors.New("invalid fdAT chunk")
+			}
+			current.data = append(current.data, chunk.data[4:])
+		case "IEND":
+		default:
+			if !seenIDAT {
+				header = append(header, chunk)
+			}
+		}
+	}
+
+	if ihdr == nil || len(ihdr) != 13 {
+		return nil, errors.New("missing IHDR chunk")
+	}
+
+	// Check the size before anything is allocated for it.
+	cfg, err := png.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxAPNGSize || cfg.Height > maxAPNGSize {
+		return nil, errors.Errorf("APNG size %dx%d is too large", cfg.Width, cfg.Height)
+	}
+
+	width := cfg.Width
+	height := cfg.Height
+
+	if !animated || len(frames) == 0 {
+		img, err := png.Decode(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return &Animation{
+			Width:  width,
+			Height: height,
+			Frames: []Frame{{Image: toRGBA(img)}},
+		}, nil
+	}
+
+	if len(frames) > maxAPNGFrames {
+		frames = frames[:maxAPNGFrames]
+	}
+
+	anim := Animation{
+		Width:  width,
+		Height: height,
+		Frames: make([]Frame, 0, len(frames)),
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	pixels := width * height
+
+	for i, frame := range frames {
+		if len(frame.data) == 0 {
+			continue
+		}
+
+		if frame.width <= 0 || frame.height <= 0 ||
+			frame.x < 0 || frame.y < 0 ||
+			frame.x+frame.width > width || frame.y+frame.height > height {
+			return nil, errors.Errorf("frame %d is out of bounds", i)
+		}
+
+		// Every frame is a full snapshot of the canvas.
+		pixels += width * height
+		if pixels > maxAPNGPixels {
+			break
+		}
+
+		img, err := decodeAPNGFrame(ihdr, header, frame)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot decode frame %d", i)
+		}
+
+		bounds := image.Rect(frame.x, frame.y, frame.x+frame.width, frame.y+frame.height)
+
+		var previous *image.RGBA
+		if frame.dispose == apngDisposePrevious && i > 0 {
+			previous = image.NewRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, bounds.Min, draw.Src)
+		}
+
+		op := draw.Over
+		if frame.blend == apngBlendSource {
+			op = draw.Src
+		}
+		draw.Draw(canvas, bounds, img, image.Point{}, op)
+
+		snapshot := image.NewRGBA(canvas.Rect)
+		copy(snapshot.Pix, canvas.Pix)
+		anim.Frames = append(anim.Frames, Frame{
+			Image: snapshot,
+			Delay: frame.delay,
+		})
+
+		switch {
+		case previous != nil:
+			draw.Draw(canvas, bounds, previous, bounds.Min, draw.Src)
+		case frame.dispose == apngDisposeBackground, frame.dispose == apngDisposePrevious:
+			draw.Draw(canvas, bounds, image.Transparent, image.Point{}, draw.Src)
+		}
+	}
+
+	if len(anim.Frames) == 0 {
+		return nil, errors.New("APNG has no frames")
+	}
+
+	return &anim, nil
+}
+
+func readPNGChunks(b []byte) ([]pngChunk, error) {
+	var chunks []pngChunk
+	for len(b) > 0 {
+		if len(b) < 12 {
+			return nil, errors.New("truncated PNG chunk")
+		}
+
+		n := int(binary.BigEndian.Uint32(b))
+		if n < 0 || len(b) < 12+n {
+			return nil, errors.New("truncated PNG chunk")
+		}
+
+		chunks = append(chunks, pngChunk{
+			typ:  string(b[4:8]),
+			data: b[8 : 8+n],
+		})
+
+		b = b[12+n:]
+	}
+	return chunks, nil
+}
+
+// decodeAPNGFrame decodes a single frame by wrapping its data in a new PNG
+// file.
+func decodeAPNGFrame(ihdr []byte, header []pngChunk, frame *apngFrame) (image.Image, error) {
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+
+	frameIHDR := append([]byte(nil), ihdr...)
+	binary.BigEndian.PutUint32(frameIHDR[0:], uint32(frame.width))
+	binary.BigEndian.PutUint32(frameIHDR[4:], uint32(frame.height))
+	writePNGChunk(&buf, "IHDR", frameIHDR)
+
+	for _, chunk := range header {
+		writePNGChunk(&buf, chunk.typ, chunk.data)
+	}
+	for _, data := range frame.data {
+		writePNGChunk(&buf, "IDAT", data)
+	}
+	writePNGChunk(&buf, "IEND", nil)
+
+	return png.Decode(&buf)
+}
+
+func writePNGChunk(buf *bytes.Buffer, typ string, data []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	buf.Write(n[:])
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+
+	buf.WriteString(typ)
+	buf.Write(data)
+	binary.BigEndian.PutUint32(n[:], crc.Sum32())
+	buf.Write(n[:])
+}
+
+func apngDelay(b []byte) time.Duration {
+	num := binary.BigEndian.Uint16(b[0:])
+	den := binary.BigEndian.Uint16(b[2:])
+	if den == 0 {
+		den = 100
+	}
+
+	delay := time.Duration(num) * time.Second / time.Duration(den)
+	// Browsers treat very short delays as the default delay, and so do we.
+	if delay <= 10*time.Millisecond {
+		delay = 100 * time.Millisecond
+	}
+
+	return delay
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, bounds.Min, draw.Src)
+	return rgba
+}
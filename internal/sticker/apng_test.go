@@ -0,0 +1,195 @@
+package sticker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+type testFrame struct {
+	x, y  int
+	color color.RGBA
+	size  int // square
+}
+
+// pngChunks encodes the image as a PNG and returns its chunks.
+func pngChunks(t *testing.T, img image.Image) []pngChunk {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := readPNGChunks(buf.Bytes()[len(pngSignature):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chunks
+}
+
+func solidImage(size int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i+0] = c.R
+		img.Pix[i+1] = c.G
+		img.Pix[i+2] = c.B
+		img.Pix[i+3] = c.A
+	}
+	return img
+}
+
+// buildAPNG builds an APNG with a width x height canvas. The first frame is
+// the default image.
+func buildAPNG(t *testing.T, width, height int, frames []testFrame) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+
+	// All frames are opaque, so they're encoded with the same color type as
+	// this image.
+	ihdr := pngChunks(t, solidImage(1, red))[0].data
+	ihdr = append([]byte(nil), ihdr...)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(height))
+	writePNGChunk(&buf, "IHDR", ihdr)
+
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(frames)))
+	writePNGChunk(&buf, "acTL", actl)
+
+	seq := uint32(0)
+	for i, frame := range frames {
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(frame.size))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(frame.size))
+		binary.BigEndian.PutUint32(fctl[12:], uint32(frame.x))
+		binary.BigEndian.PutUint32(fctl[16:], uint32(frame.y))
+		binary.BigEndian.PutUint16(fctl[20:], 1)
+		binary.BigEndian.PutUint16(fctl[22:], 10)
+		fctl[25] = apngBlendOver
+		writePNGChunk(&buf, "fcTL", fctl)
+		seq++
+
+		for _, chunk := range pngChunks(t, solidImage(frame.size, frame.color)) {
+			if chunk.typ != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writePNGChunk(&buf, "IDAT", chunk.data)
+				continue
+			}
+			fdat := make([]byte, 4, 4+len(chunk.data))
+			binary.BigEndian.PutUint32(fdat, seq)
+			writePNGChunk(&buf, "fdAT", append(fdat, chunk.data...))
+			seq++
+		}
+	}
+
+	writePNGChunk(&buf, "IEND", nil)
+	return buf.Bytes()
+}
+
+var (
+	red  = color.RGBA{255, 0, 0, 255}
+	blue = color.RGBA{0, 0, 255, 255}
+)
+
+func TestDecodeAPNG(t *testing.T) {
+	b := buildAPNG(t, 4, 4, []testFrame{
+		{size: 4, color: red},
+		{x: 2, y: 2, size: 2, color: blue},
+	})
+
+	anim, err := DecodeAPNG(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if anim.Width != 4 || anim.Height != 4 {
+		t.Fatalf("unexpected size %dx%d", anim.Width, anim.Height)
+	}
+	if len(anim.Frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(anim.Frames))
+	}
+
+	second := anim.Frames[1].Image
+	if c := second.RGBAAt(0, 0); c != red {
+		t.Errorf("previous frame not kept, got %v", c)
+	}
+	if c := second.RGBAAt(3, 3); c != blue {
+		t.Errorf("frame not drawn at its offset, got %v", c)
+	}
+}
+
+func TestDecodeAPNGStill(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, solidImage(3, red)); err != nil {
+		t.Fatal(err)
+	}
+
+	anim, err := DecodeAPNG(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Frames) != 1 {
+		t.Fatalf("expected 1 frame, got %d", len(anim.Frames))
+	}
+}
+
+func TestDecodeAPNGTooLarge(t *testing.T) {
+	b := buildAPNG(t, 1<<20, 1<<20, []testFrame{
+		{size: 1, color: red},
+	})
+
+	if _, err := DecodeAPNG(bytes.NewReader(b)); err == nil {
+		t.Fatal("oversized APNG was not rejected")
+	}
+}
+
+func TestDecodeAPNGFrameOutOfBounds(t *testing.T) {
+	b := buildAPNG(t, 4, 4, []testFrame{
+		{size: 4, color: red},
+		{x: 3, y: 3, size: 2, color: blue},
+	})
+
+	if _, err := DecodeAPNG(bytes.NewReader(b)); err == nil {
+		t.Fatal("out of bounds frame was not rejected")
+	}
+}
+
+func TestDecodeAPNGFrameLimit(t *testing.T) {
+	frames := make([]testFrame, maxAPNGFrames+10)
+	for i := range frames {
+		frames[i] = testFrame{size: 1, color: red}
+	}
+
+	anim, err := DecodeAPNG(bytes.NewReader(buildAPNG(t, 1, 1, frames)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Frames) != maxAPNGFrames {
+		t.Fatalf("expected %d frames, got %d", maxAPNGFrames, len(anim.Frames))
+	}
+}
+
+func TestDecodeAPNGPixelLimit(t *testing.T) {
+	const size = 1024
+	frames := make([]testFrame, 64)
+	for i := range frames {
+		frames[i] = testFrame{size: size, color: red}
+	}
+
+	anim, err := DecodeAPNG(bytes.NewReader(buildAPNG(t, size, size, frames)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pixels := len(anim.Frames) * size * size; pixels > maxAPNGPixels {
+		t.Fatalf("decoded %d pixels, more than the limit", pixels)
+	}
+}
@@ -0,0 +1,740 @@
+package sticker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Lottie is a parsed Lottie animation. Only the shape and transform features
+// commonly used by stickers are supported: shape layers, solids, precomps,
+// parenting, masks, track mattes, paths, rectangles, ellipses, stars, fills,
+// strokes, gradients and trim paths.
+type Lottie struct {
+	FrameRate float64  `json:"fr"`
+	InPoint   float64  `json:"ip"`
+	OutPoint  float64  `json:"op"`
+	Width     float64  `json:"w"`
+	Height    float64  `json:"h"`
+	Layers    []*layer `json:"layers"`
+	Assets    []struct {
+		ID     string   `json:"id"`
+		Layers []*layer `json:"layers"`
+	} `json:"assets"`
+
+	precomps map[string][]*layer
+}
+
+// DecodeLottie parses a Lottie JSON animation.
+func DecodeLottie(r io.Reader) (*Lottie, error) {
+	var l Lottie
+	if err := json.NewDecoder(r).Decode(&l); err != nil {
+		return nil, errors.Wrap(err, "invalid Lottie JSON")
+	}
+
+	if l.Width <= 0 || l.Height <= 0 || l.FrameRate <= 0 {
+		return nil, errors.New("invalid Lottie dimensions")
+	}
+
+	l.precomps = make(map[string][]*layer, len(l.Assets))
+	for _, asset := range l.Assets {
+		if asset.Layers != nil {
+			l.precomps[asset.ID] = asset.Layers
+		}
+	}
+
+	if err := l.checkPrecomps(); err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// checkPrecomps returns an error if a precomp includes itself, directly or
+// through other precomps, which would never finish rendering.
+func (l *Lottie) checkPrecomps() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	states := make(map[string]int, len(l.precomps))
+
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch states[id] {
+		case visiting:
+			return errors.Errorf("Lottie precomp %q includes itself", id)
+		case visited:
+			return nil
+		}
+
+		states[id] = visiting
+		for _, layer := range l.precomps[id] {
+			if layer.Type != layerPrecomp {
+				continue
+			}
+			if _, ok := l.precomps[layer.RefID]; !ok {
+				continue
+			}
+			if err := visit(layer.RefID); err != nil {
+				return err
+			}
+		}
+		states[id] = visited
+
+		return nil
+	}
+
+	for id := range l.precomps {
+		if err := visit(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Layer types.
+const (
+	layerPrecomp = 0
+	layerSolid   = 1
+	layerNull    = 3
+	layerShape   = 4
+)
+
+type layer struct {
+	Type      int       `json:"ty"`
+	Index     *int      `json:"ind"`
+	Parent    *int      `json:"parent"`
+	InPoint   float64   `json:"ip"`
+	OutPoint  float64   `json:"op"`
+	StartTime float64   `json:"st"`
+	Stretch   float64   `json:"sr"`
+	Hidden    bool      `json:"hd"`
+	Transform transform `json:"ks"`
+	Shapes    []*shape  `json:"shapes"`
+	Masks     []mask    `json:"masksProperties"`
+	MatteMode int       `json:"tt"`
+	IsMatte   int       `json:"td"`
+	RefID     string    `json:"refId"`
+	TimeRemap *property `json:"tm"`
+	Color     string    `json:"sc"`
+	SolidW    float64   `json:"sw"`
+	SolidH    float64   `json:"sh"`
+}
+
+// localTime converts the time of the layer's composition into the layer's own
+// time.
+func (l *layer) localTime(t float64) float64 {
+	t -= l.StartTime
+	if l.Stretch != 0 {
+		t /= l.Stretch
+	}
+	return t
+}
+
+type transform struct {
+	Anchor   property `json:"a"`
+	Position property `json:"p"`
+	Scale    property `json:"s"`
+	Rotation property `json:"r"`
+	RotateZ  property `json:"rz"`
+	Opacity  property `json:"o"`
+	Skew     property `json:"sk"`
+	SkewAxis property `json:"sa"`
+}
+
+// matrix returns the transformation matrix at the given time.
+func (tr *transform) matrix(t float64) matrix {
+	ax, ay := tr.Anchor.point(t, 0, 0)
+	px, py := tr.Position.point(t, 0, 0)
+	sx, sy := tr.Scale.point(t, 100, 100)
+
+	rotation := tr.Rotation.scalar(t, 0)
+	if !tr.Rotation.isSet() {
+		rotation = tr.RotateZ.scalar(t, 0)
+	}
+
+	m := translate(px, py).
+		mul(rotate(rotation))
+
+	if skew := tr.Skew.scalar(t, 0); skew != 0 {
+		axis := tr.SkewAxis.scalar(t, 0)
+		m = m.
+			mul(rotate(axis)).
+			mul(matrix{1, 0, math.Tan(-skew * math.Pi / 180), 1, 0, 0}).
+			mul(rotate(-axis))
+	}
+
+	return m.
+		mul(scale(sx/100, sy/100)).
+		mul(translate(-ax, -ay))
+}
+
+// opacity returns the opacity at the given time from 0 to 1.
+func (tr *transform) opacity(t float64) float64 {
+	return clamp01(tr.Opacity.scalar(t, 100) / 100)
+}
+
+type mask struct {
+	Mode     string         `json:"mode"`
+	Inverted bool           `json:"inv"`
+	Path     bezierProperty `json:"pt"`
+	Opacity  property       `json:"o"`
+}
+
+type shape struct {
+	Type      string          `json:"ty"`
+	Hidden    bool            `json:"hd"`
+	Items     []*shape        `json:"it"`
+	Path      *bezierProperty `json:"ks"`
+	Direction int             `json:"d"`
+
+	// These are shared by many shape types, so they're named after their
+	// keys.
+	A  property `json:"a"`
+	P  property `json:"p"`
+	S  property `json:"s"`
+	E  property `json:"e"`
+	R  property `json:"r"`
+	O  property `json:"o"`
+	Sk property `json:"sk"`
+	Sa property `json:"sa"`
+
+	// Fills and strokes.
+	Color      property  `json:"c"`
+	Width      property  `json:"w"`
+	LineCap    int       `json:"lc"`
+	LineJoin   int       `json:"lj"`
+	MiterLimit float64   `json:"ml"`
+	Gradient   *gradient `json:"g"`
+	GradType   int       `json:"t"`
+
+	// Stars.
+	StarType    int      `json:"sy"`
+	Points      property `json:"pt"`
+	InnerRadius property `json:"ir"`
+	OuterRadius property `json:"or"`
+
+	// Trim paths.
+	TrimMode int `json:"m"`
+}
+
+// transform returns the group transform of a "tr" shape.
+func (s *shape) transform() *transform {
+	return &transform{
+		Anchor:   s.A,
+		Position: s.P,
+		Scale:    s.S,
+		Rotation: s.R,
+		Opacity:  s.O,
+		Skew:     s.Sk,
+		SkewAxis: s.Sa,
+	}
+}
+
+type gradient struct {
+	Count  int      `json:"p"`
+	Colors property `json:"k"`
+}
+
+// property is an animatable value. Static values have no keyframes.
+type property struct {
+	static    []float64
+	keyframes []keyframe
+	set       bool
+
+	// split is set for positions that animate X and Y separately.
+	split bool
+	x, y  *property
+}
+
+type keyframe struct {
+	Time       float64 `json:"t"`
+	Start      numbers `json:"s"`
+	End        numbers `json:"e"`
+	Hold       int     `json:"h"`
+	In         *easing `json:"i"`
+	Out        *easing `json:"o"`
+	TangentIn  numbers `json:"ti"`
+	TangentOut numbers `json:"to"`
+}
+
+type easing struct {
+	X numbers `json:"x"`
+	Y numbers `json:"y"`
+}
+
+// numbers is a number or an array of numbers.
+type numbers []float64
+
+func (n *numbers) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		return json.Unmarshal(b, (*[]float64)(n))
+	}
+
+	var f float64
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+
+	*n = numbers{f}
+	return nil
+}
+
+func (n numbers) first(def float64) float64 {
+	if len(n) == 0 {
+		return def
+	}
+	return n[0]
+}
+
+func (p *property) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+
+	p.set = true
+
+	// Some properties, such as the fill rule, are plain numbers.
+	if b[0] != '{' {
+		var static numbers
+		if err := json.Unmarshal(b, &static); err != nil {
+			return err
+		}
+		p.static = static
+		return nil
+	}
+
+	var raw struct {
+		K     json.RawMessage `json:"k"`
+		Split bool            `json:"s"`
+		X     *property       `json:"x"`
+		Y     *property       `json:"y"`
+	}
+
+	// The split flag is the only boolean "s" key, so ignore type errors from
+	// anything else.
+	if err := json.Unmarshal(b, &raw); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return err
+		}
+	}
+
+	if raw.Split {
+		p.split = true
+		p.x = raw.X
+		p.y = raw.Y
+		return nil
+	}
+
+	k := bytes.TrimSpace(raw.K)
+	if len(k) == 0 {
+		p.set = false
+		return nil
+	}
+
+	if isKeyframes(k) {
+		return json.Unmarshal(k, &p.keyframes)
+	}
+
+	var static numbers
+	if err := json.Unmarshal(k, &static); err != nil {
+		return err
+	}
+	p.static = static
+	return nil
+}
+
+// isKeyframes returns true if the JSON value is an array of objects.
+func isKeyframes(b []byte) bool {
+	if len(b) == 0 || b[0] != '[' {
+		return false
+	}
+	b = bytes.TrimSpace(b[1:])
+	return len(b) > 0 && b[0] == '{'
+}
+
+func (p *property) isSet() bool {
+	return p != nil && p.set
+}
+
+// value returns the property's value at the given time, or nil if the
+// property isn't set.
+func (p *property) value(t float64) []float64 {
+	if !p.isSet() {
+		return nil
+	}
+
+	if p.split {
+		x := p.x.scalar(t, 0)
+		y := p.y.scalar(t, 0)
+		return []float64{x, y}
+	}
+
+	if len(p.keyframes) == 0 {
+		return p.static
+	}
+
+	kf, next, progress := findKeyframe(p.keyframes, t)
+	if next == nil {
+		if kf.Start == nil {
+			return kf.End
+		}
+		return kf.Start
+	}
+
+	start := kf.Start
+	end := kf.End
+	if end == nil {
+		end = next.Start
+	}
+	if end == nil || kf.Hold == 1 {
+		return start
+	}
+
+	progress = kf.ease(progress)
+
+	// Positions may move along a curve instead of a line.
+	if len(kf.TangentOut) >= 2 && len(kf.TangentIn) >= 2 && len(start) >= 2 && len(end) >= 2 &&
+		(kf.TangentOut[0] != 0 || kf.TangentOut[1] != 0 || kf.TangentIn[0] != 0 || kf.TangentIn[1] != 0) {
+
+		out := make([]float64, 2)
+		for i := range out {
+			out[i] = cubicAt(
+				start[i],
+				start[i]+kf.TangentOut[i],
+				end[i]+kf.TangentIn[i],
+				end[i],
+				progress,
+			)
+		}
+		return out
+	}
+
+	return lerpSlice(start, end, progress)
+}
+
+// scalar returns the first component of the property's value.
+func (p *property) scalar(t, def float64) float64 {
+	v := p.value(t)
+	if len(v) == 0 {
+		return def
+	}
+	return v[0]
+}
+
+// point returns the first two components of the property's value.
+func (p *property) point(t, defX, defY float64) (x, y float64) {
+	v := p.value(t)
+	switch len(v) {
+	case 0:
+		return defX, defY
+	case 1:
+		return v[0], v[0]
+	default:
+		return v[0], v[1]
+	}
+}
+
+// findKeyframe returns the keyframe that's active at the given time and the
+// keyframe after it, along with the linear progress between the two. next is
+// nil if the time is outside the keyframes.
+func findKeyframe(keyframes []keyframe, t float64) (kf, next *keyframe, progress float64) {
+	if t <= keyframes[0].Time {
+		return &keyframes[0], nil, 0
+	}
+
+	for i := 0; i < len(keyframes)-1; i++ {
+		if t < keyframes[i+1].Time {
+			kf = &keyframes[i]
+			next = &keyframes[i+1]
+
+			if d := next.Time - kf.Time; d > 0 {
+				progress = (t - kf.Time) / d
+			}
+			return kf, next, progress
+		}
+	}
+
+	// Some exporters leave out the value of the last keyframe, so use the end
+	// value of the one before it.
+	last := &keyframes[len(keyframes)-1]
+	if last.Start == nil && len(keyframes) > 1 {
+		return &keyframes[len(keyframes)-2], nil, 1
+	}
+
+	return last, nil, 1
+}
+
+// ease applies the keyframe's easing curve to the linear progress.
+func (kf *keyframe) ease(progress float64) float64 {
+	if kf.Out == nil || kf.In == nil {
+		return progress
+	}
+
+	return cubicBezierEasing(
+		kf.Out.X.first(0), kf.Out.Y.first(0),
+		kf.In.X.first(1), kf.In.Y.first(1),
+		progress,
+	)
+}
+
+// cubicBezierEasing solves a CSS-style cubic-bezier easing curve for x.
+func cubicBezierEasing(x1, y1, x2, y2, x float64) float64 {
+	if x <= 0 || x >= 1 {
+		return x
+	}
+
+	// Find t for x using bisection, which always converges since the curve is
+	// monotonic on x for control points within [0, 1].
+	lo, hi := 0.0, 1.0
+	t := x
+	for i := 0; i < 24; i++ {
+		cx := cubicAt(0, x1, x2, 1, t)
+		if math.Abs(cx-x) < 1e-5 {
+			break
+		}
+		if cx < x {
+			lo = t
+		} else {
+			hi = t
+		}
+		t = (lo + hi) / 2
+	}
+
+	return cubicAt(0, y1, y2, 1, t)
+}
+
+func cubicAt(p0, p1, p2, p3, t float64) float64 {
+	mt := 1 - t
+	return mt*mt*mt*p0 + 3*mt*mt*t*p1 + 3*mt*t*t*p2 + t*t*t*p3
+}
+
+func lerpSlice(a, b []float64, t float64) []float64 {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = a[i] + (b[i]-a[i])*t
+	}
+	return out
+}
+
+// bezier is a path made of cubic bezier segments. In and Out are the tangents
+// of each vertex, relative to the vertex.
+type bezier struct {
+	Closed   bool         `json:"c"`
+	Vertices [][2]float64 `json:"v"`
+	In       [][2]float64 `json:"i"`
+	Out      [][2]float64 `json:"o"`
+}
+
+// bezierProperty is an animatable bezier path.
+type bezierProperty struct {
+	static    *bezier
+	keyframes []bezierKeyframe
+}
+
+type bezierKeyframe struct {
+	Time  float64   `json:"t"`
+	Start []*bezier `json:"s"`
+	End   []*bezier `json:"e"`
+	Hold  int       `json:"h"`
+	In    *easing   `json:"i"`
+	Out   *easing   `json:"o"`
+}
+
+func (p *bezierProperty) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		K json.RawMessage `json:"k"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	k := bytes.TrimSpace(raw.K)
+	if len(k) == 0 {
+		return nil
+	}
+
+	if k[0] == '[' {
+		return json.Unmarshal(k, &p.keyframes)
+	}
+
+	p.static = new(bezier)
+	return json.Unmarshal(k, p.static)
+}
+
+// value returns the path at the given time, or nil if there's none.
+func (p *bezierProperty) value(t float64) *bezier {
+	if p == nil {
+		return nil
+	}
+	if len(p.keyframes) == 0 {
+		return p.static
+	}
+
+	keyframes := p.keyframes
+
+	first := func(b []*bezier) *bezier {
+		if len(b) == 0 {
+			return nil
+		}
+		return b[0]
+	}
+
+	if t <= keyframes[0].Time {
+		return first(keyframes[0].Start)
+	}
+
+	for i := 0; i < len(keyframes)-1; i++ {
+		kf := &keyframes[i]
+		next := &keyframes[i+1]
+		if t >= next.Time {
+			continue
+		}
+
+		start := first(kf.Start)
+		end := first(kf.End)
+		if end == nil {
+			end = first(next.Start)
+		}
+		if end == nil || kf.Hold == 1 {
+			return start
+		}
+
+		var progress float64
+		if d := next.Time - kf.Time; d > 0 {
+			progress = (t - kf.Time) / d
+		}
+		if kf.Out != nil && kf.In != nil {
+			progress = cubicBezierEasing(
+				kf.Out.X.first(0), kf.Out.Y.first(0),
+				kf.In.X.first(1), kf.In.Y.first(1),
+				progress,
+			)
+		}
+
+		return lerpBezier(start, end, progress)
+	}
+
+	last := &keyframes[len(keyframes)-1]
+	if b := first(last.Start); b != nil {
+		return b
+	}
+	if len(keyframes) > 1 {
+		return first(keyframes[len(keyframes)-2].End)
+	}
+	return nil
+}
+
+func lerpBezier(a, b *bezier, t float64) *bezier {
+	if a == nil || b == nil || len(a.Vertices) != len(b.Vertices) {
+		return a
+	}
+
+	lerp := func(a, b [][2]float64) [][2]float64 {
+		out := make([][2]float64, len(a))
+		for i := range out {
+			if i >= len(b) {
+				out[i] = a[i]
+				continue
+			}
+			out[i][0] = a[i][0] + (b[i][0]-a[i][0])*t
+			out[i][1] = a[i][1] + (b[i][1]-a[i][1])*t
+		}
+		return out
+	}
+
+	return &bezier{
+		Closed:   a.Closed,
+		Vertices: lerp(a.Vertices, b.Vertices),
+		In:       lerp(a.In, b.In),
+		Out:      lerp(a.Out, b.Out),
+	}
+}
+
+// matrix is a 2D affine transformation matrix. A point is transformed as
+//
+//	x' = a*x + c*y + e
+//	y' = b*x + d*y + f
+type matrix struct {
+	a, b, c, d, e, f float64
+}
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+func translate(x, y float64) matrix {
+	return matrix{1, 0, 0, 1, x, y}
+}
+
+func scale(x, y float64) matrix {
+	return matrix{x, 0, 0, y, 0, 0}
+}
+
+// rotate returns a clockwise rotation in degrees.
+func rotate(deg float64) matrix {
+	rad := deg * math.Pi / 180
+	sin, cos := math.Sincos(rad)
+	return matrix{cos, sin, -sin, cos, 0, 0}
+}
+
+// mul returns m * n, which applies n first.
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		a: m.a*n.a + m.c*n.b,
+		b: m.b*n.a + m.d*n.b,
+		c: m.a*n.c + m.c*n.d,
+		d: m.b*n.c + m.d*n.d,
+		e: m.a*n.e + m.c*n.f + m.e,
+		f: m.b*n.e + m.d*n.f + m.f,
+	}
+}
+
+func (m matrix) apply(x, y float64) (float64, float64) {
+	return m.a*x + m.c*y + m.e, m.b*x + m.d*y + m.f
+}
+
+func (m matrix) invert() matrix {
+	det := m.a*m.d - m.b*m.c
+	if det == 0 {
+		return identity
+	}
+	return matrix{
+		a: m.d / det,
+		b: -m.b / det,
+		c: -m.c / det,
+		d: m.a / det,
+		e: (m.c*m.f - m.d*m.e) / det,
+		f: (m.b*m.e - m.a*m.f) / det,
+	}
+}
+
+// scaleFactor returns the average scale of the matrix, which is used to scale
+// stroke widths.
+func (m matrix) scaleFactor() float64 {
+	return math.Sqrt(math.Abs(m.a*m.d - m.b*m.c))
+}
+
+func clamp01(f float64) float64 {
+	switch {
+	case f < 0:
+		return 0
+	case f > 1:
+		return 1
+	default:
+		return f
+	}
+}
@@ -0,0 +1,132 @@
+package sticker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeLottieFile(t *testing.T, name string) (*Lottie, error) {
+	t.Helper()
+
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	return DecodeLottie(f)
+}
+
+func TestDecodeLottiePrecompCycle(t *testing.T) {
+	tests := []string{
+		"precomp_self.json",
+		"precomp_cycle.json",
+	}
+
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodeLottieFile(t, name); err == nil {
+				t.Fatal("cyclic precomp was not rejected")
+			}
+		})
+	}
+}
+
+func TestDecodeLottiePrecompNested(t *testing.T) {
+	l, err := decodeLottieFile(t, "precomp_nested.json")
+	if err != nil {
+		t.Fatal("nested precomp was rejected:", err)
+	}
+
+	anim, err := l.Render(context.Background(), 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Frames) == 0 {
+		t.Fatal("no frames")
+	}
+
+	img := anim.Frames[0].Image
+	if r, _, _, a := img.At(8, 8).RGBA(); r == 0 || a == 0 {
+		t.Fatalf("precomp solid not drawn, got %v", img.At(8, 8))
+	}
+}
+
+func TestRenderLottiePrecompDepth(t *testing.T) {
+	l, err := decodeLottieFile(t, "precomp_nested.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bypass DecodeLottie's check to make sure that rendering still stops.
+	l.precomps["b"] = l.precomps["a"]
+	l.precomps["a"][0].RefID = "a"
+
+	l.Render(context.Background(), 16, 16)
+}
+
+// fanOutLottie returns a Lottie animation whose precomps each include the next
+// precomp fan times, depth levels deep.
+func fanOutLottie(t *testing.T, depth, fan int) *Lottie {
+	t.Helper()
+
+	var assets []string
+	for d := 0; d < depth; d++ {
+		layer := fmt.Sprintf(`{"ty": 0, "refId": "p%d", "ip": 0, "op": 60, "ks": {}}`, d+1)
+		if d == depth-1 {
+			layer = `{"ty": 1, "sc": "#ff0000", "sw": 16, "sh": 16, "ip": 0, "op": 60, "ks": {}}`
+		}
+
+		layers := strings.Repeat(layer+",", fan)
+		layers = strings.TrimSuffix(layers, ",")
+
+		assets = append(assets, fmt.Sprintf(`{"id": "p%d", "layers": [%s]}`, d, layers))
+	}
+
+	src := fmt.Sprintf(
+		`{"fr": 30, "ip": 0, "op": 60, "w": 16, "h": 16, "assets": [%s], `+
+			`"layers": [{"ty": 0, "refId": "p0", "ip": 0, "op": 60, "ks": {}}]}`,
+		strings.Join(assets, ", "))
+
+	l, err := DecodeLottie(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+func TestRenderLottiePrecompFanOut(t *testing.T) {
+	// 6^12 solids would never finish rendering.
+	l := fanOutLottie(t, 12, 6)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.Render(context.Background(), 16, 16)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("precomp fan-out was not rejected")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("precomp fan-out is still rendering")
+	}
+}
+
+func TestRenderLottieCancel(t *testing.T) {
+	l := fanOutLottie(t, 2, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := l.Render(ctx, 16, 16); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
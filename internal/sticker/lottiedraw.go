@@ -0,0 +1,1061 @@
+package sticker
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/vector"
+)
+
+// maxLottieFPS is the highest frame rate that Lottie animations are rendered
+// at. Stickers are usually 60 FPS, which would use too much memory.
+const maxLottieFPS = 30
+
+// maxLottieFrames is the maximum number of frames rendered from a Lottie
+// animation.
+const maxLottieFrames = 300
+
+// maxPrecompDepth is the maximum nesting of precomps. DecodeLottie already
+// rejects precomps that include themselves, so this only guards against
+// absurdly deep nesting.
+const maxPrecompDepth = 16
+
+// maxLottieOps is the maximum number of layers and shapes drawn for a single
+// frame. Precomps that include other precomps many times over multiply the
+// work with every level, so a small file can take forever to render.
+const maxLottieOps = 2000
+
+var errLottieTooComplex = errors.New("Lottie animation is too complex")
+
+// bezierKappa is the tangent length that approximates a quarter circle with
+// a cubic bezier curve.
+const bezierKappa = 0.5522847498
+
+// Render rasterizes the animation into frames of the given size. It stops
+// once ctx is done.
+func (l *Lottie) Render(ctx context.Context, width, height int) (*Animation, error) {
+	step := math.Ceil(l.FrameRate / maxLottieFPS)
+	delay := time.Duration(step / l.FrameRate * float64(time.Second))
+
+	anim := Animation{
+		Width:  width,
+		Height: height,
+	}
+
+	r := lottieRenderer{
+		lottie: l,
+		root:   scale(float64(width)/l.Width, float64(height)/l.Height),
+		bounds: image.Rect(0, 0, width, height),
+	}
+
+	for t := l.InPoint; t < l.OutPoint && len(anim.Frames) < maxLottieFrames; t += step {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		img := image.NewRGBA(r.bounds)
+		r.ops = 0
+		r.renderLayers(img, l.Layers, t, r.root, 1, 0)
+		if r.ops > maxLottieOps {
+			return nil, errLottieTooComplex
+		}
+
+		anim.Frames = append(anim.Frames, Frame{
+			Image: img,
+			Delay: delay,
+		})
+	}
+
+	if len(anim.Frames) == 0 {
+		anim.Frames = []Frame{{Image: image.NewRGBA(r.bounds)}}
+	}
+
+	return &anim, nil
+}
+
+type lottieRenderer struct {
+	lottie *Lottie
+	root   matrix
+	bounds image.Rectangle
+	raster vector.Rasterizer
+	ops    int // layers and shapes drawn for the current frame
+}
+
+// spend counts an operation towards maxLottieOps. It returns false once the
+// frame is over budget.
+func (r *lottieRenderer) spend() bool {
+	r.ops++
+	return r.ops <= maxLottieOps
+}
+
+// renderLayers draws the layers of a composition from bottom to top. depth is
+// the number of precomps that the composition is nested in.
+func (r *lottieRenderer) renderLayers(dst *image.RGBA, layers []*layer, t float64, m matrix, alpha float64, depth int) {
+	byIndex := make(map[int]*layer, len(layers))
+	for _, l := range layers {
+		if l.Index != nil {
+			byIndex[*l.Index] = l
+		}
+	}
+
+	for i := len(layers) - 1; i >= 0 && r.ops <= maxLottieOps; i-- {
+		l := layers[i]
+		if l.IsMatte != 0 || !r.visible(l, t) {
+			continue
+		}
+
+		// The track matte of a layer is the layer right above it.
+		var matte *layer
+		if l.MatteMode != 0 && i > 0 && layers[i-1].IsMatte != 0 {
+			matte = layers[i-1]
+		}
+
+		if matte == nil && len(l.Masks) == 0 {
+			r.renderLayer(dst, l, byIndex, t, m, alpha, depth)
+			continue
+		}
+
+		content := image.NewRGBA(r.bounds)
+		r.renderLayer(content, l, byIndex, t, m, alpha, depth)
+
+		if len(l.Masks) > 0 {
+			r.applyMasks(content, l, byIndex, t, m)
+		}
+
+		if matte != nil {
+			matteImg := image.NewRGBA(r.bounds)
+			if r.visible(matte, t) {
+				r.renderLayer(matteImg, matte, byIndex, t, m, 1, depth)
+			}
+			applyMatte(content, matteImg, l.MatteMode)
+		}
+
+		draw.Draw(dst, r.bounds, content, image.Point{}, draw.Over)
+	}
+}
+
+func (r *lottieRenderer) visible(l *layer, t float64) bool {
+	return !l.Hidden && t >= l.InPoint && t < l.OutPoint
+}
+
+// layerMatrix returns the layer's transformation including its parents.
+func (r *lottieRenderer) layerMatrix(l *layer, byIndex map[int]*layer, t float64) matrix {
+	m := l.Transform.matrix(l.localTime(t))
+
+	// Guard against parenting loops.
+	for depth := 0; l.Parent != nil && depth < 32; depth++ {
+		parent, ok := byIndex[*l.Parent]
+		if !ok {
+			break
+		}
+		m = parent.Transform.matrix(parent.localTime(t)).mul(m)
+		l = parent
+	}
+
+	return m
+}
+
+func (r *lottieRenderer) renderLayer(dst *image.RGBA, l *layer, byIndex map[int]*layer, t float64, m matrix, alpha float64, depth int) {
+	if !r.spend() {
+		return
+	}
+
+	local := l.localTime(t)
+	m = m.mul(r.layerMatrix(l, byIndex, t))
+	alpha *= l.Transform.opacity(local)
+
+	if alpha <= 0 {
+		return
+	}
+
+	switch l.Type {
+	case layerShape:
+		var ops []*drawOp
+		r.collectShapes(&ops, l.Shapes, local, m, alpha, nil, nil)
+		for _, op := range ops {
+			r.draw(dst, op, local)
+		}
+
+	case layerSolid:
+		c, ok := parseHexColor(l.Color)
+		if !ok {
+			return
+		}
+		op := drawOp{
+			style: &shape{Type: "fl"},
+			color: c,
+			alpha: alpha,
+			paths: []polyline{rectPath(m, 0, 0, l.SolidW, l.SolidH)},
+		}
+		r.draw(dst, &op, local)
+
+	case layerPrecomp:
+		layers, ok := r.lottie.precomps[l.RefID]
+		if !ok || depth >= maxPrecompDepth {
+			return
+		}
+		if l.TimeRemap.isSet() {
+			local = l.TimeRemap.scalar(local, 0) * r.lottie.FrameRate
+		}
+		r.renderLayers(dst, layers, local, m, alpha, depth+1)
+	}
+}
+
+// drawOp is a fill or a stroke along with all the paths that it paints.
+type drawOp struct {
+	style  *shape
+	matrix matrix // the transformation where the style is declared
+	alpha  float64
+	color  [4]float64 // only used by solids
+	paths  []polyline
+}
+
+type trim struct {
+	start, end, offset float64
+}
+
+// collectShapes walks the shapes of a group from the bottom up. Fills and
+// strokes paint all shapes above them in the same group, including shapes
+// inside nested groups, so every shape is added to all styles seen so far.
+func (r *lottieRenderer) collectShapes(ops *[]*drawOp, items []*shape, t float64, m matrix, alpha float64, styles []*drawOp, trims []trim) {
+	for _, item := range items {
+		if item.Type == "tr" {
+			tr := item.transform()
+			m = m.mul(tr.matrix(t))
+			alpha *= tr.opacity(t)
+		}
+	}
+
+	if alpha <= 0 {
+		return
+	}
+
+	// Copy the slices so that nested groups can't add to ours.
+	styles = append([]*drawOp(nil), styles...)
+	trims = append([]trim(nil), trims...)
+
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if item.Hidden {
+			continue
+		}
+
+		switch item.Type {
+		case "fl", "st", "gf", "gs":
+			op := &drawOp{
+				style:  item,
+				matrix: m,
+				alpha:  alpha,
+			}
+			*ops = append(*ops, op)
+			styles = append(styles, op)
+
+		case "tm":
+			trims = append(trims, trim{
+				start:  item.S.scalar(t, 0) / 100,
+				end:    item.E.scalar(t, 100) / 100,
+				offset: item.O.scalar(t, 0) / 360,
+			})
+
+		case "gr":
+			r.collectShapes(ops, item.Items, t, m, alpha, styles, trims)
+
+		case "sh", "rc", "el", "sr":
+			if len(styles) == 0 {
+				continue
+			}
+
+			path := shapePath(item, t, m)
+			if path == nil {
+				continue
+			}
+
+			paths := []polyline{*path}
+			for _, tr := range trims {
+				paths = trimPaths(paths, tr)
+			}
+
+			for _, op := range styles {
+				op.paths = append(op.paths, paths...)
+			}
+		}
+	}
+}
+
+// draw paints the paths of the draw operation.
+func (r *lottieRenderer) draw(dst *image.RGBA, op *drawOp, t float64) {
+	if len(op.paths) == 0 || !r.spend() {
+		return
+	}
+
+	style := op.style
+	alpha := op.alpha * clamp01(style.O.scalar(t, 100)/100)
+	if alpha <= 0 {
+		return
+	}
+
+	var src image.Image
+	switch style.Type {
+	case "fl", "st":
+		c := op.color
+		if style.Color.isSet() {
+			c = colorValue(style.Color.value(t))
+		}
+		src = image.NewUniform(premultiply(c, alpha))
+	case "gf", "gs":
+		g := newGradientImage(style, t, op.matrix, alpha)
+		if g == nil {
+			return
+		}
+		src = g
+	default:
+		return
+	}
+
+	r.raster.Reset(r.bounds.Dx(), r.bounds.Dy())
+
+	switch style.Type {
+	case "fl", "gf":
+		for _, path := range op.paths {
+			addPolygon(&r.raster, path.points, false)
+		}
+	case "st", "gs":
+		width := style.Width.scalar(t, 1) * op.matrix.scaleFactor()
+		if width <= 0 {
+			return
+		}
+		for _, path := range op.paths {
+			strokePath(&r.raster, path, width/2, style.LineCap, style.LineJoin, style.MiterLimit)
+		}
+	}
+
+	r.raster.Draw(dst, r.bounds, src, image.Point{})
+}
+
+func (r *lottieRenderer) applyMasks(content *image.RGBA, l *layer, byIndex map[int]*layer, t float64, m matrix) {
+	local := l.localTime(t)
+	m = m.mul(r.layerMatrix(l, byIndex, t))
+
+	coverage := make([]float64, r.bounds.Dx()*r.bounds.Dy())
+	if mode := l.Masks[0].Mode; mode == "s" || mode == "i" {
+		for i := range coverage {
+			coverage[i] = 1
+		}
+	}
+
+	maskImg := image.NewAlpha(r.bounds)
+
+	for _, mask := range l.Masks {
+		if mask.Mode == "n" {
+			continue
+		}
+
+		b := mask.Path.value(local)
+		if b == nil {
+			continue
+		}
+
+		for i := range maskImg.Pix {
+			maskImg.Pix[i] = 0
+		}
+
+		r.raster.Reset(r.bounds.Dx(), r.bounds.Dy())
+		addPolygon(&r.raster, flattenBezier(b, m).points, false)
+		r.raster.Draw(maskImg, r.bounds, image.Opaque, image.Point{})
+
+		opacity := clamp01(mask.Opacity.scalar(local, 100) / 100)
+
+		for i := range coverage {
+			v := float64(maskImg.Pix[i]) / 0xFF
+			if mask.Inverted {
+				v = 1 - v
+			}
+			v *= opacity
+
+			switch mask.Mode {
+			case "s":
+				coverage[i] *= 1 - v
+			case "i":
+				coverage[i] *= v
+			default:
+				coverage[i] += v * (1 - coverage[i])
+			}
+		}
+	}
+
+	for i, v := range coverage {
+		scalePixel(content.Pix[i*4:i*4+4], v)
+	}
+}
+
+// Track matte modes.
+const (
+	matteAlpha         = 1
+	matteAlphaInverted = 2
+	matteLuma          = 3
+	matteLumaInverted  = 4
+)
+
+// applyMatte multiplies the content with the coverage of the matte.
+func applyMatte(content, matte *image.RGBA, mode int) {
+	for i := 0; i < len(content.Pix); i += 4 {
+		px := matte.Pix[i : i+4]
+
+		var v float64
+		switch mode {
+		case matteAlpha, matteAlphaInverted:
+			v = float64(px[3]) / 0xFF
+		case matteLuma, matteLumaInverted:
+			// The pixels are premultiplied, so this is also scaled by alpha.
+			v = (0.299*float64(px[0]) + 0.587*float64(px[1]) + 0.114*float64(px[2])) / 0xFF
+		default:
+			continue
+		}
+
+		if mode == matteAlphaInverted || mode == matteLumaInverted {
+			v = 1 - v
+		}
+
+		scalePixel(content.Pix[i:i+4], v)
+	}
+}
+
+func scalePixel(px []uint8, v float64) {
+	if v >= 1 {
+		return
+	}
+	for i := range px {
+		px[i] = uint8(float64(px[i]) * v)
+	}
+}
+
+type point struct {
+	x, y float64
+}
+
+// polyline is a flattened path in canvas coordinates.
+type polyline struct {
+	points []point
+	closed bool
+}
+
+// shapePath builds the path of a shape and transforms it into canvas
+// coordinates.
+func shapePath(s *shape, t float64, m matrix) *polyline {
+	var b *bezier
+
+	switch s.Type {
+	case "sh":
+		b = s.Path.value(t)
+	case "rc":
+		b = rectBezier(s, t)
+	case "el":
+		b = ellipseBezier(s, t)
+	case "sr":
+		b = starBezier(s, t)
+	}
+
+	if b == nil || len(b.Vertices) == 0 {
+		return nil
+	}
+
+	path := flattenBezier(b, m)
+	return &path
+}
+
+func rectBezier(s *shape, t float64) *bezier {
+	cx, cy := s.P.point(t, 0, 0)
+	w, h := s.S.point(t, 0, 0)
+	round := s.R.scalar(t, 0)
+
+	round = math.Min(round, math.Min(w/2, h/2))
+	x0, y0 := cx-w/2, cy-h/2
+	x1, y1 := cx+w/2, cy+h/2
+
+	if round <= 0 {
+		return &bezier{
+			Closed:   true,
+			Vertices: [][2]float64{{x1, y0}, {x1, y1}, {x0, y1}, {x0, y0}},
+		}
+	}
+
+	k := round * bezierKappa
+	return &bezier{
+		Closed: true,
+		Vertices: [][2]float64{
+			{x1, y0 + round}, {x1, y1 - round},
+			{x1 - round, y1}, {x0 + round, y1},
+			{x0, y1 - round}, {x0, y0 + round},
+			{x0 + round, y0}, {x1 - round, y0},
+		},
+		In: [][2]float64{
+			{0, -k}, {0, 0},
+			{k, 0}, {0, 0},
+			{0, k}, {0, 0},
+			{-k, 0}, {0, 0},
+		},
+		Out: [][2]float64{
+			{0, 0}, {0, k},
+			{0, 0}, {-k, 0},
+			{0, 0}, {0, -k},
+			{0, 0}, {k, 0},
+		},
+	}
+}
+
+func ellipseBezier(s *shape, t float64) *bezier {
+	cx, cy := s.P.point(t, 0, 0)
+	w, h := s.S.point(t, 0, 0)
+	rx, ry := w/2, h/2
+	kx, ky := rx*bezierKappa, ry*bezierKappa
+
+	return &bezier{
+		Closed: true,
+		Vertices: [][2]float64{
+			{cx, cy - ry}, {cx + rx, cy}, {cx, cy + ry}, {cx - rx, cy},
+		},
+		In: [][2]float64{
+			{-kx, 0}, {0, -ky}, {kx, 0}, {0, ky},
+		},
+		Out: [][2]float64{
+			{kx, 0}, {0, ky}, {-kx, 0}, {0, -ky},
+		},
+	}
+}
+
+func starBezier(s *shape, t float64) *bezier {
+	cx, cy := s.P.point(t, 0, 0)
+	points := int(math.Round(s.Points.scalar(t, 5)))
+	outer := s.OuterRadius.scalar(t, 0)
+	inner := s.InnerRadius.scalar(t, 0)
+	rotation := s.R.scalar(t, 0)
+
+	if points < 2 {
+		return nil
+	}
+
+	star := s.StarType != 2
+	n := points
+	if star {
+		n *= 2
+	}
+
+	b := bezier{Closed: true}
+	for i := 0; i < n; i++ {
+		radius := outer
+		if star && i%2 == 1 {
+			radius = inner
+		}
+
+		angle := (rotation-90)*math.Pi/180 + float64(i)*2*math.Pi/float64(n)
+		sin, cos := math.Sincos(angle)
+		b.Vertices = append(b.Vertices, [2]float64{cx + radius*cos, cy + radius*sin})
+	}
+
+	return &b
+}
+
+// rectPath returns the closed path of a rectangle in canvas coordinates.
+func rectPath(m matrix, x, y, w, h float64) polyline {
+	corners := [][2]float64{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}
+	path := polyline{closed: true}
+	for _, c := range corners {
+		px, py := m.apply(c[0], c[1])
+		path.points = append(path.points, point{px, py})
+	}
+	return path
+}
+
+// flattenBezier transforms the bezier path and approximates it with lines.
+func flattenBezier(b *bezier, m matrix) polyline {
+	path := polyline{closed: b.Closed}
+
+	tangent := func(tangents [][2]float64, i int) [2]float64 {
+		if i < len(tangents) {
+			return tangents[i]
+		}
+		return [2]float64{}
+	}
+
+	n := len(b.Vertices)
+	if n == 0 {
+		return path
+	}
+
+	x, y := m.apply(b.Vertices[0][0], b.Vertices[0][1])
+	path.points = append(path.points, point{x, y})
+
+	segments := n - 1
+	if b.Closed {
+		segments = n
+	}
+
+	for i := 0; i < segments; i++ {
+		j := (i + 1) % n
+		v0, v1 := b.Vertices[i], b.Vertices[j]
+		out, in := tangent(b.Out, i), tangent(b.In, j)
+
+		x0, y0 := m.apply(v0[0], v0[1])
+		x1, y1 := m.apply(v0[0]+out[0], v0[1]+out[1])
+		x2, y2 := m.apply(v1[0]+in[0], v1[1]+in[1])
+		x3, y3 := m.apply(v1[0], v1[1])
+
+		if out == ([2]float64{}) && in == ([2]float64{}) {
+			path.points = append(path.points, point{x3, y3})
+			continue
+		}
+
+		// Subdivide based on the length of the control polygon.
+		length := math.Hypot(x1-x0, y1-y0) + math.Hypot(x2-x1, y2-y1) + math.Hypot(x3-x2, y3-y2)
+		steps := int(length / 2)
+		if steps < 2 {
+			steps = 2
+		}
+		if steps > 48 {
+			steps = 48
+		}
+
+		for s := 1; s <= steps; s++ {
+			t := float64(s) / float64(steps)
+			path.points = append(path.points, point{
+				cubicAt(x0, x1, x2, x3, t),
+				cubicAt(y0, y1, y2, y3, t),
+			})
+		}
+	}
+
+	if b.Closed && len(path.points) > 1 && path.points[0] == path.points[len(path.points)-1] {
+		path.points = path.points[:len(path.points)-1]
+	}
+
+	return path
+}
+
+// trimPaths cuts each path to the trimmed range. Paths are trimmed
+// individually.
+func trimPaths(paths []polyline, tr trim) []polyline {
+	start, end := tr.start, tr.end
+	if start > end {
+		start, end = end, start
+	}
+	if end-start >= 1 {
+		return paths
+	}
+	if end-start <= 0 {
+		return nil
+	}
+
+	start += tr.offset
+	end += tr.offset
+	shift := math.Floor(start)
+	start -= shift
+	end -= shift
+
+	var out []polyline
+	for _, path := range paths {
+		if end <= 1 {
+			out = appendSubpath(out, path, start, end)
+		} else {
+			// The range wraps around the end of the path.
+			out = appendSubpath(out, path, start, 1)
+			out = appendSubpath(out, path, 0, end-1)
+		}
+	}
+	return out
+}
+
+// appendSubpath appends the part of the path between the start and end
+// fractions of its length.
+func appendSubpath(out []polyline, path polyline, start, end float64) []polyline {
+	points := path.points
+	if path.closed && len(points) > 0 {
+		points = append(points[:len(points):len(points)], points[0])
+	}
+	if len(points) < 2 {
+		return out
+	}
+
+	var total float64
+	for i := 1; i < len(points); i++ {
+		total += math.Hypot(points[i].x-points[i-1].x, points[i].y-points[i-1].y)
+	}
+	if total == 0 {
+		return out
+	}
+
+	from, to := start*total, end*total
+	sub := polyline{}
+
+	var pos float64
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		length := math.Hypot(b.x-a.x, b.y-a.y)
+		next := pos + length
+
+		if next >= from && pos <= to && length > 0 {
+			t0 := math.Max(0, (from-pos)/length)
+			t1 := math.Min(1, (to-pos)/length)
+			if len(sub.points) == 0 {
+				sub.points = append(sub.points, lerpPoint(a, b, t0))
+			}
+			sub.points = append(sub.points, lerpPoint(a, b, t1))
+		}
+
+		pos = next
+	}
+
+	if len(sub.points) > 1 {
+		out = append(out, sub)
+	}
+	return out
+}
+
+func lerpPoint(a, b point, t float64) point {
+	return point{a.x + (b.x-a.x)*t, a.y + (b.y-a.y)*t}
+}
+
+// addPolygon adds a closed polygon to the rasterizer. If normalize is true,
+// then the polygon is reversed as needed so that all polygons have the same
+// winding direction, which makes overlapping polygons add up instead of
+// cancelling out.
+func addPolygon(z *vector.Rasterizer, points []point, normalize bool) {
+	if len(points) < 3 {
+		return
+	}
+
+	reverse := false
+	if normalize {
+		var area float64
+		for i := range points {
+			a, b := points[i], points[(i+1)%len(points)]
+			area += a.x*b.y - b.x*a.y
+		}
+		reverse = area < 0
+	}
+
+	at := func(i int) point {
+		if reverse {
+			return points[len(points)-1-i]
+		}
+		return points[i]
+	}
+
+	z.MoveTo(float32(at(0).x), float32(at(0).y))
+	for i := 1; i < len(points); i++ {
+		p := at(i)
+		z.LineTo(float32(p.x), float32(p.y))
+	}
+	z.ClosePath()
+}
+
+// Line caps and joins.
+const (
+	capButt   = 1
+	capRound  = 2
+	capSquare = 3
+
+	joinMiter = 1
+	joinRound = 2
+	joinBevel = 3
+)
+
+// strokePath adds the outline of the stroked path as polygons.
+func strokePath(z *vector.Rasterizer, path polyline, hw float64, lineCap, lineJoin int, miterLimit float64) {
+	points := path.points
+	if len(points) < 2 {
+		return
+	}
+	if path.closed {
+		points = append(points[:len(points):len(points)], points[0])
+	}
+
+	if miterLimit <= 0 {
+		miterLimit = 4
+	}
+
+	// Drop repeated points, which have no direction.
+	dedup := points[:1:1]
+	for _, p := range points[1:] {
+		if p != dedup[len(dedup)-1] {
+			dedup = append(dedup, p)
+		}
+	}
+	points = dedup
+	if len(points) < 2 {
+		if lineCap == capRound {
+			addCircle(z, points[0], hw)
+		}
+		return
+	}
+
+	normal := func(a, b point) point {
+		dx, dy := b.x-a.x, b.y-a.y
+		l := math.Hypot(dx, dy)
+		return point{-dy / l * hw, dx / l * hw}
+	}
+
+	if !path.closed && lineCap == capSquare {
+		points = append([]point(nil), points...)
+		points[0] = extend(points[1], points[0], hw)
+		last := len(points) - 1
+		points[last] = extend(points[last-1], points[last], hw)
+	}
+
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		n := normal(a, b)
+		addPolygon(z, []point{
+			{a.x + n.x, a.y + n.y},
+			{b.x + n.x, b.y + n.y},
+			{b.x - n.x, b.y - n.y},
+			{a.x - n.x, a.y - n.y},
+		}, true)
+	}
+
+	// Join every pair of segments, including the closing one.
+	joins := len(points) - 1
+	if !path.closed {
+		joins--
+	}
+	for i := 1; i <= joins; i++ {
+		prev := points[i-1]
+		v := points[i]
+		next := points[(i+1)%len(points)]
+		if i+1 == len(points) {
+			next = points[1]
+		}
+		addJoin(z, prev, v, next, hw, lineJoin, miterLimit, normal)
+	}
+
+	if !path.closed && lineCap == capRound {
+		addCircle(z, points[0], hw)
+		addCircle(z, points[len(points)-1], hw)
+	}
+}
+
+func addJoin(z *vector.Rasterizer, prev, v, next point, hw float64, join int, miterLimit float64, normal func(a, b point) point) {
+	if join == joinRound {
+		addCircle(z, v, hw)
+		return
+	}
+
+	n1 := normal(prev, v)
+	n2 := normal(v, next)
+
+	// Only the outer side of the turn needs to be filled. The normals point
+	// to the left of each segment, so the outer side of a left turn is on
+	// the right.
+	cross := (v.x-prev.x)*(next.y-v.y) - (v.y-prev.y)*(next.x-v.x)
+	if cross == 0 {
+		return
+	}
+	sign := 1.0
+	if cross > 0 {
+		sign = -1
+	}
+
+	p1 := point{v.x + n1.x*sign, v.y + n1.y*sign}
+	p2 := point{v.x + n2.x*sign, v.y + n2.y*sign}
+
+	if join == joinMiter {
+		// The miter tip is along the bisector of both normals.
+		bx, by := n1.x+n2.x, n1.y+n2.y
+		if bl := math.Hypot(bx, by); bl > 0 {
+			cos := bl / 2 / hw
+			if 1/cos <= miterLimit {
+				length := hw / cos
+				tip := point{v.x + bx/bl*length*sign, v.y + by/bl*length*sign}
+				addPolygon(z, []point{v, p1, tip, p2}, true)
+				return
+			}
+		}
+	}
+
+	addPolygon(z, []point{v, p1, p2}, true)
+}
+
+// extend moves b away from a by the given distance.
+func extend(a, b point, d float64) point {
+	dx, dy := b.x-a.x, b.y-a.y
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		return b
+	}
+	return point{b.x + dx/l*d, b.y + dy/l*d}
+}
+
+func addCircle(z *vector.Rasterizer, c point, r float64) {
+	const steps = 16
+	points := make([]point, steps)
+	for i := range points {
+		sin, cos := math.Sincos(float64(i) * 2 * math.Pi / steps)
+		points[i] = point{c.x + r*cos, c.y + r*sin}
+	}
+	addPolygon(z, points, true)
+}
+
+// colorValue converts a Lottie color into RGBA from 0 to 1.
+func colorValue(v []float64) [4]float64 {
+	c := [4]float64{0, 0, 0, 1}
+	copy(c[:], v)
+
+	// Some old exporters use 0-255.
+	if c[0] > 1 || c[1] > 1 || c[2] > 1 {
+		for i := 0; i < 3; i++ {
+			c[i] /= 255
+		}
+	}
+
+	return c
+}
+
+func premultiply(c [4]float64, alpha float64) color.RGBA {
+	a := clamp01(c[3] * alpha)
+	return color.RGBA{
+		R: uint8(clamp01(c[0]) * a * 0xFF),
+		G: uint8(clamp01(c[1]) * a * 0xFF),
+		B: uint8(clamp01(c[2]) * a * 0xFF),
+		A: uint8(a * 0xFF),
+	}
+}
+
+func parseHexColor(s string) ([4]float64, bool) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return [4]float64{}, false
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return [4]float64{}, false
+	}
+
+	return [4]float64{
+		float64(v>>16&0xFF) / 0xFF,
+		float64(v>>8&0xFF) / 0xFF,
+		float64(v&0xFF) / 0xFF,
+		1,
+	}, true
+}
+
+type gradientStop struct {
+	offset float64
+	color  [4]float64
+}
+
+// gradientImage is an infinitely large image of a linear or radial gradient.
+type gradientImage struct {
+	inverse    matrix // canvas to gradient coordinates
+	radial     bool
+	start, end point
+	stops      []gradientStop
+	alpha      float64
+}
+
+func newGradientImage(s *shape, t float64, m matrix, alpha float64) *gradientImage {
+	if s.Gradient == nil {
+		return nil
+	}
+
+	values := s.Gradient.Colors.value(t)
+	count := s.Gradient.Count
+	if count <= 0 || len(values) < count*4 {
+		return nil
+	}
+
+	g := gradientImage{
+		inverse: m.invert(),
+		radial:  s.GradType == 2,
+		alpha:   alpha,
+	}
+	g.start.x, g.start.y = s.S.point(t, 0, 0)
+	g.end.x, g.end.y = s.E.point(t, 0, 0)
+
+	for i := 0; i < count; i++ {
+		v := values[i*4 : i*4+4]
+		g.stops = append(g.stops, gradientStop{
+			offset: v[0],
+			color:  [4]float64{v[1], v[2], v[3], 1},
+		})
+	}
+
+	// Opacity stops come after the color stops as offset and alpha pairs.
+	opacities := values[count*4:]
+	if len(opacities) >= 4 {
+		for i := range g.stops {
+			g.stops[i].color[3] = interpolateOpacity(opacities, g.stops[i].offset)
+		}
+	}
+
+	return &g
+}
+
+func interpolateOpacity(stops []float64, offset float64) float64 {
+	n := len(stops) / 2
+	if offset <= stops[0] {
+		return stops[1]
+	}
+	for i := 1; i < n; i++ {
+		o0, a0 := stops[(i-1)*2], stops[(i-1)*2+1]
+		o1, a1 := stops[i*2], stops[i*2+1]
+		if offset <= o1 {
+			if o1 == o0 {
+				return a1
+			}
+			return a0 + (a1-a0)*(offset-o0)/(o1-o0)
+		}
+	}
+	return stops[(n-1)*2+1]
+}
+
+func (g *gradientImage) ColorModel() color.Model { return color.RGBAModel }
+
+func (g *gradientImage) Bounds() image.Rectangle {
+	return image.Rect(-1e9, -1e9, 1e9, 1e9)
+}
+
+func (g *gradientImage) At(x, y int) color.Color {
+	px, py := g.inverse.apply(float64(x)+0.5, float64(y)+0.5)
+	dx, dy := g.end.x-g.start.x, g.end.y-g.start.y
+
+	var pos float64
+	if g.radial {
+		if radius := math.Hypot(dx, dy); radius > 0 {
+			pos = math.Hypot(px-g.start.x, py-g.start.y) / radius
+		}
+	} else {
+		if l := dx*dx + dy*dy; l > 0 {
+			pos = ((px-g.start.x)*dx + (py-g.start.y)*dy) / l
+		}
+	}
+
+	return premultiply(g.colorAt(clamp01(pos)), g.alpha)
+}
+
+func (g *gradientImage) colorAt(pos float64) [4]float64 {
+	if pos <= g.stops[0].offset {
+		return g.stops[0].color
+	}
+	for i := 1; i < len(g.stops); i++ {
+		s0, s1 := g.stops[i-1], g.stops[i]
+		if pos <= s1.offset {
+			if s1.offset == s0.offset {
+				return s1.color
+			}
+			t := (pos - s0.offset) / (s1.offset - s0.offset)
+			var c [4]float64
+			for j := range c {
+				c[j] = s0.color[j] + (s1.color[j]-s0.color[j])*t
+			}
+			return c
+		}
+	}
+	return g.stops[len(g.stops)-1].color
+}
@@ -0,0 +1,241 @@
+package sticker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+)
+
+// maxCachedBytes is the size of the decoded sticker frames kept in memory.
+// The most recently shown sticker is always kept.
+const maxCachedBytes = 128 << 20
+
+// frameCache caches the frames of recently shown stickers. It must only be
+// used from the main thread.
+var frameCache = struct {
+	frames map[string][]texture
+	keys   []string
+	size   int
+}{
+	frames: make(map[string][]texture),
+}
+
+func cacheFrames(key string, frames []texture) {
+	if old, ok := frameCache.frames[key]; ok {
+		frameCache.size -= texturesSize(old)
+	} else {
+		frameCache.keys = append(frameCache.keys, key)
+	}
+	frameCache.frames[key] = frames
+	frameCache.size += texturesSize(frames)
+
+	for frameCache.size > maxCachedBytes && len(frameCache.keys) > 1 {
+		oldest := frameCache.keys[0]
+		frameCache.size -= texturesSize(frameCache.frames[oldest])
+		delete(frameCache.frames, oldest)
+		frameCache.keys = frameCache.keys[1:]
+	}
+}
+
+type texture struct {
+	paintable gdk.Paintabler
+	delay     time.Duration
+	size      int // bytes
+}
+
+func texturesSize(textures []texture) int {
+	var size int
+	for _, texture := range textures {
+		size += texture.size
+	}
+	return size
+}
+
+func newTextures(anim *Animation) []texture {
+	textures := make([]texture, len(anim.Frames))
+	for i, frame := range anim.Frames {
+		textures[i] = texture{
+			paintable: gdk.NewMemoryTexture(
+				frame.Image.Rect.Dx(),
+				frame.Image.Rect.Dy(),
+				gdk.MemoryR8G8B8A8Premultiplied,
+				glib.NewBytesWithGo(frame.Image.Pix),
+				uint(frame.Image.Stride),
+			),
+			delay: frame.Delay,
+			size:  len(frame.Image.Pix),
+		}
+	}
+	return textures
+}
+
+// Picture is a picture that shows a sticker. Animated stickers only play once
+// their animation is enabled and started.
+type Picture struct {
+	*gtk.Picture
+	ctx  context.Context
+	size int
+	url  string
+
+	frames  []texture
+	frame   int
+	timer   glib.SourceHandle
+	playing bool
+}
+
+// NewPicture creates a new sticker picture with the given size.
+func NewPicture(ctx context.Context, size int) *Picture {
+	p := Picture{
+		ctx:  ctx,
+		size: size,
+	}
+
+	p.Picture = gtk.NewPicture()
+	p.Picture.AddCSSClass("sticker-picture")
+	p.Picture.SetCanShrink(true)
+	p.Picture.SetKeepAspectRatio(true)
+	p.Picture.SetSizeRequest(size, size)
+
+	return &p
+}
+
+// SetSticker sets the sticker to show.
+func (p *Picture) SetSticker(sticker discord.StickerItem) {
+	p.SetAlternativeText(sticker.Name)
+	p.SetFromURL(URL(sticker), sticker.FormatType)
+}
+
+// SetFromURL sets the sticker to the one at the given URL.
+func (p *Picture) SetFromURL(url string, format discord.StickerFormatType) {
+	if p.url == url {
+		return
+	}
+
+	p.url = url
+	p.setFrames(nil)
+
+	size := p.size * gtkutil.ScaleFactor()
+	key := fmt.Sprintf("%s@%d", url, size)
+
+	if frames, ok := frameCache.frames[key]; ok {
+		p.setFrames(frames)
+		return
+	}
+
+	gtkutil.Async(p.ctx, func() func() {
+		anim, err := Fetch(p.ctx, url, format, size)
+		if err != nil {
+			log.Printf("sticker: cannot load %q: %v", url, err)
+			return func() {
+				if p.url == url {
+					p.SetPaintable(imgutil.IconPaintable("image-missing-symbolic", p.size, p.size))
+				}
+			}
+		}
+
+		return func() {
+			frames := newTextures(anim)
+			cacheFrames(key, frames)
+
+			if p.url == url {
+				p.setFrames(frames)
+			}
+		}
+	})
+}
+
+func (p *Picture) setFrames(frames []texture) {
+	p.stopTimer()
+	p.frames = frames
+	p.frame = 0
+
+	if len(frames) == 0 {
+		p.SetPaintable(nil)
+		return
+	}
+
+	p.SetPaintable(frames[0].paintable)
+	if p.playing {
+		p.startTimer()
+	}
+}
+
+func (p *Picture) startTimer() {
+	if p.timer != 0 || len(p.frames) < 2 {
+		return
+	}
+
+	delay := p.frames[p.frame].delay
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+
+	p.timer = glib.TimeoutAdd(uint(delay.Milliseconds()), func() {
+		p.timer = 0
+		p.frame = (p.frame + 1) % len(p.frames)
+		p.SetPaintable(p.frames[p.frame].paintable)
+		p.startTimer()
+	})
+}
+
+func (p *Picture) stopTimer() {
+	if p.timer != 0 {
+		glib.SourceRemove(p.timer)
+		p.timer = 0
+	}
+}
+
+// EnableAnimation enables animation for the sticker. The controller is
+// returned for the user to determine when to play the animation.
+func (p *Picture) EnableAnimation() *AnimationController {
+	p.ConnectUnmap(func() { (*AnimationController)(p).Stop() })
+	return (*AnimationController)(p)
+}
+
+// AnimationController controls the animation playback of a sticker.
+type AnimationController Picture
+
+// Start starts the animation playback. If the sticker is still loading, then
+// it starts playing once it's loaded.
+func (c *AnimationController) Start() {
+	p := (*Picture)(c)
+	p.playing = true
+	p.startTimer()
+}
+
+// Stop stops the animation playback and goes back to the first frame.
+func (c *AnimationController) Stop() {
+	p := (*Picture)(c)
+	p.playing = false
+	p.stopTimer()
+
+	if len(p.frames) > 0 && p.frame != 0 {
+		p.frame = 0
+		p.SetPaintable(p.frames[0].paintable)
+	}
+}
+
+// OnHover binds the controller to a motion controller attached to the
+// picture. When the user hovers over the sticker, the animation plays.
+func (c *AnimationController) OnHover() {
+	c.ConnectMotion(c.Picture)
+}
+
+// ConnectMotion connects a motion controller to the given widget that will
+// play the animation while it's hovered over.
+func (c *AnimationController) ConnectMotion(w gtk.Widgetter) {
+	motion := gtk.NewEventControllerMotion()
+	motion.ConnectEnter(func(x, y float64) { c.Start() })
+	motion.ConnectLeave(func() { c.Stop() })
+
+	base := gtk.BaseWidget(w)
+	base.AddController(motion)
+}
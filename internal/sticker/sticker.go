@@ -0,0 +1,122 @@
+// Package sticker decodes and plays back animated stickers. APNG stickers are
+// decoded into their composited frames, and Lottie stickers are rasterized in
+// pure Go.
+package sticker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	xdraw "golang.org/x/image/draw"
+)
+
+// maxStickerSize is the maximum size of a sticker file. Discord limits sticker
+// uploads to 512 KB, so this leaves plenty of room for other instances.
+const maxStickerSize = 4 << 20
+
+// Frame is a single frame of an animation.
+type Frame struct {
+	Image *image.RGBA
+	Delay time.Duration
+}
+
+// Animation is a decoded animation. A still image is an animation with a
+// single frame.
+type Animation struct {
+	Frames []Frame
+	Width  int
+	Height int
+}
+
+// URL returns the URL of the sticker's image or Lottie JSON.
+func URL(sticker discord.StickerItem) string {
+	if sticker.FormatType == discord.StickerFormatLottie {
+		return sticker.StickerURLWithType(".json")
+	}
+	return sticker.StickerURLWithType(discord.PNGImage)
+}
+
+// Fetch fetches the sticker at the given URL and decodes it into frames that
+// fit within size pixels. It's meant to be called in a goroutine.
+func Fetch(ctx context.Context, url string, format discord.StickerFormatType, size int) (*Animation, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid URL")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxStickerSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read sticker")
+	}
+	if len(b) > maxStickerSize {
+		return nil, errors.New("sticker is too large")
+	}
+
+	switch format {
+	case discord.StickerFormatLottie:
+		l, err := DecodeLottie(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		w, h := fitSize(l.Width, l.Height, size)
+		return l.Render(ctx, w, h)
+
+	case discord.StickerFormatPNG, discord.StickerFormatAPNG:
+		anim, err := DecodeAPNG(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		anim.resize(size)
+		return anim, nil
+
+	default:
+		return nil, fmt.Errorf("unknown sticker format %d", format)
+	}
+}
+
+// resize shrinks the frames to fit within size pixels. Stickers are usually
+// larger than they're shown, so this saves a lot of memory.
+func (a *Animation) resize(size int) {
+	w, h := fitSize(float64(a.Width), float64(a.Height), size)
+	if w >= a.Width && h >= a.Height {
+		return
+	}
+
+	for i, frame := range a.Frames {
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		xdraw.ApproxBiLinear.Scale(dst, dst.Rect, frame.Image, frame.Image.Rect, xdraw.Src, nil)
+		a.Frames[i].Image = dst
+	}
+
+	a.Width = w
+	a.Height = h
+}
+
+// fitSize scales the size down to fit within a square of the given size while
+// keeping the aspect ratio.
+func fitSize(w, h float64, size int) (int, int) {
+	if w <= 0 || h <= 0 {
+		return size, size
+	}
+
+	ratio := math.Min(float64(size)/w, float64(size)/h)
+	return int(math.Max(1, math.Round(w*ratio))), int(math.Max(1, math.Round(h*ratio)))
+}
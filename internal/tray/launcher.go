@@ -0,0 +1,49 @@
+package tray
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+)
+
+const launcherIface = "com.canonical.Unity.LauncherEntry"
+
+// LauncherEntry publishes a badge on the application's launcher icon using
+// the Unity LauncherEntry API, which docks such as Dash to Dock and KDE's task
+// manager support.
+type LauncherEntry struct {
+	conn *dbus.Conn
+	uri  string
+	path dbus.ObjectPath
+}
+
+// NewLauncherEntry creates a LauncherEntry for the application with the given
+// ID. The ID must match the name of its desktop file. It must be called in a
+// goroutine.
+func NewLauncherEntry(appID string) (*LauncherEntry, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot connect to session bus")
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(appID))
+
+	return &LauncherEntry{
+		conn: conn,
+		uri:  "application://" + appID + ".desktop",
+		path: dbus.ObjectPath(fmt.Sprintf("/com/canonical/unity/launcherentry/%d", hash.Sum32())),
+	}, nil
+}
+
+// Update sets the count shown on the badge. The badge is hidden if count is 0.
+// If urgent is true, the launcher icon asks for attention.
+func (e *LauncherEntry) Update(count int, urgent bool) error {
+	return e.conn.Emit(e.path, launcherIface+".Update", e.uri, map[string]dbus.Variant{
+		"count":         dbus.MakeVariant(int64(count)),
+		"count-visible": dbus.MakeVariant(count > 0),
+		"urgent":        dbus.MakeVariant(urgent),
+	})
+}
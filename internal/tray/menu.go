@@ -0,0 +1,279 @@
+package tray
+
+import (
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/pkg/errors"
+)
+
+const (
+	menuPath  = "/MenuBar"
+	menuIface = "com.canonical.dbusmenu"
+)
+
+const menuIntrospection = `
+<interface name="com.canonical.dbusmenu">
+	<property name="Version" type="u" access="read"/>
+	<property name="TextDirection" type="s" access="read"/>
+	<property name="Status" type="s" access="read"/>
+	<property name="IconThemePath" type="as" access="read"/>
+	<method name="GetLayout">
+		<arg type="i" name="parentId" direction="in"/>
+		<arg type="i" name="recursionDepth" direction="in"/>
+		<arg type="as" name="propertyNames" direction="in"/>
+		<arg type="u" name="revision" direction="out"/>
+		<arg type="(ia{sv}av)" name="layout" direction="out"/>
+	</method>
+	<method name="GetGroupProperties">
+		<arg type="ai" name="ids" direction="in"/>
+		<arg type="as" name="propertyNames" direction="in"/>
+		<arg type="a(ia{sv})" name="properties" direction="out"/>
+	</method>
+	<method name="GetProperty">
+		<arg type="i" name="id" direction="in"/>
+		<arg type="s" name="name" direction="in"/>
+		<arg type="v" name="value" direction="out"/>
+	</method>
+	<method name="Event">
+		<arg type="i" name="id" direction="in"/>
+		<arg type="s" name="eventId" direction="in"/>
+		<arg type="v" name="data" direction="in"/>
+		<arg type="u" name="timestamp" direction="in"/>
+	</method>
+	<method name="EventGroup">
+		<arg type="a(isvu)" name="events" direction="in"/>
+		<arg type="ai" name="idErrors" direction="out"/>
+	</method>
+	<method name="AboutToShow">
+		<arg type="i" name="id" direction="in"/>
+		<arg type="b" name="needUpdate" direction="out"/>
+	</method>
+	<method name="AboutToShowGroup">
+		<arg type="ai" name="ids" direction="in"/>
+		<arg type="ai" name="updatesNeeded" direction="out"/>
+		<arg type="ai" name="idErrors" direction="out"/>
+	</method>
+	<signal name="LayoutUpdated">
+		<arg type="u" name="revision"/>
+		<arg type="i" name="parent"/>
+	</signal>
+</interface>`
+
+// MenuItem is an item in the tray icon's menu. An item without a label is a
+// separator.
+type MenuItem struct {
+	Label string
+	// Radio makes the item a radio button, which is selected if Checked is
+	// true.
+	Radio   bool
+	Checked bool
+	// Activate is called when the item is clicked. It's called in the main
+	// thread.
+	Activate func()
+}
+
+// menu implements com.canonical.dbusmenu. The root item has the ID 0, and
+// the items have their index plus one as their ID.
+type menu struct {
+	conn *dbus.Conn
+
+	mu       sync.Mutex
+	items    []MenuItem
+	revision uint32
+}
+
+// menuLayout is the D-Bus structure of a menu item and its children.
+type menuLayout struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+	Children   []dbus.Variant
+}
+
+type menuProperties struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+}
+
+type menuEvent struct {
+	ID        int32
+	EventID   string
+	Data      dbus.Variant
+	Timestamp uint32
+}
+
+func exportMenu(conn *dbus.Conn) (*menu, error) {
+	m := &menu{conn: conn}
+
+	_, err := prop.Export(conn, menuPath, map[string]map[string]*prop.Prop{
+		menuIface: {
+			"Version":       readOnly(uint32(3)),
+			"TextDirection": readOnly("ltr"),
+			"Status":        readOnly("normal"),
+			"IconThemePath": readOnly([]string{}),
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot export menu properties")
+	}
+
+	if err := conn.Export(m, menuPath, menuIface); err != nil {
+		return nil, errors.Wrap(err, "cannot export menu")
+	}
+
+	conn.Export(introspect.Introspectable(introspectionXML(menuIntrospection)), menuPath, introspect.IntrospectData.Name)
+
+	return m, nil
+}
+
+func (m *menu) setItems(items []MenuItem) {
+	m.mu.Lock()
+	m.items = items
+	m.revision++
+	revision := m.revision
+	m.mu.Unlock()
+
+	m.conn.Emit(menuPath, menuIface+".LayoutUpdated", revision, int32(0))
+}
+
+// properties returns the properties of the item with the given ID. m.mu must
+// be held.
+func (m *menu) properties(id int32) (map[string]dbus.Variant, bool) {
+	if id == 0 {
+		return map[string]dbus.Variant{
+			"children-display": dbus.MakeVariant("submenu"),
+		}, true
+	}
+
+	if id < 1 || int(id) > len(m.items) {
+		return nil, false
+	}
+
+	item := m.items[id-1]
+	if item.Label == "" {
+		return map[string]dbus.Variant{
+			"type": dbus.MakeVariant("separator"),
+		}, true
+	}
+
+	props := map[string]dbus.Variant{
+		"label":   dbus.MakeVariant(item.Label),
+		"enabled": dbus.MakeVariant(item.Activate != nil),
+	}
+
+	if item.Radio {
+		var state int32
+		if item.Checked {
+			state = 1
+		}
+		props["toggle-type"] = dbus.MakeVariant("radio")
+		props["toggle-state"] = dbus.MakeVariant(state)
+	}
+
+	return props, true
+}
+
+// GetLayout implements com.canonical.dbusmenu.
+func (m *menu) GetLayout(parentID, depth int32, names []string) (uint32, menuLayout, *dbus.Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	props, ok := m.properties(parentID)
+	if !ok {
+		return 0, menuLayout{}, dbus.MakeFailedError(errors.New("unknown menu item"))
+	}
+
+	layout := menuLayout{
+		ID:         parentID,
+		Properties: props,
+		Children:   []dbus.Variant{},
+	}
+
+	if parentID == 0 && depth != 0 {
+		for i := range m.items {
+			id := int32(i + 1)
+			props, _ := m.properties(id)
+			layout.Children = append(layout.Children, dbus.MakeVariant(menuLayout{
+				ID:         id,
+				Properties: props,
+				Children:   []dbus.Variant{},
+			}))
+		}
+	}
+
+	return m.revision, layout, nil
+}
+
+// GetGroupProperties implements com.canonical.dbusmenu.
+func (m *menu) GetGroupProperties(ids []int32, names []string) ([]menuProperties, *dbus.Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var group []menuProperties
+	for _, id := range ids {
+		if props, ok := m.properties(id); ok {
+			group = append(group, menuProperties{ID: id, Properties: props})
+		}
+	}
+
+	return group, nil
+}
+
+// GetProperty implements com.canonical.dbusmenu.
+func (m *menu) GetProperty(id int32, name string) (dbus.Variant, *dbus.Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	props, ok := m.properties(id)
+	if !ok {
+		return dbus.Variant{}, dbus.MakeFailedError(errors.New("unknown menu item"))
+	}
+
+	v, ok := props[name]
+	if !ok {
+		return dbus.Variant{}, dbus.MakeFailedError(errors.New("unknown property"))
+	}
+
+	return v, nil
+}
+
+// Event implements com.canonical.dbusmenu.
+func (m *menu) Event(id int32, eventID string, data dbus.Variant, timestamp uint32) *dbus.Error {
+	if eventID != "clicked" {
+		return nil
+	}
+
+	m.mu.Lock()
+	var activate func()
+	if id >= 1 && int(id) <= len(m.items) {
+		activate = m.items[id-1].Activate
+	}
+	m.mu.Unlock()
+
+	if activate != nil {
+		glib.IdleAdd(activate)
+	}
+
+	return nil
+}
+
+// EventGroup implements com.canonical.dbusmenu.
+func (m *menu) EventGroup(events []menuEvent) ([]int32, *dbus.Error) {
+	for _, ev := range events {
+		m.Event(ev.ID, ev.EventID, ev.Data, ev.Timestamp)
+	}
+	return []int32{}, nil
+}
+
+// AboutToShow implements com.canonical.dbusmenu.
+func (m *menu) AboutToShow(id int32) (bool, *dbus.Error) {
+	return false, nil
+}
+
+// AboutToShowGroup implements com.canonical.dbusmenu.
+func (m *menu) AboutToShowGroup(ids []int32) ([]int32, []int32, *dbus.Error) {
+	return []int32{}, []int32{}, nil
+}
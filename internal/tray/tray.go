@@ -0,0 +1,242 @@
+// Package tray implements a StatusNotifierItem, which is the system tray icon
+// on most Linux desktops, and the Unity launcher entry badge, both over D-Bus.
+package tray
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/pkg/errors"
+)
+
+const (
+	itemPath  = "/StatusNotifierItem"
+	itemIface = "org.kde.StatusNotifierItem"
+
+	watcherName  = "org.kde.StatusNotifierWatcher"
+	watcherPath  = "/StatusNotifierWatcher"
+	watcherIface = "org.kde.StatusNotifierWatcher"
+)
+
+const itemIntrospection = `
+<interface name="org.kde.StatusNotifierItem">
+	<property name="Category" type="s" access="read"/>
+	<property name="Id" type="s" access="read"/>
+	<property name="Title" type="s" access="read"/>
+	<property name="Status" type="s" access="read"/>
+	<property name="WindowId" type="i" access="read"/>
+	<property name="IconName" type="s" access="read"/>
+	<property name="AttentionIconName" type="s" access="read"/>
+	<property name="ToolTip" type="(sa(iiay)ss)" access="read"/>
+	<property name="ItemIsMenu" type="b" access="read"/>
+	<property name="Menu" type="o" access="read"/>
+	<method name="Activate">
+		<arg name="x" type="i" direction="in"/>
+		<arg name="y" type="i" direction="in"/>
+	</method>
+	<method name="SecondaryActivate">
+		<arg name="x" type="i" direction="in"/>
+		<arg name="y" type="i" direction="in"/>
+	</method>
+	<method name="ContextMenu">
+		<arg name="x" type="i" direction="in"/>
+		<arg name="y" type="i" direction="in"/>
+	</method>
+	<method name="Scroll">
+		<arg name="delta" type="i" direction="in"/>
+		<arg name="orientation" type="s" direction="in"/>
+	</method>
+	<signal name="NewTitle"/>
+	<signal name="NewIcon"/>
+	<signal name="NewAttentionIcon"/>
+	<signal name="NewToolTip"/>
+	<signal name="NewStatus">
+		<arg name="status" type="s"/>
+	</signal>
+</interface>`
+
+// Options describes the tray icon.
+type Options struct {
+	// ID is the application ID.
+	ID string
+	// Title is the name of the application.
+	Title string
+	// IconName is the icon shown normally.
+	IconName string
+	// AttentionIconName is the icon shown when there are unread mentions.
+	AttentionIconName string
+	// Activate is called when the icon is clicked. It's called in the main
+	// thread.
+	Activate func()
+}
+
+// Item is a tray icon.
+type Item struct {
+	conn  *dbus.Conn
+	name  string
+	opts  Options
+	props *prop.Properties
+	menu  *menu
+
+	mu     sync.Mutex
+	status string
+}
+
+// toolTip is the D-Bus structure of a tooltip.
+type toolTip struct {
+	IconName    string
+	IconPixmaps []iconPixmap
+	Title       string
+	Description string
+}
+
+type iconPixmap struct {
+	Width  int32
+	Height int32
+	Data   []byte
+}
+
+// itemMethods are the methods exported under the StatusNotifierItem
+// interface.
+type itemMethods Item
+
+// New creates a new tray icon and registers it to the StatusNotifierWatcher.
+// It must be called in a goroutine.
+func New(opts Options) (*Item, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot connect to session bus")
+	}
+
+	item := &Item{
+		conn:   conn,
+		name:   fmt.Sprintf("org.kde.StatusNotifierItem-%d-1", os.Getpid()),
+		opts:   opts,
+		status: "Active",
+	}
+
+	item.props, err = prop.Export(conn, itemPath, map[string]map[string]*prop.Prop{
+		itemIface: {
+			"Category":          readOnly("Communications"),
+			"Id":                readOnly(opts.ID),
+			"Title":             readOnly(opts.Title),
+			"Status":            readOnly(item.status),
+			"WindowId":          readOnly(int32(0)),
+			"IconName":          readOnly(opts.IconName),
+			"AttentionIconName": readOnly(opts.AttentionIconName),
+			"ToolTip":           readOnly(toolTip{Title: opts.Title}),
+			"ItemIsMenu":        readOnly(false),
+			"Menu":              readOnly(dbus.ObjectPath(menuPath)),
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot export item properties")
+	}
+
+	if err := conn.Export((*itemMethods)(item), itemPath, itemIface); err != nil {
+		return nil, errors.Wrap(err, "cannot export item")
+	}
+
+	conn.Export(introspect.Introspectable(introspectionXML(itemIntrospection)), itemPath, introspect.IntrospectData.Name)
+
+	item.menu, err = exportMenu(conn)
+	if err != nil {
+		item.unexport()
+		return nil, err
+	}
+
+	if _, err := conn.RequestName(item.name, dbus.NameFlagDoNotQueue); err != nil {
+		item.unexport()
+		return nil, errors.Wrap(err, "cannot request bus name")
+	}
+
+	err = conn.Object(watcherName, watcherPath).Call(watcherIface+".RegisterStatusNotifierItem", 0, item.name).Err
+	if err != nil {
+		item.Close()
+		return nil, errors.Wrap(err, "cannot register to the status notifier watcher")
+	}
+
+	return item, nil
+}
+
+func readOnly(v interface{}) *prop.Prop {
+	return &prop.Prop{Value: v, Emit: prop.EmitFalse}
+}
+
+func introspectionXML(iface string) string {
+	return `<node>` + introspect.IntrospectDataString + iface + `</node>`
+}
+
+// Close removes the tray icon.
+func (item *Item) Close() {
+	item.conn.ReleaseName(item.name)
+	item.unexport()
+}
+
+func (item *Item) unexport() {
+	item.conn.Export(nil, itemPath, itemIface)
+	item.conn.Export(nil, itemPath, "org.freedesktop.DBus.Properties")
+	item.conn.Export(nil, itemPath, introspect.IntrospectData.Name)
+	item.conn.Export(nil, menuPath, menuIface)
+	item.conn.Export(nil, menuPath, "org.freedesktop.DBus.Properties")
+	item.conn.Export(nil, menuPath, introspect.IntrospectData.Name)
+}
+
+// SetState updates the tooltip and, if attention is true, switches to the
+// attention icon.
+func (item *Item) SetState(attention bool, description string) {
+	status := "Active"
+	if attention {
+		status = "NeedsAttention"
+	}
+
+	item.props.SetMust(itemIface, "ToolTip", toolTip{
+		Title:       item.opts.Title,
+		Description: description,
+	})
+	item.conn.Emit(itemPath, itemIface+".NewToolTip")
+
+	item.mu.Lock()
+	changed := item.status != status
+	item.status = status
+	item.mu.Unlock()
+
+	if changed {
+		item.props.SetMust(itemIface, "Status", status)
+		item.conn.Emit(itemPath, itemIface+".NewStatus", status)
+	}
+}
+
+// SetMenu replaces the items in the tray icon's menu.
+func (item *Item) SetMenu(items []MenuItem) {
+	item.menu.setItems(items)
+}
+
+// Activate implements org.kde.StatusNotifierItem.
+func (item *itemMethods) Activate(x, y int32) *dbus.Error {
+	if item.opts.Activate != nil {
+		glib.IdleAdd(item.opts.Activate)
+	}
+	return nil
+}
+
+// SecondaryActivate implements org.kde.StatusNotifierItem.
+func (item *itemMethods) SecondaryActivate(x, y int32) *dbus.Error {
+	return item.Activate(x, y)
+}
+
+// ContextMenu implements org.kde.StatusNotifierItem. The host shows the menu
+// exported at the Menu path instead.
+func (item *itemMethods) ContextMenu(x, y int32) *dbus.Error {
+	return nil
+}
+
+// Scroll implements org.kde.StatusNotifierItem.
+func (item *itemMethods) Scroll(delta int32, orientation string) *dbus.Error {
+	return nil
+}
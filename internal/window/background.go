@@ -0,0 +1,199 @@
+package window
+
+import (
+	"log"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/tray"
+)
+
+var runInBackground = prefs.NewBool(false, prefs.PropMeta{
+	Name:    "Run in Background",
+	Section: "Application",
+	Description: "Keep running and receiving notifications after the window is closed. " +
+		"An icon is shown in the system tray to reopen or quit the application.",
+})
+
+// background manages the tray icon and the launcher badge, which show the
+// unread state while the window is hidden.
+type background struct {
+	w     *Window
+	state *gtkcord.State
+
+	tray     *tray.Item
+	starting bool
+	launcher *tray.LauncherEntry
+	pending  glib.SourceHandle
+}
+
+func newBackground(w *Window) *background {
+	bg := &background{w: w}
+
+	gtkutil.Async(w.ctx, func() func() {
+		launcher, err := tray.NewLauncherEntry(app.FromContext(w.ctx).ID())
+		if err != nil {
+			log.Println("cannot create launcher entry:", err)
+			return nil
+		}
+		return func() {
+			bg.launcher = launcher
+			bg.QueueUpdate()
+		}
+	})
+
+	runInBackground.SubscribeInit(bg.updateTray)
+	return bg
+}
+
+// SetState sets the state to show the unread state of.
+func (bg *background) SetState(state *gtkcord.State) {
+	bg.state = state
+	bg.QueueUpdate()
+}
+
+// HideWindow hides the window instead of closing it if the application should
+// keep running in the background. It returns false if the window should be
+// closed. The window is never hidden without a tray icon, since there would be
+// no way to get it back.
+func (bg *background) HideWindow() bool {
+	if !runInBackground.Value() || bg.state == nil {
+		return false
+	}
+
+	if bg.tray == nil {
+		log.Println("no tray icon to reopen the window from, closing instead of hiding")
+		return false
+	}
+
+	bg.w.SetVisible(false)
+	return true
+}
+
+func (bg *background) updateTray() {
+	if !runInBackground.Value() {
+		if bg.tray != nil {
+			go bg.tray.Close()
+			bg.tray = nil
+		}
+		// Don't leave the user without a way back to the window.
+		if !bg.w.Visible() {
+			bg.w.Present()
+		}
+		return
+	}
+
+	if bg.tray != nil || bg.starting {
+		return
+	}
+	bg.starting = true
+
+	application := app.FromContext(bg.w.ctx)
+	opts := tray.Options{
+		ID:                application.ID(),
+		Title:             "gtkcord4",
+		IconName:          application.ID(),
+		AttentionIconName: "mail-unread",
+		Activate:          bg.w.Present,
+	}
+
+	gtkutil.Async(bg.w.ctx, func() func() {
+		item, err := tray.New(opts)
+		return func() {
+			bg.starting = false
+			if err != nil {
+				log.Println("cannot create tray icon:", err)
+				return
+			}
+			if !runInBackground.Value() {
+				go item.Close()
+				return
+			}
+			bg.tray = item
+			bg.QueueUpdate()
+		}
+	})
+}
+
+// QueueUpdate updates the unread state shortly. Updates are batched, since
+// counting the unreads goes through every channel.
+func (bg *background) QueueUpdate() {
+	if bg.pending != 0 {
+		return
+	}
+	bg.pending = glib.TimeoutAdd(500, func() {
+		bg.pending = 0
+		bg.update()
+	})
+}
+
+func (bg *background) update() {
+	if bg.state == nil {
+		return
+	}
+
+	counts := bg.state.UnreadCounts()
+	attention := counts.Mentions > 0
+
+	if launcher := bg.launcher; launcher != nil {
+		go func() {
+			if err := launcher.Update(counts.Unread, attention); err != nil {
+				log.Println("cannot update launcher entry:", err)
+			}
+		}()
+	}
+
+	if item := bg.tray; item != nil {
+		description := locale.Sprintf("%d unread channels, %d mentions", counts.Unread, counts.Mentions)
+		menu := bg.menu()
+		go func() {
+			item.SetState(attention, description)
+			item.SetMenu(menu)
+		}()
+	}
+}
+
+func (bg *background) menu() []tray.MenuItem {
+	current := bg.state.Status()
+	status := func(label string, status discord.Status) tray.MenuItem {
+		return tray.MenuItem{
+			Label:    label,
+			Radio:    true,
+			Checked:  current == status,
+			Activate: func() { bg.setStatus(status) },
+		}
+	}
+
+	return []tray.MenuItem{
+		{Label: locale.Get("Open"), Activate: bg.w.Present},
+		{},
+		status(locale.Get("Online"), discord.OnlineStatus),
+		status(locale.Get("Idle"), discord.IdleStatus),
+		status(locale.Get("Do Not Disturb"), discord.DoNotDisturbStatus),
+		status(locale.Get("Invisible"), discord.InvisibleStatus),
+		{},
+		{Label: locale.Get("Quit"), Activate: app.FromContext(bg.w.ctx).Quit},
+	}
+}
+
+func (bg *background) setStatus(status discord.Status) {
+	state := bg.state
+	ctx := bg.w.ctx
+
+	gtkutil.Async(ctx, func() func() {
+		err := state.SetStatus(status, nil)
+		return func() {
+			if err != nil {
+				app.Error(ctx, errors.Wrap(err, "invalid status"))
+				return
+			}
+			bg.QueueUpdate()
+		}
+	})
+}
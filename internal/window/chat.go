@@ -11,10 +11,13 @@ import (
 	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/customstatus"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/downloads"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/message"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/sidebar"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/window/backbutton"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/window/inbox"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/window/quickswitcher"
 	"github.com/pkg/errors"
 )
@@ -26,6 +29,7 @@ type ChatPage struct {
 	RightChild *gtk.Stack
 
 	prevView gtk.Widgetter
+	inbox    *inbox.Store
 
 	ctx         context.Context
 	placeholder gtk.Widgetter
@@ -49,7 +53,10 @@ var chatPageCSS = cssutil.Applier("window-chatpage", `
 `)
 
 func NewChatPage(ctx context.Context) *ChatPage {
+	ctx = downloads.WithManager(ctx, downloads.NewManager(ctx))
+
 	p := ChatPage{ctx: ctx}
+	p.inbox = inbox.NewStore(ctx)
 	p.Left = sidebar.NewSidebar(ctx, (*sidebarChatPage)(&p), &p)
 	p.Left.SetHAlign(gtk.AlignStart)
 	p.Left.SetSizeRequest(225, -1)
@@ -68,6 +75,7 @@ func NewChatPage(ctx context.Context) *ChatPage {
 	rightHeaderBox.AddCSSClass("right-header")
 	rightHeaderBox.Append(back)
 	rightHeaderBox.Append(p.RightLabel)
+	rightHeaderBox.Append(downloads.NewButton(ctx))
 	rightHeaderBox.Append(gtk.NewWindowControls(gtk.PackEnd))
 
 	rightHeader := gtk.NewWindowHandle()
@@ -130,11 +138,13 @@ func NewChatPage(ctx context.Context) *ChatPage {
 	}
 
 	gtkutil.BindActionMap(p, map[string]func(){
-		"discord.show-qs":       p.ShowQuickSwitcher,
-		"discord.set-online":    func() { setStatus(discord.OnlineStatus) },
-		"discord.set-idle":      func() { setStatus(discord.IdleStatus) },
-		"discord.set-dnd":       func() { setStatus(discord.DoNotDisturbStatus) },
-		"discord.set-invisible": func() { setStatus(discord.InvisibleStatus) },
+		"discord.show-qs":           p.ShowQuickSwitcher,
+		"discord.show-inbox":        p.ShowInbox,
+		"discord.set-online":        func() { setStatus(discord.OnlineStatus) },
+		"discord.set-idle":          func() { setStatus(discord.IdleStatus) },
+		"discord.set-dnd":           func() { setStatus(discord.DoNotDisturbStatus) },
+		"discord.set-invisible":     func() { setStatus(discord.InvisibleStatus) },
+		"discord.set-custom-status": func() { customstatus.ShowDialog(ctx) },
 	})
 
 	chatPageCSS(p)
@@ -154,6 +164,11 @@ func (p *ChatPage) ShowQuickSwitcher() {
 	quickswitcher.ShowDialog(p.ctx, (*quickSwitcherChatPage)(p))
 }
 
+// ShowInbox shows the inbox dialog listing the user's recent mentions.
+func (p *ChatPage) ShowInbox() {
+	inbox.ShowDialog(p.ctx, p.inbox, p)
+}
+
 // SwitchToPlaceholder switches to the empty placeholder view.
 func (p *ChatPage) SwitchToPlaceholder() {
 	win := app.WindowFromContext(p.ctx)
@@ -182,6 +197,12 @@ func (p *ChatPage) OpenDMs() {
 	p.Left.OpenDMs()
 }
 
+// OpenUnreads opens the overview of unread channels.
+func (p *ChatPage) OpenUnreads() {
+	p.SwitchToPlaceholder()
+	p.Left.OpenUnreads()
+}
+
 // OpenChannel opens the channel with the given ID. Use this method to direct
 // the user to a new channel when they request to, e.g. through a notification.
 func (p *ChatPage) OpenChannel(chID discord.ChannelID) {
@@ -197,6 +218,16 @@ func (p *ChatPage) OpenChannel(chID discord.ChannelID) {
 	p.switchTo(view)
 }
 
+// OpenMessage opens the channel with the given ID and highlights the message
+// with the given ID once it's loaded.
+func (p *ChatPage) OpenMessage(chID discord.ChannelID, msgID discord.MessageID) {
+	p.OpenChannel(chID)
+
+	if view, ok := p.prevView.(*message.View); ok && msgID.IsValid() {
+		view.HighlightMessage(msgID)
+	}
+}
+
 // OpenGuild opens the guild with the given ID.
 func (p *ChatPage) OpenGuild(guildID discord.GuildID) {
 	p.SwitchToPlaceholder()
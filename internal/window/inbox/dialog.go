@@ -0,0 +1,68 @@
+package inbox
+
+import (
+	"context"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+)
+
+// Dialog is a dialog showing the inbox.
+type Dialog struct {
+	*gtk.Dialog
+	View   *View
+	opener Opener
+}
+
+const dialogFlags = 0 |
+	gtk.DialogDestroyWithParent |
+	gtk.DialogModal |
+	gtk.DialogUseHeaderBar
+
+// ShowDialog shows a new inbox dialog.
+func ShowDialog(ctx context.Context, store *Store, opener Opener) {
+	d := NewDialog(ctx, store, opener)
+	d.Show()
+}
+
+// NewDialog creates a new inbox dialog.
+func NewDialog(ctx context.Context, store *Store, opener Opener) *Dialog {
+	d := Dialog{opener: opener}
+	d.View = NewView(ctx, store, (*dialogOpener)(&d))
+
+	d.Dialog = gtk.NewDialogWithFlags(
+		app.FromContext(ctx).SuffixedTitle("Inbox"),
+		app.GTKWindowFromContext(ctx),
+		dialogFlags,
+	)
+	d.Dialog.SetHideOnClose(false)
+	d.Dialog.SetDefaultSize(450, 500)
+	d.Dialog.SetChild(d.View)
+
+	esc := gtk.NewEventControllerKey()
+	esc.SetName("dialog-escape")
+	esc.ConnectKeyPressed(func(val, _ uint, state gdk.ModifierType) bool {
+		switch val {
+		case gdk.KEY_Escape:
+			d.Dialog.Close()
+			return true
+		}
+		return false
+	})
+	d.Dialog.AddController(esc)
+
+	if app.IsDevel() {
+		d.Dialog.AddCSSClass("devel")
+	}
+
+	return &d
+}
+
+type dialogOpener Dialog
+
+func (d *dialogOpener) OpenMessage(chID discord.ChannelID, msgID discord.MessageID) {
+	(*Dialog)(d).opener.OpenMessage(chID, msgID)
+	(*Dialog)(d).Close()
+}
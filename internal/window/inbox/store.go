@@ -0,0 +1,312 @@
+// Package inbox contains the inbox panel listing recent mentions.
+package inbox
+
+import (
+	"context"
+	"sort"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/ningen/v3"
+	"github.com/pkg/errors"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// maxMentions is the maximum number of mentions that the store keeps.
+const maxMentions = 200
+
+// maxDismissed is the maximum number of dismissed mentions that are persisted.
+// Older ones are dropped, since they're long gone from the recent mentions by
+// then.
+const maxDismissed = 1000
+
+// dismissedStateKey is the key of the dismissed mention IDs inside the inbox
+// state.
+const dismissedStateKey = "dismissed"
+
+// MentionKind describes how a message mentioned the current user.
+type MentionKind uint8
+
+const (
+	// UserMention is a message that mentions the user directly.
+	UserMention MentionKind = iota
+	// RoleMention is a message that mentions one of the user's roles.
+	RoleMention
+	// EveryoneMention is a message that mentions @everyone or @here.
+	EveryoneMention
+)
+
+// Mention is a single message inside the inbox.
+type Mention struct {
+	discord.Message
+	Kind MentionKind
+}
+
+// Store keeps track of the messages that mentioned the current user. It
+// collects mentions seen through the gateway for as long as the session lives
+// and merges in mentions fetched from the API. Store must only be used from
+// the main thread.
+type Store struct {
+	ctx       context.Context
+	mentions  []Mention // newest first
+	dismissed map[discord.MessageID]struct{}
+	// loaded is true once the saved dismissed IDs are merged into dismissed.
+	// They're only saved after that, so they don't overwrite the saved ones.
+	loaded    bool
+	handlers  map[int]func()
+	handlerID int
+}
+
+// NewStore creates a new Store and starts collecting mentions from the
+// gateway.
+func NewStore(ctx context.Context) *Store {
+	s := &Store{
+		ctx:       ctx,
+		dismissed: make(map[discord.MessageID]struct{}),
+		handlers:  make(map[int]func()),
+	}
+
+	state := gtkcord.FromContext(ctx)
+	state.AddSyncHandler(func(ev gateway.Event) {
+		switch ev := ev.(type) {
+		case *gateway.MessageCreateEvent:
+			if !state.MessageMentions(&ev.Message).Has(ningen.MessageMentions) {
+				return
+			}
+			msg := ev.Message
+			glib.IdleAdd(func() { s.Merge([]discord.Message{msg}) })
+
+		case *gateway.MessageUpdateEvent:
+			id := ev.ID
+			glib.IdleAdd(func() { s.update(id) })
+
+		case *gateway.MessageDeleteEvent:
+			id := ev.ID
+			glib.IdleAdd(func() { s.remove(id) })
+		}
+	})
+
+	gtkutil.Async(ctx, func() func() {
+		var dismissed []discord.MessageID
+
+		cfg := app.AcquireState(ctx, "inbox-state")
+		cfg.Get(dismissedStateKey, &dismissed)
+
+		return func() {
+			// Mentions dismissed while loading are kept and saved along
+			// with the loaded ones.
+			pending := len(s.dismissed) > 0
+
+			for _, id := range dismissed {
+				s.dismissed[id] = struct{}{}
+			}
+			s.loaded = true
+
+			if pending {
+				s.saveDismissed()
+			}
+			s.filterDismissed()
+		}
+	})
+
+	return s
+}
+
+// Mentions returns the list of mentions from newest to oldest. The returned
+// slice must not be modified.
+func (s *Store) Mentions() []Mention {
+	return s.mentions
+}
+
+// OnUpdate adds a callback that's called every time the list of mentions
+// changes. Call the returned function to remove the callback.
+func (s *Store) OnUpdate(f func()) (remove func()) {
+	id := s.handlerID
+	s.handlerID++
+	s.handlers[id] = f
+	return func() { delete(s.handlers, id) }
+}
+
+// Merge adds the given messages into the store. Messages that are already in
+// the store or that were dismissed are ignored.
+func (s *Store) Merge(msgs []discord.Message) {
+	known := make(map[discord.MessageID]struct{}, len(s.mentions))
+	for _, m := range s.mentions {
+		known[m.ID] = struct{}{}
+	}
+
+	var changed bool
+	for _, msg := range msgs {
+		if _, ok := known[msg.ID]; ok {
+			continue
+		}
+		if _, ok := s.dismissed[msg.ID]; ok {
+			continue
+		}
+
+		known[msg.ID] = struct{}{}
+		s.mentions = append(s.mentions, s.newMention(msg))
+		changed = true
+	}
+
+	if !changed {
+		return
+	}
+
+	sort.Slice(s.mentions, func(i, j int) bool {
+		return s.mentions[i].ID > s.mentions[j].ID
+	})
+
+	if len(s.mentions) > maxMentions {
+		s.mentions = s.mentions[:maxMentions]
+	}
+
+	s.invalidate()
+}
+
+// Dismiss removes the mention with the given ID from the store and from the
+// user's recent mentions on the server. Dismissed mentions are remembered
+// across restarts, since instances don't always delete them.
+func (s *Store) Dismiss(id discord.MessageID) {
+	s.dismissed[id] = struct{}{}
+	s.saveDismissed()
+	s.remove(id)
+
+	state := gtkcord.FromContext(s.ctx)
+	go func() {
+		if err := state.DeleteRecentMention(id); err != nil {
+			app.Error(s.ctx, errors.Wrap(err, "cannot dismiss mention"))
+		}
+	}()
+}
+
+// saveDismissed persists the newest dismissed mention IDs. It does nothing
+// until the saved ones are loaded.
+func (s *Store) saveDismissed() {
+	if !s.loaded {
+		return
+	}
+
+	dismissed := make([]discord.MessageID, 0, len(s.dismissed))
+	for id := range s.dismissed {
+		dismissed = append(dismissed, id)
+	}
+
+	sort.Slice(dismissed, func(i, j int) bool {
+		return dismissed[i] > dismissed[j]
+	})
+
+	if len(dismissed) > maxDismissed {
+		for _, id := range dismissed[maxDismissed:] {
+			delete(s.dismissed, id)
+		}
+		dismissed = dismissed[:maxDismissed]
+	}
+
+	cfg := app.AcquireState(s.ctx, "inbox-state")
+	cfg.Set(dismissedStateKey, dismissed)
+}
+
+// filterDismissed removes the dismissed mentions that were merged in before
+// the dismissed IDs were loaded.
+func (s *Store) filterDismissed() {
+	mentions := s.mentions[:0]
+	for _, m := range s.mentions {
+		if _, ok := s.dismissed[m.ID]; !ok {
+			mentions = append(mentions, m)
+		}
+	}
+
+	if len(mentions) == len(s.mentions) {
+		return
+	}
+
+	s.mentions = mentions
+	s.invalidate()
+}
+
+func (s *Store) newMention(msg discord.Message) Mention {
+	state := gtkcord.FromContext(s.ctx)
+
+	// Messages from the recent mentions endpoint don't always have the guild
+	// ID, so we fill it in ourselves. The guild filter relies on it.
+	if !msg.GuildID.IsValid() {
+		if ch, _ := state.Cabinet.Channel(msg.ChannelID); ch != nil {
+			msg.GuildID = ch.GuildID
+		}
+	}
+
+	return Mention{
+		Message: msg,
+		Kind:    mentionKind(state, &msg),
+	}
+}
+
+func mentionKind(state *gtkcord.State, msg *discord.Message) MentionKind {
+	me, _ := state.Cabinet.Me()
+	if me == nil {
+		return UserMention
+	}
+
+	for _, user := range msg.Mentions {
+		if user.ID == me.ID {
+			return UserMention
+		}
+	}
+
+	if msg.GuildID.IsValid() && len(msg.MentionRoleIDs) > 0 {
+		member, _ := state.Cabinet.Member(msg.GuildID, me.ID)
+		if member != nil {
+			for _, mentioned := range msg.MentionRoleIDs {
+				for _, role := range member.RoleIDs {
+					if role == mentioned {
+						return RoleMention
+					}
+				}
+			}
+		}
+	}
+
+	if msg.MentionEveryone {
+		return EveryoneMention
+	}
+
+	return UserMention
+}
+
+func (s *Store) update(id discord.MessageID) {
+	for i, m := range s.mentions {
+		if m.ID != id {
+			continue
+		}
+
+		state := gtkcord.FromContext(s.ctx)
+
+		msg, _ := state.Cabinet.Message(m.ChannelID, id)
+		if msg != nil {
+			s.mentions[i] = s.newMention(*msg)
+			s.invalidate()
+		}
+
+		return
+	}
+}
+
+func (s *Store) remove(id discord.MessageID) {
+	for i, m := range s.mentions {
+		if m.ID == id {
+			s.mentions = append(s.mentions[:i], s.mentions[i+1:]...)
+			s.invalidate()
+			return
+		}
+	}
+}
+
+func (s *Store) invalidate() {
+	for _, f := range s.handlers {
+		f()
+	}
+}
@@ -0,0 +1,311 @@
+package inbox
+
+import (
+	"context"
+	"html"
+	"log"
+
+	"github.com/diamondburned/adaptive"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/components/onlineimage"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"github.com/thekrafter/arikawa-spacebar/v3/discord"
+	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+)
+
+// Opener is the interface used by the inbox to jump to a mentioned message.
+type Opener interface {
+	// OpenMessage opens the channel with the given ID and highlights the given
+	// message.
+	OpenMessage(discord.ChannelID, discord.MessageID)
+}
+
+// View is the inbox view that lists the messages that mentioned the user.
+type View struct {
+	*gtk.Box
+	Filters *gtk.Box
+	Page    *adaptive.LoadablePage
+	Scroll  *gtk.ScrolledWindow
+	List    *gtk.ListBox
+
+	everyone *gtk.ToggleButton
+	roles    *gtk.ToggleButton
+	guilds   *gtk.DropDown
+	guildIDs []discord.GuildID // parallel to guilds, 0 is all guilds
+	shown    []Mention         // parallel to List's rows
+
+	ctx    context.Context
+	store  *Store
+	opener Opener
+}
+
+var viewCSS = cssutil.Applier("inbox-view", `
+	.inbox-filters {
+		padding: 6px;
+	}
+	.inbox-list {
+		background: none;
+	}
+	.inbox-mention {
+		padding: 6px;
+	}
+	.inbox-mention-avatar {
+		margin-right: 8px;
+	}
+	.inbox-mention-header {
+		font-size: 0.9em;
+	}
+	.inbox-mention-time {
+		font-size: 0.85em;
+		color: alpha(@theme_fg_color, 0.55);
+	}
+	.inbox-mention-preview {
+		margin-top: 2px;
+	}
+`)
+
+// NewView creates a new inbox view.
+func NewView(ctx context.Context, store *Store, opener Opener) *View {
+	v := View{
+		ctx:    ctx,
+		store:  store,
+		opener: opener,
+	}
+
+	v.everyone = gtk.NewToggleButtonWithLabel("@everyone")
+	v.everyone.SetTooltipText("Include @everyone and @here mentions")
+	v.everyone.SetActive(true)
+	v.everyone.ConnectToggled(v.Refresh)
+
+	v.roles = gtk.NewToggleButtonWithLabel("Roles")
+	v.roles.SetTooltipText("Include role mentions")
+	v.roles.SetActive(true)
+	v.roles.ConnectToggled(v.Refresh)
+
+	state := gtkcord.FromContext(ctx)
+
+	guildNames := []string{"All Servers"}
+	v.guildIDs = []discord.GuildID{0}
+
+	guilds, _ := state.Cabinet.Guilds()
+	for _, guild := range guilds {
+		guildNames = append(guildNames, guild.Name)
+		v.guildIDs = append(v.guildIDs, guild.ID)
+	}
+
+	v.guilds = gtk.NewDropDownFromStrings(guildNames)
+	v.guilds.SetHExpand(true)
+	v.guilds.SetHAlign(gtk.AlignEnd)
+	v.guilds.NotifyProperty("selected", v.Refresh)
+
+	v.Filters = gtk.NewBox(gtk.OrientationHorizontal, 4)
+	v.Filters.AddCSSClass("inbox-filters")
+	v.Filters.Append(v.everyone)
+	v.Filters.Append(v.roles)
+	v.Filters.Append(v.guilds)
+
+	v.List = gtk.NewListBox()
+	v.List.AddCSSClass("inbox-list")
+	v.List.SetSelectionMode(gtk.SelectionNone)
+	v.List.SetActivateOnSingleClick(true)
+	v.List.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		if i := row.Index(); i >= 0 && i < len(v.shown) {
+			v.opener.OpenMessage(v.shown[i].ChannelID, v.shown[i].ID)
+		}
+	})
+
+	v.Scroll = gtk.NewScrolledWindow()
+	v.Scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	v.Scroll.SetVExpand(true)
+	v.Scroll.SetChild(v.List)
+
+	v.Page = adaptive.NewLoadablePage()
+	v.Page.SetTransitionDuration(125)
+	v.Page.SetVExpand(true)
+	v.Page.SetChild(v.Scroll)
+	v.Page.SetRetryFunc(v.Refresh)
+
+	v.Box = gtk.NewBox(gtk.OrientationVertical, 0)
+	v.Box.Append(v.Filters)
+	v.Box.Append(gtk.NewSeparator(gtk.OrientationHorizontal))
+	v.Box.Append(v.Page)
+
+	var removeUpdate func()
+	v.ConnectMap(func() {
+		removeUpdate = store.OnUpdate(v.invalidate)
+		v.Refresh()
+	})
+	v.ConnectUnmap(func() {
+		if removeUpdate != nil {
+			removeUpdate()
+			removeUpdate = nil
+		}
+	})
+
+	viewCSS(v)
+	return &v
+}
+
+func (v *View) guildID() discord.GuildID {
+	selected := int(v.guilds.Selected())
+	if selected >= len(v.guildIDs) {
+		return 0
+	}
+	return v.guildIDs[selected]
+}
+
+// Refresh fetches the recent mentions from the server using the current
+// filters and shows them alongside the mentions already in the store.
+func (v *View) Refresh() {
+	ctx := v.Page.SetCancellableLoading(v.ctx)
+
+	data := gtkcord.RecentMentionsData{
+		Limit:    50,
+		Roles:    v.roles.Active(),
+		Everyone: v.everyone.Active(),
+		GuildID:  v.guildID(),
+	}
+
+	state := gtkcord.FromContext(v.ctx)
+
+	gtkutil.Async(ctx, func() func() {
+		msgs, err := state.RecentMentions(data)
+		return func() {
+			if err != nil {
+				// Not every instance implements this endpoint, so only show
+				// the error if we have nothing else to show.
+				log.Println("cannot fetch recent mentions:", err)
+				if len(v.store.Mentions()) == 0 {
+					v.Page.SetError(err)
+					return
+				}
+			}
+
+			v.store.Merge(msgs)
+			v.invalidate()
+		}
+	})
+}
+
+// filter returns true if the mention should be shown using the current
+// filters.
+func (v *View) filter(m *Mention) bool {
+	if guildID := v.guildID(); guildID.IsValid() && m.GuildID != guildID {
+		return false
+	}
+
+	switch m.Kind {
+	case RoleMention:
+		return v.roles.Active()
+	case EveryoneMention:
+		return v.everyone.Active()
+	default:
+		return true
+	}
+}
+
+func (v *View) invalidate() {
+	gtkutil.RemoveChildren(v.List)
+	v.shown = v.shown[:0]
+
+	for i := range v.store.Mentions() {
+		m := &v.store.Mentions()[i]
+		if !v.filter(m) {
+			continue
+		}
+		v.List.Append(v.newMentionRow(m))
+		v.shown = append(v.shown, *m)
+	}
+
+	if len(v.shown) == 0 {
+		status := adaptive.NewStatusPage()
+		status.SetIconName("mail-read-symbolic")
+		status.SetTitle("No Mentions")
+		status.SetDescriptionText("Messages that mention you will show up here.")
+		status.Icon.SetOpacity(0.45)
+		v.Page.SetChild(status)
+		return
+	}
+
+	v.Page.SetChild(v.Scroll)
+}
+
+func (v *View) newMentionRow(m *Mention) *gtk.ListBoxRow {
+	state := gtkcord.FromContext(v.ctx)
+
+	chID := m.ChannelID
+	msgID := m.ID
+
+	avatar := onlineimage.NewAvatar(v.ctx, imgutil.HTTPProvider, gtkcord.ChannelIconSize)
+	avatar.AddCSSClass("inbox-mention-avatar")
+	avatar.SetVAlign(gtk.AlignStart)
+	avatar.SetInitials(m.Author.Username)
+	avatar.SetFromURL(gtkcord.InjectAvatarSize(m.Author.AvatarURL()))
+
+	location := html.EscapeString(gtkcord.ChannelNameFromID(v.ctx, chID))
+	if m.GuildID.IsValid() {
+		if guild, _ := state.Cabinet.Guild(m.GuildID); guild != nil {
+			location += " (" + html.EscapeString(guild.Name) + ")"
+		}
+	}
+
+	author := gtk.NewLabel("")
+	author.AddCSSClass("inbox-mention-header")
+	author.SetXAlign(0)
+	author.SetHExpand(true)
+	author.SetEllipsize(pango.EllipsizeEnd)
+	author.SetMarkup(
+		state.AuthorMarkup(&gateway.MessageCreateEvent{Message: m.Message}) +
+			` <span alpha="75%">` + location + `</span>`,
+	)
+	author.SetTooltipText(gtkcord.ChannelNameFromID(v.ctx, chID))
+
+	timestamp := gtk.NewLabel(gtkcord.FormatTimeAgo(m.Timestamp.Time()))
+	timestamp.AddCSSClass("inbox-mention-time")
+	timestamp.SetTooltipText(gtkcord.FormatTime(m.Timestamp.Time(), true))
+
+	header := gtk.NewBox(gtk.OrientationHorizontal, 4)
+	header.Append(author)
+	header.Append(timestamp)
+
+	preview := gtk.NewLabel(state.MessagePreview(&m.Message))
+	preview.AddCSSClass("inbox-mention-preview")
+	preview.SetXAlign(0)
+	preview.SetWrap(true)
+	preview.SetWrapMode(pango.WrapWordChar)
+	preview.SetLines(3)
+	preview.SetEllipsize(pango.EllipsizeEnd)
+
+	content := gtk.NewBox(gtk.OrientationVertical, 0)
+	content.SetHExpand(true)
+	content.Append(header)
+	content.Append(preview)
+
+	jump := gtk.NewButtonFromIconName("mail-reply-sender-symbolic")
+	jump.AddCSSClass("flat")
+	jump.SetVAlign(gtk.AlignStart)
+	jump.SetTooltipText("Jump")
+	jump.ConnectClicked(func() { v.opener.OpenMessage(chID, msgID) })
+
+	dismiss := gtk.NewButtonFromIconName("window-close-symbolic")
+	dismiss.AddCSSClass("flat")
+	dismiss.SetVAlign(gtk.AlignStart)
+	dismiss.SetTooltipText("Dismiss")
+	dismiss.ConnectClicked(func() { v.store.Dismiss(msgID) })
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	box.AddCSSClass("inbox-mention")
+	box.Append(avatar)
+	box.Append(content)
+	box.Append(jump)
+	box.Append(dismiss)
+
+	row := gtk.NewListBoxRow()
+	row.SetChild(box)
+
+	return row
+}
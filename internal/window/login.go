@@ -1,16 +1,17 @@
 package window
 
 import (
-	"fmt"
 	"log"
 
-	"github.com/thekrafter/arikawa-spacebar/v3/discord"
 	"github.com/thekrafter/arikawa-spacebar/v3/gateway"
 	"github.com/thekrafter/arikawa-spacebar/v3/utils/ws"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
-	"github.com/diamondburned/gotkit/app/notify"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/activity"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/idle"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/notifications"
 	"github.com/diamondburned/ningen/v3"
+	"github.com/diamondburned/ningen/v3/states/read"
 )
 
 type loginWindow Window
@@ -19,7 +20,19 @@ func (w *loginWindow) Hook(state *gtkcord.State) {
 	w.ctx = gtkcord.InjectState(w.ctx, state)
 	w.Reconnecting()
 
+	// Services that belong to the session stop once it ends, so logging in
+	// again doesn't start them twice.
+	session := (*Window)(w).startSession()
+	go func() {
+		<-session.Done()
+		state.EndSession()
+	}()
+
 	var reconnecting glib.SourceHandle
+	notifier := notifications.NewNotifier(session)
+	idle.Start(session)
+	activity.Start(session)
+	w.bg.SetState(state)
 
 	// When the websocket closes, the screen must be changed to a busy one. The
 	// websocket may close if it's disconnected unexpectedly.
@@ -58,6 +71,8 @@ func (w *loginWindow) Hook(state *gtkcord.State) {
 			})
 
 		case *gateway.ReadyEvent:
+			w.bg.QueueUpdate()
+
 			if ev.UserSettings != nil {
 				switch ev.UserSettings.Theme {
 				case "dark":
@@ -68,32 +83,29 @@ func (w *loginWindow) Hook(state *gtkcord.State) {
 			}
 
 		case *gateway.MessageCreateEvent:
-			mentions := state.MessageMentions(&ev.Message)
-			if mentions == 0 {
+			w.bg.QueueUpdate()
+
+			if !state.MessageNotifies(&ev.Message) {
 				return
 			}
 
-			if state.Status() == discord.DoNotDisturbStatus {
-				return
+			notifier.Notify(ev)
+
+		case *read.UpdateEvent:
+			w.bg.QueueUpdate()
+
+			if !ev.Unread {
+				notifier.Clear(ev.ChannelID)
 			}
 
-			avatarURL := gtkcord.InjectAvatarSize(ev.Author.AvatarURL())
-
-			notify.Send(w.ctx, notify.Notification{
-				ID: notify.HashID(ev.ChannelID),
-				Title: fmt.Sprintf(
-					"%s (%s)",
-					state.AuthorDisplayName(ev),
-					gtkcord.ChannelNameFromID(w.ctx, ev.ChannelID),
-				),
-				Body:  state.MessagePreview(&ev.Message),
-				Icon:  notify.IconURL(w.ctx, avatarURL, notify.IconName("avatar-default-symbolic")),
-				Sound: notify.MessageSound,
-				Action: notify.ActionJSONData("app.open-channel", gtkcord.OpenChannelCommand{
-					ChannelID: ev.ChannelID,
-					MessageID: ev.ID,
-				}),
-			})
+		case *gateway.UserGuildSettingsUpdateEvent:
+			w.bg.QueueUpdate()
+
+		case *gateway.PresenceUpdateEvent:
+			// Keep the status in the tray menu in sync.
+			if me, _ := state.Me(); me != nil && me.ID == ev.User.ID {
+				w.bg.QueueUpdate()
+			}
 		}
 	})
 }
@@ -119,5 +131,6 @@ func (w *loginWindow) Connected() {
 }
 
 func (w *loginWindow) PromptLogin() {
+	(*Window)(w).endSession()
 	(*Window)(w).SwitchToLoginPage()
 }
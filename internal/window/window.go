@@ -7,6 +7,7 @@ import (
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/window/login"
 )
 
@@ -46,6 +47,11 @@ type Window struct {
 	Login   *login.Page
 	Loading *login.LoadingPage
 	Chat    *ChatPage
+
+	bg          *background
+	pendingOpen *gtkcord.OpenChannelCommand
+	// cancelSession ends the services started for the logged in session.
+	cancelSession context.CancelFunc
 }
 
 // NewWindow creates a new Window.
@@ -74,6 +80,10 @@ func NewWindow(ctx context.Context) *Window {
 	w.Stack.SetVisibleChild(w.Login)
 
 	win.SetChild(w.Stack)
+
+	w.bg = newBackground(&w)
+	win.ConnectCloseRequest(w.bg.HideWindow)
+
 	return &w
 }
 
@@ -89,6 +99,39 @@ func (w *Window) SwitchToChatPage() {
 	w.Stack.SetVisibleChild(w.Chat)
 	w.Chat.SwitchToMessages()
 	w.SetTitle("")
+
+	if cmd := w.pendingOpen; cmd != nil {
+		w.pendingOpen = nil
+		w.Chat.OpenMessage(cmd.ChannelID, cmd.MessageID)
+	}
+}
+
+// OpenChannelOnReady opens the channel once the user is logged in, or right
+// away if they already are.
+func (w *Window) OpenChannelOnReady(cmd gtkcord.OpenChannelCommand) {
+	if w.Chat != nil {
+		w.Chat.OpenMessage(cmd.ChannelID, cmd.MessageID)
+		return
+	}
+	w.pendingOpen = &cmd
+}
+
+// startSession ends the previous session and returns the context of a new
+// one. It's done once the user logs out or the login fails.
+func (w *Window) startSession() context.Context {
+	w.endSession()
+
+	ctx, cancel := context.WithCancel(w.ctx)
+	w.cancelSession = cancel
+
+	return ctx
+}
+
+func (w *Window) endSession() {
+	if w.cancelSession != nil {
+		w.cancelSession()
+		w.cancelSession = nil
+	}
 }
 
 func (w *Window) SwitchToLoginPage() {
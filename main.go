@@ -3,16 +3,23 @@ package main
 import (
 	"context"
 	"embed"
+	"fmt"
 	"io/fs"
+	"log"
+	"os"
 
 	"github.com/diamondburned/adaptive"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/app/locale"
 	"github.com/diamondburned/gotkit/app/prefs"
 	"github.com/diamondburned/gotkit/components/logui"
 	"github.com/diamondburned/gotkit/components/prefui"
+	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/godbus/dbus/v5"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/gtkcord"
+	"github.com/thekrafter/gtkcord4-spacebar/internal/ipc"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/window"
 	"github.com/thekrafter/gtkcord4-spacebar/internal/window/about"
 	_ "github.com/thekrafter/gtkcord4-spacebar/internal/icons"
@@ -43,8 +50,47 @@ var _ = cssutil.WriteCSS(`
 `)
 
 func main() {
+	opts, err := parseArgs(os.Args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if opts.Instance != "" {
+		if err := gtkcord.SetInstance(opts.Instance); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(2)
+		}
+	}
+
 	m := manager{}
-	m.app = app.New(context.Background(), "xyz.krafterdev.gtkcord4-spacebar", "gtkcord4-sb")
+	// HandlesOpen lets gtkcord4:// and spacebar:// URIs be opened, both from
+	// the command line and through D-Bus activation.
+	m.app = app.NewWithFlags(context.Background(), "xyz.krafterdev.gtkcord4-spacebar", "gtkcord4-sb", gio.ApplicationHandlesOpen)
+
+	if opts.Command != nil {
+		// Only Run puts the application into the context, but the secret
+		// drivers need it.
+		ctx := app.WithApplication(m.app.Context(), m.app)
+		os.Exit(runCommand(ctx, opts))
+	}
+
+	if opts.Open != "" {
+		cmd, err := gtkcord.ParseChannelLink(opts.Open)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(2)
+		}
+		if cliOpen(opts.Instance, cmd) {
+			return
+		}
+		m.pendingOpen = &cmd
+	}
+
+	// GApplication doesn't know about the flags above.
+	os.Args = opts.Args
+
 	m.app.AddJSONActions(map[string]interface{}{
 		"app.open-channel": m.openChannel,
 		"app.preferences":  func() { prefui.ShowDialog(m.win.Context()) },
@@ -58,12 +104,15 @@ func main() {
 		"<Ctrl>Q": "app.quit",
 	})
 	m.app.ConnectActivate(func() { m.activate(m.app.Context()) })
+	m.app.ConnectOpen(func(files []gio.Filer, hint string) { m.open(m.app.Context(), files) })
 	m.app.RunMain()
 }
 
 type manager struct {
 	app *app.Application
 	win *window.Window
+
+	pendingOpen *gtkcord.OpenChannelCommand
 }
 
 func (m *manager) isLoggedIn() bool {
@@ -81,8 +130,25 @@ func (m *manager) openChannel(cmd gtkcord.OpenChannelCommand) {
 	if !m.isLoggedIn() {
 		return
 	}
-	// TODO: highlight message.
-	m.win.Chat.OpenChannel(cmd.ChannelID)
+	m.win.Present()
+	m.win.Chat.OpenMessage(cmd.ChannelID, cmd.MessageID)
+}
+
+// open opens the channels and messages linked by the given URIs.
+func (m *manager) open(ctx context.Context, files []gio.Filer) {
+	m.activate(ctx)
+
+	for _, file := range files {
+		uri := file.URI()
+
+		cmd, err := gtkcord.ParseChannelLink(uri)
+		if err != nil {
+			log.Println("cannot open URI:", err)
+			continue
+		}
+
+		m.win.OpenChannelOnReady(cmd)
+	}
 }
 
 func (m *manager) activate(ctx context.Context) {
@@ -96,9 +162,46 @@ func (m *manager) activate(ctx context.Context) {
 	m.win = window.NewWindow(ctx)
 	m.win.Show()
 
+	if m.pendingOpen != nil {
+		m.win.OpenChannelOnReady(*m.pendingOpen)
+		m.pendingOpen = nil
+	}
+
+	m.exportIPC(ctx)
+
 	prefs.AsyncLoadSaved(ctx, func(err error) {
 		if err != nil {
 			app.Error(ctx, err)
 		}
 	})
 }
+
+// exportIPC exports the D-Bus interface that lets scripts control this
+// instance. See package ipc.
+func (m *manager) exportIPC(ctx context.Context) {
+	handler := ipc.StateHandler{
+		State: func() *gtkcord.State {
+			if !m.isLoggedIn() {
+				return nil
+			}
+			return gtkcord.FromContext(m.win.Context())
+		},
+		Open: m.openChannel,
+	}
+
+	gtkutil.Async(ctx, func() func() {
+		conn, err := dbus.SessionBus()
+		if err != nil {
+			log.Println("cannot connect to session bus:", err)
+			return nil
+		}
+
+		server, err := ipc.Export(conn, handler)
+		if err != nil {
+			log.Println("cannot export D-Bus interface:", err)
+			return nil
+		}
+
+		return func() { m.app.ConnectShutdown(server.Close) }
+	})
+}
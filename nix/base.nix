@@ -15,13 +15,14 @@
 		gst_all_1.gst-plugins-good
 		gst_all_1.gst-plugins-bad
 		gst_all_1.gst-plugins-ugly
+		ffmpeg
 		libadwaita
 	];
 
 	files = {
 		desktop = {
-			name = "xyz.diamondb.gtkcord4.desktop";
-			path = ./xyz.diamondb.gtkcord4.desktop;
+			name = "xyz.krafterdev.gtkcord4-spacebar.desktop";
+			path = ./xyz.krafterdev.gtkcord4-spacebar.desktop;
 		};
 		logo = {
 			name = "gtkcord4.svg";